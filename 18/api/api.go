@@ -0,0 +1,11 @@
+// Package api holds the published description of the calendar HTTP API.
+package api
+
+import _ "embed"
+
+//go:generate protoc -I proto --go_out=../pkg/calendarpb --go_opt=paths=source_relative --go-grpc_out=../pkg/calendarpb --go-grpc_opt=paths=source_relative proto/calendar.proto
+
+// OpenAPI is the OpenAPI 3 document for every route of the calendar server.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
@@ -0,0 +1,263 @@
+// calendarctl inspects and repairs calendar data directly in a storage
+// backend. Stop the server before changing a file storage it is serving.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/pkg/storage"
+)
+
+const usage = `usage: calendarctl [-storage DSN] <command> [flags]
+
+commands:
+  list    -user ID              list events of a user
+  search  -user ID -q TEXT      full-text search in a user's events
+  export  [-o FILE]             write all events as JSON (stdout by default)
+  import  [-i FILE]             read events from JSON (stdin by default)
+  migrate -to DSN               copy all events to another storage
+  purge   -user ID              delete all events of a user
+  stats                         print event statistics
+
+DSN is "memory:", "sharded:[SHARDS]" or "file:PATH"; it defaults to
+$STORAGE_DSN.
+`
+
+func main() {
+	if err := Run(os.Args, os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Run executes calendarctl with args including the program name.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	dsn := fs.String("storage", os.Getenv("STORAGE_DSN"), "storage DSN")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no command given")
+	}
+	if *dsn == "" {
+		return errors.New("no storage given, use -storage or STORAGE_DSN")
+	}
+
+	store, err := storage.Open(*dsn)
+	if err != nil {
+		return err
+	}
+	return runCommand(store, fs.Args(), stdin, stdout, stderr)
+}
+
+func runCommand(store storage.Storage, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	name := args[0]
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	userID := fs.Int("user", 0, "user ID")
+	query := fs.String("q", "", "search query")
+	in := fs.String("i", "", "input file")
+	out := fs.String("o", "", "output file")
+	to := fs.String("to", "", "target storage DSN")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	needUser := func() error {
+		if *userID <= 0 {
+			return fmt.Errorf("%s: -user is required", name)
+		}
+		return nil
+	}
+
+	switch name {
+	case "list":
+		if err := needUser(); err != nil {
+			return err
+		}
+		return list(store, *userID, stdout)
+	case "search":
+		if err := needUser(); err != nil {
+			return err
+		}
+		return search(store, *userID, *query, stdout)
+	case "export":
+		w := stdout
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return export(store, w)
+	case "import":
+		r := stdin
+		if *in != "" {
+			f, err := os.Open(*in)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		return importEvents(store, r, stdout)
+	case "migrate":
+		if *to == "" {
+			return errors.New("migrate: -to is required")
+		}
+		target, err := storage.Open(*to)
+		if err != nil {
+			return err
+		}
+		return migrate(store, target, stdout)
+	case "purge":
+		if err := needUser(); err != nil {
+			return err
+		}
+		return purge(store, *userID, stdout)
+	case "stats":
+		return stats(store, stdout)
+	default:
+		fmt.Fprint(stderr, usage)
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+func userEvents(store storage.Storage, userID int) ([]model.Event, error) {
+	all, err := store.All()
+	if err != nil {
+		return nil, err
+	}
+	var res []model.Event
+	for _, e := range all {
+		if e.UserID == userID {
+			res = append(res, e)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Date.Before(res[j].Date) })
+	return res, nil
+}
+
+func printEvents(w io.Writer, events []model.Event) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSER\tDATE\tTITLE")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", e.ID, e.UserID, e.Date.Format("2006-01-02"), e.Title)
+	}
+	return tw.Flush()
+}
+
+func list(store storage.Storage, userID int, w io.Writer) error {
+	events, err := userEvents(store, userID)
+	if err != nil {
+		return err
+	}
+	return printEvents(w, events)
+}
+
+func search(store storage.Storage, userID int, query string, w io.Writer) error {
+	if query == "" {
+		return errors.New("search: -q is required")
+	}
+	events, _, err := store.Search(userID, query, 0, 0)
+	if err != nil {
+		return err
+	}
+	return printEvents(w, events)
+}
+
+func export(store storage.Storage, w io.Writer) error {
+	events, err := store.All()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(events)
+}
+
+func importEvents(store storage.Storage, r io.Reader, w io.Writer) error {
+	var events []model.Event
+	if err := json.NewDecoder(r).Decode(&events); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if err := store.Import(events); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "imported %d events\n", len(events))
+	return nil
+}
+
+func migrate(from, to storage.Storage, w io.Writer) error {
+	events, err := from.All()
+	if err != nil {
+		return err
+	}
+	if err := to.Import(events); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "migrated %d events\n", len(events))
+	return nil
+}
+
+func purge(store storage.Storage, userID int, w io.Writer) error {
+	events, err := userEvents(store, userID)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := store.Delete(e.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "deleted %d events of user %d\n", len(events), userID)
+	return nil
+}
+
+func stats(store storage.Storage, w io.Writer) error {
+	events, err := store.All()
+	if err != nil {
+		return err
+	}
+
+	perUser := make(map[int]int)
+	var first, last time.Time
+	for _, e := range events {
+		perUser[e.UserID]++
+		if first.IsZero() || e.Date.Before(first) {
+			first = e.Date
+		}
+		if e.Date.After(last) {
+			last = e.Date
+		}
+	}
+	users := make([]int, 0, len(perUser))
+	for u := range perUser {
+		users = append(users, u)
+	}
+	sort.Ints(users)
+
+	fmt.Fprintf(w, "events: %d\nusers: %d\n", len(events), len(users))
+	if len(events) > 0 {
+		fmt.Fprintf(w, "dates: %s .. %s\n", first.Format("2006-01-02"), last.Format("2006-01-02"))
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tEVENTS")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%d\n", u, perUser[u])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+	"wb_l12/18/pkg/storage"
+)
+
+func seed(t *testing.T) *storage.InMemoryStorage {
+	t.Helper()
+	store := storage.NewInMemoryStorage()
+	for _, e := range []model.Event{
+		{UserID: 1, Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Title: "Retro"},
+		{UserID: 1, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Title: "Sprint planning"},
+		{UserID: 2, Date: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), Title: "Planning poker"},
+	} {
+		_, err := store.Create(&e)
+		require.NoError(t, err)
+	}
+	return store
+}
+
+func run(t *testing.T, store storage.Storage, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := runCommand(store, args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestList(t *testing.T) {
+	out, err := run(t, seed(t), "", "list", "-user", "1")
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "Sprint planning", "events are ordered by date")
+	assert.Contains(t, lines[2], "Retro")
+}
+
+func TestSearch(t *testing.T) {
+	out, err := run(t, seed(t), "", "search", "-user", "2", "-q", "plan")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Planning poker")
+	assert.NotContains(t, out, "Sprint")
+}
+
+func TestExportImport_PreservesIDs(t *testing.T) {
+	src := seed(t)
+	src.Delete(1)
+
+	dump, err := run(t, src, "", "export")
+	require.NoError(t, err)
+
+	dst := storage.NewInMemoryStorage()
+	out, err := run(t, dst, dump, "import")
+	require.NoError(t, err)
+	assert.Equal(t, "imported 2 events\n", out)
+
+	want, _ := src.All()
+	got, _ := dst.All()
+	assert.Equal(t, want, got)
+
+	id, _ := dst.Create(&model.Event{UserID: 3, Date: time.Now(), Title: "New"})
+	assert.Equal(t, 4, id, "new IDs continue after imported ones")
+}
+
+func TestMigrate_ToFileStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	out, err := run(t, seed(t), "", "migrate", "-to", "file:"+path)
+	require.NoError(t, err)
+	assert.Equal(t, "migrated 3 events\n", out)
+
+	reopened, err := storage.NewFileStorage(path)
+	require.NoError(t, err)
+	events, _ := reopened.All()
+	assert.Len(t, events, 3)
+}
+
+func TestPurge(t *testing.T) {
+	store := seed(t)
+	out, err := run(t, store, "", "purge", "-user", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "deleted 2 events of user 1\n", out)
+
+	events, _ := store.All()
+	require.Len(t, events, 1)
+	assert.Equal(t, 2, events[0].UserID)
+}
+
+func TestStats(t *testing.T) {
+	out, err := run(t, seed(t), "", "stats")
+	require.NoError(t, err)
+	assert.Contains(t, out, "events: 3\nusers: 2\ndates: 2024-03-01 .. 2024-04-01\n")
+}
+
+func TestRun_Errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Error(t, Run([]string{"calendarctl", "-storage", "memory:"}, nil, &stdout, &stderr))
+	assert.Error(t, Run([]string{"calendarctl", "-storage", "redis:"}, nil, &stdout, &stderr))
+	assert.Error(t, Run([]string{"calendarctl", "-storage", "memory:", "list"}, nil, &stdout, &stderr))
+	assert.Error(t, Run([]string{"calendarctl", "-storage", "memory:", "drop"}, nil, &stdout, &stderr))
+	assert.NoError(t, Run([]string{"calendarctl", "-storage", "memory:", "stats"}, nil, &stdout, &stderr))
+}
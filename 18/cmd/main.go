@@ -2,63 +2,375 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
+	// User time zones must load on hosts without a tz database.
+	_ "time/tzdata"
 	"wb_l12/18/config"
+	"wb_l12/18/internal/certs"
+	"wb_l12/18/internal/graceful"
+	"wb_l12/18/internal/grpcserver"
 	"wb_l12/18/internal/handler"
+	"wb_l12/18/internal/holiday"
 	"wb_l12/18/internal/middleware"
 	"wb_l12/18/internal/service"
+	"wb_l12/18/internal/tenant"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/internal/web"
+	"wb_l12/18/pkg/calendarpb"
 	"wb_l12/18/pkg/storage"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	cnf := config.Load()
+	cnf, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Error load config: %v", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(cnf.Tracing.Exporter, cnf.Tracing.SampleRatio)
+	if err != nil {
+		log.Fatalf("Error set up tracing: %v", err)
+	}
+
+	var calendars map[string]*holiday.Calendar
+	if cnf.Holidays.Dir != "" {
+		calendars, err = holiday.LoadDir(cnf.Holidays.Dir)
+		if err != nil {
+			log.Fatalf("Error load holidays: %v", err)
+		}
+	}
 
-	storage := storage.NewInMemoryStorage()
-	service := service.NewService(storage)
-	eventHandler := handler.NewEventHandler(service)
+	tenants := map[string]*service.Service{}
+	tenantConfigs := map[string]config.TenantConfig{tenant.Default: {}}
+	for id, tc := range cnf.Tenants {
+		tenantConfigs[id] = tc
+	}
+	for id, tc := range tenantConfigs {
+		svc, err := newService(cnf, id, tc, calendars)
+		if err != nil {
+			log.Fatalf("Error start tenant %s: %v", id, err)
+		}
+		tenants[id] = svc
+	}
+	services := service.NewTenants(tenants)
+	eventHandler := handler.NewEventHandler(services)
 
+	drainer := graceful.NewDrainer()
 	router := gin.New()
-	router.Use(middleware.Logging())
+	router.Use(middleware.Tracing(), middleware.Logging(),
+		middleware.SecurityHeaders(cnf.Security.HSTSMaxAge, cnf.Security.ContentSecurityPolicy))
+	if len(cnf.CORS.AllowedOrigins) > 0 {
+		router.Use(middleware.CORS(middleware.CORSOptions{
+			AllowedOrigins:   cnf.CORS.AllowedOrigins,
+			AllowedMethods:   cnf.CORS.AllowedMethods,
+			AllowedHeaders:   cnf.CORS.AllowedHeaders,
+			AllowCredentials: cnf.CORS.AllowCredentials,
+			MaxAge:           cnf.CORS.MaxAge,
+		}))
+	}
+	// Uploads stream up to the attachment size limit plus room for the
+	// other multipart fields.
+	router.Use(middleware.MaxBody(cnf.Server.MaxBodySize, map[string]int64{
+		"/upload_attachment": cnf.Attachments.MaxSize + cnf.Server.MaxBodySize,
+	}))
+	if len(cnf.Server.TLS.ClientUsers) > 0 {
+		router.Use(middleware.ClientCertIdentity(cnf.Server.TLS.ClientUsers))
+	}
+	router.Use(middleware.Tenant(cnf.Server.TLS.ClientTenants))
+
+	idempotency := middleware.NewIdempotencyStore(cnf.Idempotency.TTL, cnf.Idempotency.MaxEntries)
+	eventHandler.RegisterRoutes(router, middleware.Idempotency(idempotency))
+	if cnf.Attachments.Dir != "" {
+		handler.NewAttachmentHandler(services).RegisterRoutes(router, middleware.Idempotency(idempotency))
+	}
+	handler.NewTemplateHandler(services).RegisterRoutes(router, middleware.Idempotency(idempotency))
+	handler.NewBookingHandler(services).RegisterRoutes(router, middleware.Idempotency(idempotency))
+	web.NewHandler(services).RegisterRoutes(router)
+	handler.NewHealthHandler(drainer).RegisterRoutes(router)
+	if cnf.Admin.Token != "" {
+		handler.NewAdminHandler(services).RegisterRoutes(router.Group("", middleware.AdminToken(cnf.Admin.Token)))
+	}
+
+	var tlsConfig *tls.Config
+	if tlsCnf := cnf.Server.TLS; tlsCnf.Enabled() {
+		reloader, err := certs.NewReloader(tlsCnf.CertFile, tlsCnf.KeyFile, tlsCnf.ClientCAFile)
+		if err != nil {
+			log.Fatalf("Error load TLS certificate: %v", err)
+		}
+		tlsConfig = reloader.ServerConfig(!tlsCnf.ClientCertOptional)
+	}
+
+	// Sockets are inherited from the previous process after a restart.
+	listeners := map[string]net.Listener{}
+	listeners["http"], err = graceful.Listen("http", net.JoinHostPort(cnf.Server.Host, cnf.Server.Port))
+	if err != nil {
+		log.Fatalf("Error listen: %v", err)
+	}
+	if cnf.GRPC.Port != "" {
+		listeners["grpc"], err = graceful.Listen("grpc", net.JoinHostPort(cnf.Server.Host, cnf.GRPC.Port))
+		if err != nil {
+			log.Fatalf("Error listen gRPC: %v", err)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	srvs := startServers(cnf, router, services, tlsConfig, drainer, listeners)
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	if cnf.Retention.Months > 0 {
+		for _, svc := range tenants {
+			go svc.RunRetention(retentionCtx, cnf.Retention.Interval)
+		}
+	}
+	if err := graceful.Ready(); err != nil {
+		log.Printf("Error notify previous process: %v", err)
+	}
+
+	// On restart the new process starts while this one serves, and this
+	// one stops once the new one is ready.
+	handedOff := false
+	for !handedOff {
+		sockets := awaitStop(signals, listeners, cnf.InMemory())
+		if sockets == nil {
+			break
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cnf.Server.HandoffTimeout)
+		err := sockets.Handoff(ctx)
+		cancel()
+		sockets.Close()
+		if err != nil {
+			log.Printf("Error restart server, serve on: %v", err)
+			continue
+		}
+		log.Println("New process serves")
+		handedOff = true
+	}
+
+	drainer.Drain()
+	stopRetention()
+	if !handedOff && cnf.Server.DrainDelay > 0 {
+		log.Printf("Drain for %s...", cnf.Server.DrainDelay)
+		time.Sleep(cnf.Server.DrainDelay)
+	}
+	st := drainer.Stats()
+	log.Printf("Stop server (%d connections, %d streams open)...", st.Connections, st.Streams)
+	ctx, cancel := context.WithTimeout(context.Background(), cnf.Server.ShutdownTimeout)
+	srvs.stop(ctx, drainer)
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), cnf.Server.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Error flush traces: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// awaitStop waits for a signal to stop. On SIGHUP it returns the sockets
+// of listeners to hand over to a new process, or nil to just stop. A
+// restart is refused while inMemory names data the new process would not
+// get.
+func awaitStop(signals <-chan os.Signal, listeners map[string]net.Listener, inMemory []string) *graceful.Sockets {
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			return nil
+		}
+		if len(inMemory) > 0 {
+			log.Printf("Error restart server: %s are kept in memory and would be lost, stop the server instead", strings.Join(inMemory, ", "))
+			continue
+		}
+		sockets, err := graceful.Dup(listeners)
+		if err != nil {
+			log.Printf("Error restart server: %v", err)
+			continue
+		}
+		log.Println("Restart server...")
+		return sockets
+	}
+	return nil
+}
+
+// servers serve HTTP and gRPC on a set of listeners. Once stopped they
+// cannot start again.
+type servers struct {
+	http *http.Server
+	grpc *grpc.Server
+}
 
-	router.POST("/create_event", eventHandler.CreateEvent)
-	router.POST("/delete_event", eventHandler.DeleteEvent)
-	router.POST("/update_event", eventHandler.UpdateEvent)
-	router.GET("/events_for_day", eventHandler.GetByDay)
-	router.GET("/events_for_week", eventHandler.GetByWeek)
-	router.GET("/events_for_month", eventHandler.GetByMonth)
+func startServers(cnf *config.Config, router http.Handler, services service.Provider, tlsConfig *tls.Config, drainer *graceful.Drainer, listeners map[string]net.Listener) *servers {
+	srvs := &servers{http: &http.Server{
+		Handler:      router,
+		ReadTimeout:  cnf.Server.ReadTimeout,
+		WriteTimeout: cnf.Server.WriteTimeout,
+		IdleTimeout:  cnf.Server.IdleTimeout,
+		TLSConfig:    tlsConfig,
+		ConnState:    drainer.ConnState,
+	}}
 
-	srv := &http.Server{
-		Addr:    net.JoinHostPort(cnf.Host, cnf.Port),
-		Handler: router,
+	if lis, ok := listeners["grpc"]; ok {
+		opts := []grpc.ServerOption{grpc.ChainStreamInterceptor(drainer.StreamInterceptor())}
+		if tlsConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		if users := cnf.Server.TLS.ClientUsers; len(users) > 0 {
+			opts = append(opts,
+				grpc.ChainUnaryInterceptor(grpcserver.UnaryIdentity(users)),
+				grpc.ChainStreamInterceptor(grpcserver.StreamIdentity(users)))
+		}
+		srvs.grpc = grpc.NewServer(opts...)
+		calendarpb.RegisterCalendarServiceServer(srvs.grpc, grpcserver.NewServer(services, cnf.Server.TLS.ClientTenants))
+		go func() {
+			log.Printf("gRPC server run on %s", lis.Addr())
+			if err := srvs.grpc.Serve(lis); err != nil {
+				log.Fatalf("Error run gRPC server: %v", err)
+			}
+		}()
 	}
 
 	go func() {
-		log.Printf("HTTP server run on http://%s", srv.Addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		lis := listeners["http"]
+		if tlsConfig != nil {
+			log.Printf("HTTPS server run on https://%s", lis.Addr())
+			err = srvs.http.ServeTLS(lis, "", "")
+		} else {
+			log.Printf("HTTP server run on http://%s", lis.Addr())
+			err = srvs.http.Serve(lis)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Error run server: %v", err)
 		}
 	}()
+	return srvs
+}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
-
-	log.Println("Stop server...")
+// stop closes the listeners and waits for open requests until ctx is done.
+func (s *servers) stop(ctx context.Context, drainer *graceful.Drainer) {
+	// End watch streams first, otherwise GracefulStop waits for them until
+	// the timeout.
+	if err := drainer.CloseStreams(ctx); err != nil {
+		log.Printf("Error close streams: %v", err)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	var wg sync.WaitGroup
+	if s.grpc != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				s.grpc.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				log.Printf("Error stop gRPC server: %v", ctx.Err())
+				s.grpc.Stop()
+			}
+		}()
+	}
 
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := s.http.Shutdown(ctx); err != nil {
 		log.Printf("Error stop server: %v", err)
-		srv.Close()
+		s.http.Close()
 	}
-	log.Println("Server stopped")
+	wg.Wait()
+}
+
+// newService opens the storage and files of tenant id. The default tenant
+// uses the configured paths as they are, others get their own next to them.
+func newService(cnf *config.Config, id string, tc config.TenantConfig, calendars map[string]*holiday.Calendar) (*service.Service, error) {
+	dsn := cnf.Storage.DSN
+	if path, ok := strings.CutPrefix(dsn, "file:"); ok {
+		dsn = "file:" + tenant.Path(path, id)
+	}
+	store, err := storage.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open storage: %w", err)
+	}
+	if cnf.Storage.CacheSize > 0 {
+		store = storage.NewCachedStorage(store, cnf.Storage.CacheSize)
+	}
+	store = storage.NewChangeLog(store, cnf.Storage.ChangeLogSize)
+	if cnf.Tracing.Exporter != "" {
+		store = storage.NewTracedStorage(store)
+	}
+
+	quota := service.Quota{
+		MaxEventsPerUser: cnf.Limits.MaxEventsPerUser,
+		MaxEventsPerDay:  cnf.Limits.MaxEventsPerDay,
+	}
+	if tc.MaxEventsPerUser > 0 {
+		quota.MaxEventsPerUser = tc.MaxEventsPerUser
+	}
+	if tc.MaxEventsPerDay > 0 {
+		quota.MaxEventsPerDay = tc.MaxEventsPerDay
+	}
+	opts := []service.Option{service.WithQuota(quota), service.WithDefaultTimeZone(tc.TimeZone)}
+
+	if cnf.Retention.Months > 0 {
+		policy := service.RetentionPolicy{Months: cnf.Retention.Months}
+		if cnf.Retention.ArchiveFile != "" {
+			policy.Archiver = storage.NewFileArchive(tenant.Path(cnf.Retention.ArchiveFile, id))
+		}
+		opts = append(opts, service.WithRetention(policy))
+	}
+	if calendars != nil {
+		region := cnf.Holidays.Region
+		if tc.HolidayRegion != "" {
+			region = tc.HolidayRegion
+		}
+		if _, ok := calendars[region]; region != "" && !ok {
+			return nil, fmt.Errorf("no holiday calendar for region %q in %s", region, cnf.Holidays.Dir)
+		}
+		opts = append(opts, service.WithHolidays(calendars, region))
+	}
+	if cnf.Attachments.Dir != "" {
+		attachments, err := storage.NewDiskAttachmentStore(tenant.Path(cnf.Attachments.Dir, id), cnf.Attachments.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("open attachments: %w", err)
+		}
+		opts = append(opts, service.WithAttachments(attachments))
+	}
+	if cnf.Templates.File != "" {
+		templates, err := storage.NewFileTemplateStore(tenant.Path(cnf.Templates.File, id))
+		if err != nil {
+			return nil, fmt.Errorf("open templates: %w", err)
+		}
+		opts = append(opts, service.WithTemplates(templates))
+	}
+	if cnf.Bookings.File != "" {
+		links, err := storage.NewFileBookingLinkStore(tenant.Path(cnf.Bookings.File, id))
+		if err != nil {
+			return nil, fmt.Errorf("open booking links: %w", err)
+		}
+		opts = append(opts, service.WithBookingLinks(links))
+	}
+	if cnf.Settings.File != "" {
+		settings, err := storage.NewFileUserSettingsStore(tenant.Path(cnf.Settings.File, id))
+		if err != nil {
+			return nil, fmt.Errorf("open user settings: %w", err)
+		}
+		opts = append(opts, service.WithUserSettings(settings))
+	}
+	return service.NewService(store, opts...), nil
 }
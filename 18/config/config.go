@@ -1,28 +1,649 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wb_l12/18/internal/tenant"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the calendar server configuration. Values are layered in the
+// following order, each layer overriding the previous one:
+// defaults, config file, environment variables, command-line flags.
 type Config struct {
-	Host string
-	Port string
+	Server    ServerConfig    `yaml:"server"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	Retention RetentionConfig `yaml:"retention"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Holidays  HolidaysConfig  `yaml:"holidays"`
+
+	Attachments AttachmentsConfig `yaml:"attachments"`
+	Templates   TemplatesConfig   `yaml:"templates"`
+	Bookings    BookingsConfig    `yaml:"bookings"`
+	Settings    SettingsConfig    `yaml:"settings"`
+
+	// Tenants enables multi-tenancy. Each tenant has its own events, IDs and
+	// files; requests name theirs in the X-Tenant-ID header. Requests
+	// without one use the "default" tenant, which always exists.
+	Tenants map[string]TenantConfig `yaml:"tenants"`
+
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Security    SecurityConfig    `yaml:"security"`
+}
+
+type ServerConfig struct {
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// DrainDelay is how long /readyz fails before the server stops taking
+	// requests on shutdown, for load balancers to notice.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// ShutdownTimeout bounds the wait for open requests and streams.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// HandoffTimeout bounds the wait for the new process started on SIGHUP
+	// to serve on the inherited sockets; after it the old one serves on.
+	HandoffTimeout time.Duration `yaml:"handoff_timeout"`
+	// MaxBodySize caps request bodies in bytes; attachment uploads may
+	// exceed it by attachments.max_size.
+	MaxBodySize int64 `yaml:"max_body_size"`
+
+	TLS TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig enables HTTPS when CertFile and KeyFile are set. With
+// ClientCAFile set, client certificates are verified against that bundle and
+// ClientUsers maps a certificate subject (e.g. "CN=alice,O=Team") to the
+// user ID the client is allowed to act as, and ClientTenants to the only
+// tenant it may use.
+type TLSConfig struct {
+	CertFile           string            `yaml:"cert_file"`
+	KeyFile            string            `yaml:"key_file"`
+	ClientCAFile       string            `yaml:"client_ca_file"`
+	ClientCertOptional bool              `yaml:"client_cert_optional"`
+	ClientUsers        map[string]int    `yaml:"client_users"`
+	ClientTenants      map[string]string `yaml:"client_tenants"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// GRPCConfig configures the gRPC API. It listens on Server.Host and shares
+// the TLS settings of the HTTP server; an empty Port disables it.
+type GRPCConfig struct {
+	Port string `yaml:"port"`
+}
+
+// StorageConfig selects the event storage: "memory:", "sharded:[SHARDS]" or
+// "file:PATH". CacheSize is the number of day, week and month query results kept in
+// memory; zero disables the cache. ChangeLogSize is the number of recent
+// changes kept for delta sync.
+type StorageConfig struct {
+	DSN           string `yaml:"dsn"`
+	CacheSize     int    `yaml:"cache_size"`
+	ChangeLogSize int    `yaml:"change_log_size"`
+}
+
+// LimitsConfig caps the number of events per user; zero means unlimited.
+type LimitsConfig struct {
+	MaxEventsPerUser int `yaml:"max_events_per_user"`
+	MaxEventsPerDay  int `yaml:"max_events_per_day"`
+}
+
+// RetentionConfig removes events older than Months months every Interval.
+// Removed events are appended to ArchiveFile, or purged if it is empty.
+// Zero Months disables retention.
+type RetentionConfig struct {
+	Months      int           `yaml:"months"`
+	ArchiveFile string        `yaml:"archive_file"`
+	Interval    time.Duration `yaml:"interval"`
+}
+
+// IdempotencyConfig sets how long responses to write requests carrying an
+// Idempotency-Key header are kept for replay, and for how many keys at most.
+type IdempotencyConfig struct {
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+}
+
+// HolidaysConfig loads holiday calendars from Dir, one .json or .ics file per
+// region named after the file. Region is the default for users that did not
+// choose one; without calendars only weekends are non-working days.
+type HolidaysConfig struct {
+	Dir    string `yaml:"dir"`
+	Region string `yaml:"region"`
+}
+
+// AttachmentsConfig stores event attachments in Dir; an empty Dir disables
+// them. Files larger than MaxSize bytes are rejected.
+type AttachmentsConfig struct {
+	Dir     string `yaml:"dir"`
+	MaxSize int64  `yaml:"max_size"`
+}
+
+// TemplatesConfig keeps event templates in File; with an empty File they
+// are lost on restart.
+type TemplatesConfig struct {
+	File string `yaml:"file"`
+}
+
+// BookingsConfig keeps booking links in File; with an empty File they are
+// lost on restart.
+type BookingsConfig struct {
+	File string `yaml:"file"`
+}
+
+// SettingsConfig keeps user settings, such as time zones, in File; with an
+// empty File they are lost on restart.
+type SettingsConfig struct {
+	File string `yaml:"file"`
+}
+
+// TracingConfig exports OpenTelemetry spans of requests to Exporter:
+// "stdout", "file:PATH" for JSON lines in PATH, or empty to disable
+// tracing. SampleRatio is the fraction of new traces recorded; requests
+// carrying a traceparent header follow the caller's decision.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// CORSConfig lets browser apps on AllowedOrigins, such as
+// "https://app.example.com", call the API; an empty list disables CORS. "*"
+// allows any origin, but not together with AllowCredentials. Browsers cache
+// preflight responses for MaxAge.
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// SecurityConfig sets the security headers of responses. HSTSMaxAge is sent
+// over TLS only; zero disables it. An empty ContentSecurityPolicy omits the
+// header except on the web UI, which sets its own.
+type SecurityConfig struct {
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"`
+	ContentSecurityPolicy string        `yaml:"content_security_policy"`
+}
+
+// TenantConfig overrides settings for one tenant; zero values keep the
+// global ones. TimeZone is the default of users that did not choose one.
+type TenantConfig struct {
+	MaxEventsPerUser int    `yaml:"max_events_per_user"`
+	MaxEventsPerDay  int    `yaml:"max_events_per_day"`
+	TimeZone         string `yaml:"time_zone"`
+	HolidayRegion    string `yaml:"holiday_region"`
+}
+
+// AdminConfig protects the /admin routes; they are disabled without a token.
+type AdminConfig struct {
+	Token string `yaml:"token"`
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host:            "localhost",
+			Port:            "8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+			HandoffTimeout:  30 * time.Second,
+			MaxBodySize:     1 << 20,
+		},
+		GRPC: GRPCConfig{
+			Port: "9090",
+		},
+		Storage: StorageConfig{
+			DSN:           "memory:",
+			CacheSize:     1024,
+			ChangeLogSize: 10000,
+		},
+		Retention: RetentionConfig{
+			Interval: time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:        24 * time.Hour,
+			MaxEntries: 100000,
+		},
+		Attachments: AttachmentsConfig{
+			MaxSize: 10 << 20,
+		},
+		Tracing: TracingConfig{
+			SampleRatio: 1,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "Idempotency-Key", "X-Tenant-ID", "traceparent"},
+			MaxAge:         10 * time.Minute,
+		},
+		Security: SecurityConfig{
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		},
+	}
 }
 
-func Load() *Config {
+// Load builds the configuration from all sources. args are the command-line
+// arguments without the program name. The config file path is taken from the
+// -config flag or the CONFIG_FILE environment variable; .yaml, .yml and .json
+// files are supported.
+func Load(args []string) (*Config, error) {
 	godotenv.Load()
-	host := os.Getenv("SERVER_HOST")
-	if host == "" {
-		host = "localhost"
+
+	cfg := Default()
+
+	fs := flag.NewFlagSet("calendar", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML or JSON config file")
+	bindFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
 	}
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	// Flags share storage with cfg, so remember the explicitly set ones and
+	// re-apply them after the file and environment layers.
+	set := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	if *path != "" {
+		if err := loadFile(*path, cfg); err != nil {
+			return nil, err
+		}
 	}
-	return &Config{
-		Host: host,
-		Port: port,
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
+	}
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return nil, fmt.Errorf("config: -%s: %w", name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// InMemory names the data that is kept only in memory and so lost when
+// the process exits: events without file storage, and templates, booking
+// links and user settings without a file.
+func (c *Config) InMemory() []string {
+	var res []string
+	if !strings.HasPrefix(c.Storage.DSN, "file:") {
+		res = append(res, "events")
+	}
+	if c.Templates.File == "" {
+		res = append(res, "templates")
+	}
+	if c.Bookings.File == "" {
+		res = append(res, "booking links")
+	}
+	if c.Settings.File == "" {
+		res = append(res, "user settings")
+	}
+	return res
+}
+
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Host == "" {
+		errs = append(errs, errors.New("server.host must not be empty"))
+	}
+	if !validPort(c.Server.Port) {
+		errs = append(errs, fmt.Errorf("server.port must be a number in 1..65535, got %q", c.Server.Port))
+	}
+	if c.GRPC.Port != "" {
+		if !validPort(c.GRPC.Port) {
+			errs = append(errs, fmt.Errorf("grpc.port must be a number in 1..65535, got %q", c.GRPC.Port))
+		} else if c.GRPC.Port == c.Server.Port {
+			errs = append(errs, fmt.Errorf("grpc.port must differ from server.port %q", c.Server.Port))
+		}
+	}
+	for name, d := range map[string]time.Duration{
+		"server.read_timeout":     c.Server.ReadTimeout,
+		"server.write_timeout":    c.Server.WriteTimeout,
+		"server.idle_timeout":     c.Server.IdleTimeout,
+		"server.drain_delay":      c.Server.DrainDelay,
+		"server.shutdown_timeout": c.Server.ShutdownTimeout,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", name, d))
+		}
+	}
+	if c.Server.HandoffTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.handoff_timeout must be positive, got %s", c.Server.HandoffTimeout))
+	}
+	if c.Server.MaxBodySize <= 0 {
+		errs = append(errs, fmt.Errorf("server.max_body_size must be positive, got %d", c.Server.MaxBodySize))
+	}
+	if tls := c.Server.TLS; tls.Enabled() {
+		if tls.CertFile == "" || tls.KeyFile == "" {
+			errs = append(errs, errors.New("server.tls.cert_file and server.tls.key_file must be set together"))
+		}
+	} else if tls.ClientCAFile != "" {
+		errs = append(errs, errors.New("server.tls.client_ca_file requires server.tls.cert_file and key_file"))
+	}
+	if len(c.Server.TLS.ClientUsers) > 0 && c.Server.TLS.ClientCAFile == "" {
+		errs = append(errs, errors.New("server.tls.client_users requires server.tls.client_ca_file"))
+	}
+	if len(c.Server.TLS.ClientTenants) > 0 && c.Server.TLS.ClientCAFile == "" {
+		errs = append(errs, errors.New("server.tls.client_tenants requires server.tls.client_ca_file"))
+	}
+	for subject, id := range c.Server.TLS.ClientTenants {
+		if _, ok := c.Tenants[id]; !ok && id != tenant.Default {
+			errs = append(errs, fmt.Errorf("server.tls.client_tenants: %q maps to unknown tenant %q", subject, id))
+		}
+	}
+	if c.Storage.DSN == "" {
+		errs = append(errs, errors.New("storage.dsn must not be empty"))
+	}
+	if c.Storage.CacheSize < 0 {
+		errs = append(errs, fmt.Errorf("storage.cache_size must not be negative, got %d", c.Storage.CacheSize))
+	}
+	if c.Storage.ChangeLogSize <= 0 {
+		errs = append(errs, fmt.Errorf("storage.change_log_size must be positive, got %d", c.Storage.ChangeLogSize))
+	}
+	if c.Limits.MaxEventsPerUser < 0 {
+		errs = append(errs, fmt.Errorf("limits.max_events_per_user must not be negative, got %d", c.Limits.MaxEventsPerUser))
+	}
+	if c.Limits.MaxEventsPerDay < 0 {
+		errs = append(errs, fmt.Errorf("limits.max_events_per_day must not be negative, got %d", c.Limits.MaxEventsPerDay))
+	}
+	if c.Retention.Months < 0 {
+		errs = append(errs, fmt.Errorf("retention.months must not be negative, got %d", c.Retention.Months))
+	}
+	if c.Retention.Months > 0 && c.Retention.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("retention.interval must be positive, got %s", c.Retention.Interval))
+	}
+	if c.Holidays.Region != "" && c.Holidays.Dir == "" {
+		errs = append(errs, errors.New("holidays.region requires holidays.dir"))
+	}
+	if c.Attachments.MaxSize <= 0 {
+		errs = append(errs, fmt.Errorf("attachments.max_size must be positive, got %d", c.Attachments.MaxSize))
+	}
+	for id, t := range c.Tenants {
+		if err := tenant.Validate(id); err != nil {
+			errs = append(errs, fmt.Errorf("tenants: %w", err))
+		}
+		if t.MaxEventsPerUser < 0 || t.MaxEventsPerDay < 0 {
+			errs = append(errs, fmt.Errorf("tenants.%s: limits must not be negative", id))
+		}
+		if _, err := time.LoadLocation(t.TimeZone); err != nil {
+			errs = append(errs, fmt.Errorf("tenants.%s.time_zone: unknown time zone %q", id, t.TimeZone))
+		}
+		if t.HolidayRegion != "" && c.Holidays.Dir == "" {
+			errs = append(errs, fmt.Errorf("tenants.%s.holiday_region requires holidays.dir", id))
+		}
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency.ttl must be positive, got %s", c.Idempotency.TTL))
+	}
+	if c.Idempotency.MaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency.max_entries must be positive, got %d", c.Idempotency.MaxEntries))
+	}
+	if e := c.Tracing.Exporter; e != "" && e != "stdout" && (!strings.HasPrefix(e, "file:") || e == "file:") {
+		errs = append(errs, fmt.Errorf(`tracing.exporter must be "stdout" or "file:PATH", got %q`, e))
+	}
+	if r := c.Tracing.SampleRatio; r < 0 || r > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio must be within 0..1, got %g", r))
+	}
+	for _, o := range c.CORS.AllowedOrigins {
+		if o == "*" {
+			if c.CORS.AllowCredentials {
+				errs = append(errs, errors.New(`cors.allow_credentials cannot be used with origin "*"`))
+			}
+		} else if !validOrigin(o) {
+			errs = append(errs, fmt.Errorf(`cors.allowed_origins must be "*" or like "https://host[:port]", got %q`, o))
+		}
+	}
+	if len(c.CORS.AllowedOrigins) > 0 && len(c.CORS.AllowedMethods) == 0 {
+		errs = append(errs, errors.New("cors.allowed_methods must not be empty"))
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cors.max_age must not be negative, got %s", c.CORS.MaxAge))
+	}
+	if c.Security.HSTSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("security.hsts_max_age must not be negative, got %s", c.Security.HSTSMaxAge))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validPort(port string) bool {
+	p, err := strconv.Atoi(port)
+	return err == nil && p >= 1 && p <= 65535
+}
+
+// validOrigin reports whether o is a scheme and host with an optional port,
+// as browsers send in the Origin header.
+func validOrigin(o string) bool {
+	u, err := url.Parse(o)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// listValue is a comma-separated list flag.
+type listValue struct{ dst *[]string }
+
+func (v listValue) String() string {
+	if v.dst == nil {
+		return ""
+	}
+	return strings.Join(*v.dst, ",")
+}
+
+func (v listValue) Set(s string) error {
+	*v.dst = splitList(s)
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping blank items.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func loadFile(path string, cfg *Config) error {
+	switch strings.ToLower(path[strings.LastIndex(path, ".")+1:]) {
+	case "yaml", "yml", "json":
+	default:
+		return fmt.Errorf("config: unsupported file type %q, use .yaml, .yml or .json", path)
 	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	// JSON is a subset of YAML, so one decoder handles both formats.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	setString := func(key string, dst *string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	setList := func(key string, dst *[]string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = splitList(v)
+		}
+	}
+	setBool := func(key string, dst *bool) error {
+		v := os.Getenv(key)
+		if v == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+		*dst = b
+		return nil
+	}
+	setInt := func(key string, dst *int) error {
+		v := os.Getenv(key)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+		*dst = n
+		return nil
+	}
+	setInt64 := func(key string, dst *int64) error {
+		v := os.Getenv(key)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+		*dst = n
+		return nil
+	}
+	setFloat := func(key string, dst *float64) error {
+		v := os.Getenv(key)
+		if v == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+		*dst = f
+		return nil
+	}
+	setDuration := func(key string, dst *time.Duration) error {
+		v := os.Getenv(key)
+		if v == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+		*dst = d
+		return nil
+	}
+
+	setString("SERVER_HOST", &cfg.Server.Host)
+	setString("SERVER_PORT", &cfg.Server.Port)
+	setString("GRPC_PORT", &cfg.GRPC.Port)
+	setString("SERVER_TLS_CERT_FILE", &cfg.Server.TLS.CertFile)
+	setString("SERVER_TLS_KEY_FILE", &cfg.Server.TLS.KeyFile)
+	setString("SERVER_TLS_CLIENT_CA_FILE", &cfg.Server.TLS.ClientCAFile)
+	setString("STORAGE_DSN", &cfg.Storage.DSN)
+	setString("RETENTION_ARCHIVE_FILE", &cfg.Retention.ArchiveFile)
+	setString("ADMIN_TOKEN", &cfg.Admin.Token)
+	setString("HOLIDAYS_DIR", &cfg.Holidays.Dir)
+	setString("HOLIDAYS_REGION", &cfg.Holidays.Region)
+	setString("ATTACHMENTS_DIR", &cfg.Attachments.Dir)
+	setString("TEMPLATES_FILE", &cfg.Templates.File)
+	setString("BOOKINGS_FILE", &cfg.Bookings.File)
+	setString("SETTINGS_FILE", &cfg.Settings.File)
+	setString("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	setList("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	setList("CORS_ALLOWED_METHODS", &cfg.CORS.AllowedMethods)
+	setList("CORS_ALLOWED_HEADERS", &cfg.CORS.AllowedHeaders)
+	setString("SECURITY_CONTENT_SECURITY_POLICY", &cfg.Security.ContentSecurityPolicy)
+	return errors.Join(
+		setDuration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout),
+		setDuration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout),
+		setDuration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout),
+		setDuration("SERVER_DRAIN_DELAY", &cfg.Server.DrainDelay),
+		setDuration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout),
+		setDuration("SERVER_HANDOFF_TIMEOUT", &cfg.Server.HandoffTimeout),
+		setInt64("SERVER_MAX_BODY_SIZE", &cfg.Server.MaxBodySize),
+		setInt("STORAGE_CACHE_SIZE", &cfg.Storage.CacheSize),
+		setInt("STORAGE_CHANGE_LOG_SIZE", &cfg.Storage.ChangeLogSize),
+		setInt("LIMITS_MAX_EVENTS_PER_USER", &cfg.Limits.MaxEventsPerUser),
+		setInt("LIMITS_MAX_EVENTS_PER_DAY", &cfg.Limits.MaxEventsPerDay),
+		setInt("RETENTION_MONTHS", &cfg.Retention.Months),
+		setDuration("RETENTION_INTERVAL", &cfg.Retention.Interval),
+		setDuration("IDEMPOTENCY_TTL", &cfg.Idempotency.TTL),
+		setInt("IDEMPOTENCY_MAX_ENTRIES", &cfg.Idempotency.MaxEntries),
+		setInt64("ATTACHMENTS_MAX_SIZE", &cfg.Attachments.MaxSize),
+		setFloat("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio),
+		setBool("CORS_ALLOW_CREDENTIALS", &cfg.CORS.AllowCredentials),
+		setDuration("CORS_MAX_AGE", &cfg.CORS.MaxAge),
+		setDuration("SECURITY_HSTS_MAX_AGE", &cfg.Security.HSTSMaxAge),
+	)
+}
+
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Server.Host, "host", cfg.Server.Host, "HTTP listen host")
+	fs.StringVar(&cfg.Server.Port, "port", cfg.Server.Port, "HTTP listen port")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "read-timeout", cfg.Server.ReadTimeout, "HTTP read timeout")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "HTTP write timeout")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", cfg.Server.IdleTimeout, "HTTP keep-alive idle timeout")
+	fs.DurationVar(&cfg.Server.DrainDelay, "drain-delay", cfg.Server.DrainDelay, "how long /readyz fails before shutdown")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "graceful shutdown timeout")
+	fs.DurationVar(&cfg.Server.HandoffTimeout, "handoff-timeout", cfg.Server.HandoffTimeout, "wait for the new process on SIGHUP restart")
+	fs.Int64Var(&cfg.Server.MaxBodySize, "max-body-size", cfg.Server.MaxBodySize, "max request body size in bytes")
+	fs.StringVar(&cfg.GRPC.Port, "grpc-port", cfg.GRPC.Port, "gRPC listen port, empty to disable")
+	fs.StringVar(&cfg.Server.TLS.CertFile, "tls-cert", cfg.Server.TLS.CertFile, "TLS certificate file")
+	fs.StringVar(&cfg.Server.TLS.KeyFile, "tls-key", cfg.Server.TLS.KeyFile, "TLS private key file")
+	fs.StringVar(&cfg.Server.TLS.ClientCAFile, "tls-client-ca", cfg.Server.TLS.ClientCAFile, "CA bundle for client certificate verification")
+	fs.StringVar(&cfg.Storage.DSN, "storage", cfg.Storage.DSN, "event storage: memory:, sharded:[SHARDS] or file:PATH")
+	fs.IntVar(&cfg.Storage.CacheSize, "storage-cache-size", cfg.Storage.CacheSize, "cached day/week/month query results, 0 to disable")
+	fs.IntVar(&cfg.Storage.ChangeLogSize, "storage-change-log-size", cfg.Storage.ChangeLogSize, "recent changes kept for delta sync")
+	fs.IntVar(&cfg.Limits.MaxEventsPerUser, "max-events-per-user", cfg.Limits.MaxEventsPerUser, "max events per user, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxEventsPerDay, "max-events-per-day", cfg.Limits.MaxEventsPerDay, "max events per user and day, 0 for unlimited")
+	fs.IntVar(&cfg.Retention.Months, "retention-months", cfg.Retention.Months, "remove events older than N months, 0 to keep forever")
+	fs.StringVar(&cfg.Retention.ArchiveFile, "retention-archive", cfg.Retention.ArchiveFile, "append removed events to this file instead of purging")
+	fs.StringVar(&cfg.Holidays.Dir, "holidays-dir", cfg.Holidays.Dir, "directory with holiday calendars, one .json or .ics per region")
+	fs.StringVar(&cfg.Holidays.Region, "holidays-region", cfg.Holidays.Region, "default holiday region")
+	fs.StringVar(&cfg.Attachments.Dir, "attachments-dir", cfg.Attachments.Dir, "directory for event attachments, empty to disable")
+	fs.Int64Var(&cfg.Attachments.MaxSize, "attachments-max-size", cfg.Attachments.MaxSize, "max attachment size in bytes")
+	fs.StringVar(&cfg.Templates.File, "templates-file", cfg.Templates.File, "file for event templates, empty to keep them in memory")
+	fs.StringVar(&cfg.Bookings.File, "bookings-file", cfg.Bookings.File, "file for booking links, empty to keep them in memory")
+	fs.StringVar(&cfg.Settings.File, "settings-file", cfg.Settings.File, "file for user settings, empty to keep them in memory")
+	fs.DurationVar(&cfg.Idempotency.TTL, "idempotency-ttl", cfg.Idempotency.TTL, "how long Idempotency-Key responses are kept")
+	fs.IntVar(&cfg.Idempotency.MaxEntries, "idempotency-max-entries", cfg.Idempotency.MaxEntries, "max Idempotency-Key responses kept")
+	fs.StringVar(&cfg.Tracing.Exporter, "tracing", cfg.Tracing.Exporter, "trace exporter: stdout or file:PATH, empty to disable")
+	fs.Float64Var(&cfg.Tracing.SampleRatio, "tracing-sample-ratio", cfg.Tracing.SampleRatio, "fraction of new traces recorded")
+	fs.Var(listValue{&cfg.CORS.AllowedOrigins}, "cors-origins", "comma-separated origins allowed to call the API, * for any")
+	fs.BoolVar(&cfg.CORS.AllowCredentials, "cors-credentials", cfg.CORS.AllowCredentials, "allow CORS requests with cookies or client certificates")
+	fs.DurationVar(&cfg.CORS.MaxAge, "cors-max-age", cfg.CORS.MaxAge, "how long browsers cache CORS preflight responses")
+	fs.DurationVar(&cfg.Security.HSTSMaxAge, "hsts-max-age", cfg.Security.HSTSMaxAge, "Strict-Transport-Security max age over TLS, 0 to disable")
 }
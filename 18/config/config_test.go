@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_LayerPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  host: file-host
+  port: "9000"
+  read_timeout: 3s
+  idle_timeout: 30s
+`)
+	t.Setenv("SERVER_PORT", "9100")
+	t.Setenv("SERVER_READ_TIMEOUT", "4s")
+
+	cfg, err := Load([]string{"-config", path, "-read-timeout", "7s"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "file-host", cfg.Server.Host)
+	assert.Equal(t, "9100", cfg.Server.Port)
+	assert.Equal(t, 7*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 30*time.Second, cfg.Server.IdleTimeout)
+	assert.Equal(t, 10*time.Second, cfg.Server.WriteTimeout)
+}
+
+func TestLoad_JSONFileFromEnv(t *testing.T) {
+	path := writeFile(t, "config.json", `{"server": {"port": "8181", "shutdown_timeout": "1s"}}`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "8181", cfg.Server.Port)
+	assert.Equal(t, time.Second, cfg.Server.ShutdownTimeout)
+}
+
+func TestLoad_CORS(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+cors:
+  allowed_origins: ["https://file.example.com"]
+  allowed_methods: [GET, POST, OPTIONS]
+  max_age: 1h
+`)
+	t.Setenv("CORS_ALLOWED_HEADERS", "Content-Type, X-Tenant-ID")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	cfg, err := Load([]string{"-config", path, "-cors-origins", "https://a.example.com,https://b.example.com:8443"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com:8443"}, cfg.CORS.AllowedOrigins)
+	assert.Equal(t, []string{"GET", "POST", "OPTIONS"}, cfg.CORS.AllowedMethods)
+	assert.Equal(t, []string{"Content-Type", "X-Tenant-ID"}, cfg.CORS.AllowedHeaders)
+	assert.True(t, cfg.CORS.AllowCredentials)
+	assert.Equal(t, time.Hour, cfg.CORS.MaxAge)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{name: "bad port", args: []string{"-port", "http"}, want: "server.port"},
+		{name: "negative timeout", args: []string{"-idle-timeout", "-1s"}, want: "server.idle_timeout"},
+		{name: "zero handoff timeout", env: map[string]string{"SERVER_HANDOFF_TIMEOUT": "0s"}, want: "server.handoff_timeout"},
+		{name: "bad env duration", env: map[string]string{"SERVER_WRITE_TIMEOUT": "soon"}, want: "SERVER_WRITE_TIMEOUT"},
+		{name: "unknown file field", file: "server:\n  hots: x\n", want: "hots"},
+		{name: "zero attachment size", args: []string{"-attachments-max-size", "0"}, want: "attachments.max_size"},
+		{name: "bad tenant id", file: "tenants:\n  Acme: {}\n", want: "invalid tenant"},
+		{name: "bad tenant time zone", file: "tenants:\n  acme:\n    time_zone: Mars/Base\n", want: "tenants.acme.time_zone"},
+		{name: "unknown trace exporter", args: []string{"-tracing", "jaeger"}, want: "tracing.exporter"},
+		{name: "empty change log", env: map[string]string{"STORAGE_CHANGE_LOG_SIZE": "0"}, want: "storage.change_log_size"},
+		{name: "bad sample ratio", env: map[string]string{"TRACING_SAMPLE_RATIO": "1.5"}, want: "tracing.sample_ratio"},
+		{name: "zero max body size", env: map[string]string{"SERVER_MAX_BODY_SIZE": "0"}, want: "server.max_body_size"},
+		{name: "bad cors origin", args: []string{"-cors-origins", "app.example.com"}, want: "cors.allowed_origins"},
+		{name: "cors origin with path", env: map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com/ui"}, want: "cors.allowed_origins"},
+		{name: "cors credentials with any origin", args: []string{"-cors-origins", "*", "-cors-credentials"}, want: "cors.allow_credentials"},
+		{name: "bad env bool", env: map[string]string{"CORS_ALLOW_CREDENTIALS": "maybe"}, want: "CORS_ALLOW_CREDENTIALS"},
+		{name: "no cors methods", file: "cors:\n  allowed_origins: [\"https://app.example.com\"]\n  allowed_methods: []\n", want: "cors.allowed_methods"},
+		{name: "negative hsts max age", args: []string{"-hsts-max-age", "-1h"}, want: "security.hsts_max_age"},
+		{name: "unknown flag", args: []string{"-verbose"}, want: "verbose"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, "config.yml", tt.file))
+			}
+			_, err := Load(args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestLoad_UnsupportedFileType(t *testing.T) {
+	_, err := Load([]string{"-config", writeFile(t, "config.toml", "")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported file type")
+}
+
+func TestConfig_InMemory(t *testing.T) {
+	cfg := Default()
+	assert.Equal(t, []string{"events", "templates", "booking links", "user settings"}, cfg.InMemory())
+
+	cfg.Storage.DSN = "file:events.json"
+	cfg.Templates.File = "templates.json"
+	cfg.Bookings.File = "bookings.json"
+	assert.Equal(t, []string{"user settings"}, cfg.InMemory())
+	cfg.Settings.File = "settings.json"
+	assert.Empty(t, cfg.InMemory())
+}
@@ -0,0 +1,196 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) keyPair(t *testing.T) tls.Certificate {
+	t.Helper()
+	pair, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	require.NoError(t, err)
+	return pair
+}
+
+func writeCert(t *testing.T, dir string, c *testCert) (certFile, keyFile string) {
+	t.Helper()
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, c.certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+func startServer(t *testing.T, cfg *tls.Config) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.String()))
+		}
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func client(roots *x509.CertPool, certs ...tls.Certificate) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+		DisableKeepAlives: true,
+	}}
+}
+
+func peerCN(t *testing.T, c *http.Client, url string) string {
+	t.Helper()
+	resp, err := c.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func TestReloader_ReloadsChangedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "test-ca", nil, true)
+	first := newCert(t, "first", ca, false)
+	second := newCert(t, "second", ca, false)
+
+	certFile, keyFile := writeCert(t, dir, first)
+	r, err := NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+	r.CheckInterval = 0
+
+	srv := startServer(t, r.ServerConfig(false))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	c := client(roots)
+
+	assert.Equal(t, "first", peerCN(t, c, srv.URL))
+
+	writeCert(t, dir, second)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	assert.Equal(t, "second", peerCN(t, c, srv.URL))
+}
+
+func TestReloader_KeepsCertificateOnBrokenFile(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "test-ca", nil, true)
+	certFile, keyFile := writeCert(t, dir, newCert(t, "server", ca, false))
+	r, err := NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+	r.CheckInterval = 0
+
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	cert, _ := r.current()
+	assert.Equal(t, "server", cert.Leaf.Subject.CommonName)
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "test-ca", nil, true)
+	otherCA := newCert(t, "other-ca", nil, true)
+	certFile, keyFile := writeCert(t, dir, newCert(t, "server", ca, false))
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+
+	r, err := NewReloader(certFile, keyFile, caFile)
+	require.NoError(t, err)
+	srv := startServer(t, r.ServerConfig(true))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	alice := newCert(t, "alice", ca, false)
+	resp, err := client(roots, alice.keyPair(t)).Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "CN=alice", string(body))
+
+	_, err = client(roots).Get(srv.URL)
+	assert.Error(t, err, "client without certificate must be rejected")
+
+	mallory := newCert(t, "mallory", otherCA, false)
+	_, err = client(roots, mallory.keyPair(t)).Get(srv.URL)
+	assert.Error(t, err, "certificate from unknown CA must be rejected")
+}
+
+func TestNewReloader_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewReloader(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"), "")
+	assert.Error(t, err)
+
+	ca := newCert(t, "test-ca", nil, true)
+	certFile, keyFile := writeCert(t, dir, newCert(t, "server", ca, false))
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a pem"), 0o600))
+	_, err = NewReloader(certFile, keyFile, caFile)
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+// Package errstatus maps service and storage errors to the statuses of the
+// JSON API, the web interface and the gRPC API, so that clients of all of
+// them see an error alike. Errors it does not know are reported as the
+// service being unavailable, which clients may retry.
+package errstatus
+
+import (
+	"errors"
+	"net/http"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type entry struct {
+	errs []error
+	http int
+	code codes.Code
+}
+
+var table = []entry{
+	{[]error{service.ErrInvalidEvent, service.ErrInvalidRange}, http.StatusBadRequest, codes.InvalidArgument},
+	{[]error{service.ErrUnknownTenant, service.ErrNotOwner}, http.StatusForbidden, codes.PermissionDenied},
+	{[]error{service.ErrQuotaExceeded}, http.StatusForbidden, codes.ResourceExhausted},
+	{[]error{service.ErrDailyQuotaExceeded}, http.StatusTooManyRequests, codes.ResourceExhausted},
+	{[]error{
+		storage.ErrNotFound, storage.ErrAttachmentNotFound, service.ErrAttachmentsDisabled,
+		storage.ErrTemplateNotFound, storage.ErrBookingLinkNotFound,
+		service.ErrNothingToUndo, service.ErrNothingToRedo,
+	}, http.StatusNotFound, codes.NotFound},
+	{[]error{service.ErrEventChanged, service.ErrSlotUnavailable}, http.StatusConflict, codes.FailedPrecondition},
+	{[]error{service.ErrSyncTokenExpired}, http.StatusGone, codes.FailedPrecondition},
+	{[]error{storage.ErrAttachmentTooLarge}, http.StatusRequestEntityTooLarge, codes.ResourceExhausted},
+	{[]error{service.ErrWatcherTooSlow}, http.StatusServiceUnavailable, codes.ResourceExhausted},
+}
+
+func lookup(err error) (entry, bool) {
+	for _, e := range table {
+		for _, target := range e.errs {
+			if errors.Is(err, target) {
+				return e, true
+			}
+		}
+	}
+	return entry{}, false
+}
+
+// HTTP returns the HTTP status of err.
+func HTTP(err error) int {
+	if e, ok := lookup(err); ok {
+		return e.http
+	}
+	if errors.As(err, new(*http.MaxBytesError)) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusServiceUnavailable
+}
+
+// GRPC returns err as a gRPC status error, or nil for nil.
+func GRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	code := codes.Unavailable
+	if e, ok := lookup(err); ok {
+		code = e.code
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,38 @@
+package errstatus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+)
+
+func TestStatuses(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		http int
+		code codes.Code
+	}{
+		{storage.ErrNotFound, http.StatusNotFound, codes.NotFound},
+		{fmt.Errorf("%w: x", service.ErrInvalidRange), http.StatusBadRequest, codes.InvalidArgument},
+		{service.ErrNotOwner, http.StatusForbidden, codes.PermissionDenied},
+		{service.ErrDailyQuotaExceeded, http.StatusTooManyRequests, codes.ResourceExhausted},
+		{service.ErrEventChanged, http.StatusConflict, codes.FailedPrecondition},
+		{service.ErrNothingToRedo, http.StatusNotFound, codes.NotFound},
+		{service.ErrSyncTokenExpired, http.StatusGone, codes.FailedPrecondition},
+		{storage.ErrBookingLinkNotFound, http.StatusNotFound, codes.NotFound},
+		{&http.MaxBytesError{Limit: 1}, http.StatusRequestEntityTooLarge, codes.Unavailable},
+		{errors.New("disk full"), http.StatusServiceUnavailable, codes.Unavailable},
+	} {
+		assert.Equal(t, tc.http, HTTP(tc.err), tc.err.Error())
+		assert.Equal(t, tc.code, status.Code(GRPC(tc.err)), tc.err.Error())
+	}
+	assert.NoError(t, GRPC(nil))
+}
@@ -0,0 +1,154 @@
+// Package graceful stops the calendar server without dropping work: it
+// tracks readiness, open connections and streams while draining, and hands
+// the listening sockets over to a new process on restart.
+package graceful
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Drainer tracks whether the server takes new work and which connections
+// and long-lived streams are open, so that a shutdown can end the streams
+// instead of waiting for them until its timeout.
+type Drainer struct {
+	draining atomic.Bool
+	conns    atomic.Int64
+
+	mu      sync.Mutex
+	closing bool
+	streams map[*stream]struct{}
+	// idle is closed when the last stream ends.
+	idle chan struct{}
+}
+
+type stream struct {
+	cancel context.CancelFunc
+	closed atomic.Bool
+}
+
+// Stats are the connections and streams open now.
+type Stats struct {
+	Ready       bool  `json:"ready"`
+	Connections int64 `json:"connections"`
+	Streams     int   `json:"streams"`
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{streams: make(map[*stream]struct{})}
+}
+
+// Ready reports whether the server takes new work, for readiness probes.
+func (d *Drainer) Ready() bool {
+	return !d.draining.Load()
+}
+
+// Drain marks the server as not ready, so that load balancers stop sending
+// it requests before it stops.
+func (d *Drainer) Drain() {
+	d.draining.Store(true)
+}
+
+func (d *Drainer) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return Stats{Ready: d.Ready(), Connections: d.conns.Load(), Streams: len(d.streams)}
+}
+
+// ConnState counts the open HTTP connections; use it as
+// http.Server.ConnState. Hijacked connections are no longer counted.
+func (d *Drainer) ConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		d.conns.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		d.conns.Add(-1)
+	}
+}
+
+// Track registers a long-lived request, such as a server-sent event stream,
+// until done is called. Its context is canceled by CloseStreams, and closed
+// then reports true; streams started while closing are canceled right away.
+func (d *Drainer) Track(ctx context.Context) (_ context.Context, done func(), closed func() bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	st := &stream{cancel: cancel}
+
+	d.mu.Lock()
+	if len(d.streams) == 0 {
+		d.idle = make(chan struct{})
+	}
+	d.streams[st] = struct{}{}
+	if d.closing {
+		st.closed.Store(true)
+		cancel()
+	}
+	d.mu.Unlock()
+
+	done = func() {
+		cancel()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if _, ok := d.streams[st]; !ok {
+			return
+		}
+		delete(d.streams, st)
+		if len(d.streams) == 0 {
+			close(d.idle)
+		}
+	}
+	return ctx, done, st.closed.Load
+}
+
+// StreamInterceptor tracks gRPC streams. Those ended by CloseStreams fail
+// with Unavailable so that clients reconnect.
+func (d *Drainer) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, done, closed := d.Track(ss.Context())
+		defer done()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		if closed() {
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+		return err
+	}
+}
+
+// CloseStreams cancels the tracked streams, and those started later, and
+// waits for them to end or ctx to be done.
+func (d *Drainer) CloseStreams(ctx context.Context) error {
+	d.mu.Lock()
+	d.closing = true
+	for st := range d.streams {
+		st.closed.Store(true)
+		st.cancel()
+	}
+	idle := d.idle
+	n := len(d.streams)
+	d.mu.Unlock()
+
+	if n == 0 {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
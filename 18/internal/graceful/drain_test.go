@@ -0,0 +1,100 @@
+package graceful
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDrainer_Readiness(t *testing.T) {
+	d := NewDrainer()
+	assert.True(t, d.Ready())
+	d.Drain()
+	assert.False(t, d.Ready())
+	assert.False(t, d.Stats().Ready)
+}
+
+func TestDrainer_CountsConnections(t *testing.T) {
+	d := NewDrainer()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = d.ConnState
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	// The connection is kept alive for the next request.
+	assert.Equal(t, int64(1), d.Stats().Connections)
+
+	srv.CloseClientConnections()
+	assert.Eventually(t, func() bool { return d.Stats().Connections == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestDrainer_CloseStreams(t *testing.T) {
+	d := NewDrainer()
+	ctx, done, closed := d.Track(context.Background())
+	assert.Equal(t, 1, d.Stats().Streams)
+
+	ended := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		done()
+		close(ended)
+	}()
+	require.NoError(t, d.CloseStreams(context.Background()))
+	<-ended
+	assert.True(t, closed())
+	assert.Equal(t, 0, d.Stats().Streams)
+
+	// Streams started while closing end right away.
+	ctx, done, _ = d.Track(context.Background())
+	assert.Error(t, ctx.Err())
+	done()
+
+	d = NewDrainer()
+	ctx, done, closed = d.Track(context.Background())
+	assert.NoError(t, ctx.Err())
+	assert.False(t, closed())
+
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// The stream is canceled but not done yet.
+	assert.ErrorIs(t, d.CloseStreams(timeout), context.DeadlineExceeded)
+	done()
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context { return s.ctx }
+
+func TestDrainer_StreamInterceptor(t *testing.T) {
+	d := NewDrainer()
+	started := make(chan struct{})
+	handler := func(_ any, ss grpc.ServerStream) error {
+		close(started)
+		<-ss.Context().Done()
+		return nil
+	}
+
+	res := make(chan error)
+	go func() {
+		res <- d.StreamInterceptor()(nil, fakeStream{ctx: context.Background()}, &grpc.StreamServerInfo{}, handler)
+	}()
+	<-started
+	require.NoError(t, d.CloseStreams(context.Background()))
+	assert.Equal(t, codes.Unavailable, status.Code(<-res))
+}
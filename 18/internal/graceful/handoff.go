@@ -0,0 +1,189 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// envListeners names the sockets a restarted process inherits, in the
+// order of its extra files starting at descriptor 3. The last one is the
+// pipe on which it reports being ready.
+const envListeners = "CALENDAR_LISTENERS"
+
+const readyName = "ready"
+
+var inherited struct {
+	once  sync.Once
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+// take returns the inherited file named name, once.
+func take(name string) *os.File {
+	inherited.once.Do(func() {
+		inherited.files = make(map[string]*os.File)
+		names := os.Getenv(envListeners)
+		if names == "" {
+			return
+		}
+		os.Unsetenv(envListeners)
+		for i, n := range strings.Split(names, ",") {
+			inherited.files[n] = os.NewFile(uintptr(3+i), n)
+		}
+	})
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	f := inherited.files[name]
+	delete(inherited.files, name)
+	return f
+}
+
+// Listen returns the TCP listener named name inherited from the process
+// that restarted this one, or a new one on addr. An inherited listener on
+// another port is closed, so that a changed port takes effect.
+func Listen(name, addr string) (net.Listener, error) {
+	if f := take(name); f != nil {
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherit %s listener: %w", name, err)
+		}
+		if _, port, _ := net.SplitHostPort(addr); port == portOf(l.Addr()) {
+			return l, nil
+		}
+		l.Close()
+	}
+	return net.Listen("tcp", addr)
+}
+
+func portOf(addr net.Addr) string {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return strconv.Itoa(a.Port)
+	}
+	return ""
+}
+
+// Ready tells the process that restarted this one that it serves, so that
+// the old one can exit, and closes the inherited sockets it did not use.
+// Without such a parent it does nothing.
+func Ready() error {
+	f := take(readyName)
+	inherited.mu.Lock()
+	for name, unused := range inherited.files {
+		unused.Close()
+		delete(inherited.files, name)
+	}
+	inherited.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	defer f.Close()
+	_, err := f.Write([]byte(readyName + "\n"))
+	return err
+}
+
+// Sockets are duplicates of listening sockets, to pass to a new process.
+// Both processes accept connections on them until the old one closes its
+// listeners, so none is refused while the server restarts.
+type Sockets struct {
+	names []string
+	files []*os.File
+}
+
+// Dup duplicates the sockets of the TCP listeners by name.
+func Dup(listeners map[string]net.Listener) (*Sockets, error) {
+	s := &Sockets{}
+	for name := range listeners {
+		s.names = append(s.names, name)
+	}
+	sort.Strings(s.names)
+	for _, name := range s.names {
+		l, ok := listeners[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			s.Close()
+			return nil, fmt.Errorf("%s listener has no file", name)
+		}
+		f, err := l.File()
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("duplicate %s listener: %w", name, err)
+		}
+		s.files = append(s.files, f)
+	}
+	return s, nil
+}
+
+// Handoff starts the program again with the same arguments and
+// environment, passing it the sockets, and waits until it calls Ready. The
+// caller keeps serving meanwhile and stops once it returns nil. It fails if
+// the new process exits first or ctx is done, killing it.
+func (s *Sockets) Handoff(ctx context.Context) error {
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return s.handoff(ctx, cmd)
+}
+
+func (s *Sockets) handoff(ctx context.Context, cmd *exec.Cmd) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, envListeners+"="+strings.Join(append(s.names[:len(s.names):len(s.names)], readyName), ","))
+	cmd.ExtraFiles = append(s.files[:len(s.files):len(s.files)], w)
+	err = cmd.Start()
+	w.Close()
+	// Start passes the sockets by descriptor, which puts them in blocking
+	// mode. The mode is shared with the listeners this process still
+	// serves on, whose Close would then wait for a blocked Accept.
+	for _, f := range s.files {
+		err = errors.Join(err, setNonblock(f))
+	}
+	if err != nil {
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+		return fmt.Errorf("start new process: %w", err)
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		// The pipe is closed without data if the process exits first.
+		_, err := r.Read(make([]byte, 1))
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+		if err == nil {
+			return nil
+		}
+		err = errors.New("new process exited before it was ready")
+	case <-ctx.Done():
+		err = fmt.Errorf("new process not ready: %w", ctx.Err())
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	return err
+}
+
+func (s *Sockets) Close() error {
+	var errs []error
+	for _, f := range s.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
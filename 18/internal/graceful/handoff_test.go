@@ -0,0 +1,131 @@
+package graceful
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envChild makes the test binary act as the restarted process serving on
+// the inherited listener at the address it holds.
+const envChild = "GRACEFUL_TEST_CHILD"
+
+func TestMain(m *testing.M) {
+	if addr := os.Getenv(envChild); addr != "" {
+		runChild(addr)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func runChild(addr string) {
+	if os.Getenv("GRACEFUL_TEST_FAIL") != "" {
+		os.Exit(1)
+	}
+	l, err := Listen("http", addr)
+	if err != nil {
+		os.Exit(1)
+	}
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "child")
+	}))
+	if err := Ready(); err != nil {
+		os.Exit(1)
+	}
+	time.Sleep(time.Minute)
+}
+
+func get(addr string) (string, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + addr)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func childCmd(addr string, env ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(append(os.Environ(), envChild+"="+addr), env...)
+	return cmd
+}
+
+func TestHandoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	sockets, err := Dup(map[string]net.Listener{"http": l})
+	require.NoError(t, err)
+	defer sockets.Close()
+	require.NoError(t, l.Close())
+
+	// Connections made before the new process serves wait in the backlog.
+	res := make(chan string)
+	go func() {
+		body, err := get(addr)
+		assert.NoError(t, err)
+		res <- body
+	}()
+
+	cmd := childCmd(addr)
+	require.NoError(t, sockets.handoff(context.Background(), cmd))
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	assert.Equal(t, "child", <-res)
+	body, err := get(addr)
+	require.NoError(t, err)
+	assert.Equal(t, "child", body)
+}
+
+func TestHandoff_FailedChildKeepsServing(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	addr := l.Addr().String()
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "parent")
+	}))
+	sockets, err := Dup(map[string]net.Listener{"http": l})
+	require.NoError(t, err)
+	defer sockets.Close()
+
+	err = sockets.handoff(context.Background(), childCmd(addr, "GRACEFUL_TEST_FAIL=1"))
+	assert.ErrorContains(t, err, "exited before it was ready")
+
+	body, err := get(addr)
+	require.NoError(t, err)
+	assert.Equal(t, "parent", body)
+}
+
+func TestHandoff_Timeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	sockets, err := Dup(map[string]net.Listener{"http": l})
+	require.NoError(t, err)
+	defer sockets.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = sockets.handoff(ctx, exec.Command("sleep", "10"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestListen_WithoutParent(t *testing.T) {
+	l, err := Listen("http", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	assert.NoError(t, Ready())
+}
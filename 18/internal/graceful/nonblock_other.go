@@ -0,0 +1,10 @@
+//go:build !unix
+
+package graceful
+
+import "os"
+
+// setNonblock does nothing where sockets are not inherited by descriptor.
+func setNonblock(*os.File) error {
+	return nil
+}
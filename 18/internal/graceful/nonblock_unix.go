@@ -0,0 +1,21 @@
+//go:build unix
+
+package graceful
+
+import (
+	"os"
+	"syscall"
+)
+
+// setNonblock puts f back into non-blocking mode, which f.Fd() clears.
+func setNonblock(f *os.File) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := conn.Control(func(fd uintptr) { serr = syscall.SetNonblock(int(fd), true) }); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,82 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type userIDKey struct{}
+
+// UnaryIdentity resolves the verified client certificate subject of a call
+// to a user ID using users, like middleware.ClientCertIdentity does for
+// HTTP. Calls with a verified certificate that is not in users are rejected;
+// calls without a client certificate pass through without an identity.
+func UnaryIdentity(users map[string]int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := withIdentity(ctx, users)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamIdentity is UnaryIdentity for streams.
+func StreamIdentity(users map[string]int) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withIdentity(ss.Context(), users)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func withIdentity(ctx context.Context, users map[string]int) (context.Context, error) {
+	subject, ok := clientSubject(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	userID, ok := users[subject]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "unknown client certificate")
+	}
+	return context.WithValue(ctx, userIDKey{}, userID), nil
+}
+
+// clientSubject returns the subject of the verified client certificate of
+// the call, if any.
+func clientSubject(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return "", false
+	}
+	return info.State.VerifiedChains[0][0].Subject.String(), true
+}
+
+// authorized rejects calls made on behalf of another user when the client
+// identity is known from its certificate.
+func authorized(ctx context.Context, userID int) error {
+	if id, ok := ctx.Value(userIDKey{}).(int); ok && id != userID {
+		return status.Error(codes.PermissionDenied, "user_id does not match client certificate")
+	}
+	return nil
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
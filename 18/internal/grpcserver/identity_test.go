@@ -0,0 +1,148 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/calendarpb"
+	"wb_l12/18/pkg/storage"
+)
+
+// testCA issues the server and client certificates of a mutual TLS test.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// dialTLS serves cal over mutual TLS with opts and returns a dial function
+// for clients with the certificate of cn.
+func dialTLS(t *testing.T, cal calendarpb.CalendarServiceServer, opts ...grpc.ServerOption) func(cn string) calendarpb.CalendarServiceClient {
+	t.Helper()
+	ca := newTestCA(t)
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "bufnet", x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))...)
+	calendarpb.RegisterCalendarServiceServer(srv, cal)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return func(cn string) calendarpb.CalendarServiceClient {
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				RootCAs:      ca.pool,
+				Certificates: []tls.Certificate{ca.issue(t, cn, x509.ExtKeyUsageClientAuth)},
+			})),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return calendarpb.NewCalendarServiceClient(conn)
+	}
+}
+
+func TestServer_ClientCertIdentity(t *testing.T) {
+	svc := service.NewService(storage.NewInMemoryStorage())
+	users := map[string]int{"CN=alice": 1, "CN=bob": 2}
+	dial := dialTLS(t, NewServer(svc, nil),
+		grpc.ChainUnaryInterceptor(UnaryIdentity(users)),
+		grpc.ChainStreamInterceptor(StreamIdentity(users)))
+	alice, bob, mallory := dial("alice"), dial("bob"), dial("mallory")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created, err := alice.CreateEvent(ctx, &calendarpb.CreateEventRequest{UserId: 1, Date: "2024-01-10", Title: "alice's"})
+	require.NoError(t, err)
+	id := created.GetId()
+
+	_, err = bob.CreateEvent(ctx, &calendarpb.CreateEventRequest{UserId: 1, Date: "2024-01-10", Title: "for alice"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = bob.EventsForDay(ctx, &calendarpb.EventsRequest{UserId: 1, Date: "2024-01-10"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	// bob takes alice's event over by naming himself as the new owner.
+	_, err = bob.UpdateEvent(ctx, &calendarpb.UpdateEventRequest{Id: id, UserId: 2, Date: "2024-01-10", Title: "bob's"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = bob.DeleteEvent(ctx, &calendarpb.DeleteEventRequest{Id: id})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = mallory.EventsForDay(ctx, &calendarpb.EventsRequest{UserId: 3, Date: "2024-01-10"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := bob.WatchEvents(ctx, &calendarpb.WatchEventsRequest{UserId: 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	event, err := svc.Event(int(id))
+	require.NoError(t, err)
+	assert.Equal(t, 1, event.UserID)
+	assert.Equal(t, "alice's", event.Title)
+
+	day, err := alice.EventsForDay(ctx, &calendarpb.EventsRequest{UserId: 1, Date: "2024-01-10"})
+	require.NoError(t, err)
+	assert.Len(t, day.GetEvents(), 1)
+	_, err = alice.DeleteEvent(ctx, &calendarpb.DeleteEventRequest{Id: id})
+	require.NoError(t, err)
+}
@@ -0,0 +1,286 @@
+package grpcserver
+
+import (
+	"context"
+	"time"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/internal/tenant"
+	"wb_l12/18/pkg/calendarpb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const dateLayout = "2006-01-02"
+
+type server struct {
+	calendarpb.UnimplementedCalendarServiceServer
+	services    service.Provider
+	certTenants map[string]string
+}
+
+// NewServer serves the calendar of services. certTenants pins clients
+// by certificate subject to a tenant, like middleware.Tenant does for HTTP.
+func NewServer(services service.Provider, certTenants map[string]string) calendarpb.CalendarServiceServer {
+	return &server{services: services, certTenants: certTenants}
+}
+
+// serviceFor returns the service of the tenant named in the x-tenant-id
+// metadata, or of the default tenant. A verified client certificate whose
+// subject is in certTenants pins the tenant, and metadata naming another
+// one is rejected. The service is bound to the user established by
+// UnaryIdentity or StreamIdentity, if any.
+func (s *server) serviceFor(ctx context.Context) (*service.Service, error) {
+	id := ""
+	if ids := metadata.ValueFromIncomingContext(ctx, tenant.Header); len(ids) > 0 {
+		if err := tenant.Validate(ids[0]); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		id = ids[0]
+	}
+	if subject, ok := clientSubject(ctx); ok {
+		if pinned, ok := s.certTenants[subject]; ok {
+			if id != "" && id != pinned {
+				return nil, status.Error(codes.PermissionDenied, "tenant does not match client certificate")
+			}
+			id = pinned
+		}
+	}
+	if id != "" {
+		ctx = tenant.NewContext(ctx, id)
+	}
+	if userID, ok := ctx.Value(userIDKey{}).(int); ok {
+		ctx = service.WithClient(ctx, userID)
+	}
+	svc, err := s.services.For(ctx)
+	if err != nil {
+		return nil, errstatus.GRPC(err)
+	}
+	return svc, nil
+}
+
+func (s *server) CreateEvent(ctx context.Context, req *calendarpb.CreateEventRequest) (*calendarpb.CreateEventResponse, error) {
+	date, err := parseDate(req.GetDate())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == 0 || req.GetTitle() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and title are required")
+	}
+
+	if err := authorized(ctx, int(req.GetUserId())); err != nil {
+		return nil, err
+	}
+	svc, err := s.serviceFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := svc.CreateEvent(model.Event{
+		UserID:          int(req.GetUserId()),
+		Date:            date,
+		Title:           req.GetTitle(),
+		StartTime:       req.GetStartTime(),
+		DurationMinutes: int(req.GetDurationMinutes()),
+		Description:     req.GetDescription(),
+		Location:        req.GetLocation(),
+		Category:        req.GetCategory(),
+		Color:           req.GetColor(),
+		Tags:            req.GetTags(),
+		Attendees:       req.GetAttendees(),
+		Reminders:       fromInt32s(req.GetReminders()),
+	})
+	if err != nil {
+		return nil, errstatus.GRPC(err)
+	}
+	return &calendarpb.CreateEventResponse{Id: int64(id)}, nil
+}
+
+func (s *server) UpdateEvent(ctx context.Context, req *calendarpb.UpdateEventRequest) (*calendarpb.UpdateEventResponse, error) {
+	date, err := parseDate(req.GetDate())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetId() == 0 || req.GetUserId() == 0 || req.GetTitle() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id, user_id and title are required")
+	}
+
+	svc, err := s.serviceFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = svc.UpdateEvent(model.Event{
+		ID:              int(req.GetId()),
+		UserID:          int(req.GetUserId()),
+		Date:            date,
+		Title:           req.GetTitle(),
+		StartTime:       req.GetStartTime(),
+		DurationMinutes: int(req.GetDurationMinutes()),
+		Description:     req.GetDescription(),
+		Location:        req.GetLocation(),
+		Category:        req.GetCategory(),
+		Color:           req.GetColor(),
+		Tags:            req.GetTags(),
+		Attendees:       req.GetAttendees(),
+		Reminders:       fromInt32s(req.GetReminders()),
+	})
+	if err != nil {
+		return nil, errstatus.GRPC(err)
+	}
+	return &calendarpb.UpdateEventResponse{}, nil
+}
+
+func (s *server) DeleteEvent(ctx context.Context, req *calendarpb.DeleteEventRequest) (*calendarpb.DeleteEventResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	svc, err := s.serviceFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.DeleteEvent(int(req.GetId())); err != nil {
+		return nil, errstatus.GRPC(err)
+	}
+	return &calendarpb.DeleteEventResponse{}, nil
+}
+
+func (s *server) EventsForDay(ctx context.Context, req *calendarpb.EventsRequest) (*calendarpb.EventsResponse, error) {
+	svc, err := s.serviceFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.events(ctx, req, svc.GetByDay)
+}
+
+func (s *server) EventsForWeek(ctx context.Context, req *calendarpb.EventsRequest) (*calendarpb.EventsResponse, error) {
+	svc, err := s.serviceFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.events(ctx, req, svc.GetByWeek)
+}
+
+func (s *server) EventsForMonth(ctx context.Context, req *calendarpb.EventsRequest) (*calendarpb.EventsResponse, error) {
+	svc, err := s.serviceFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.events(ctx, req, svc.GetByMonth)
+}
+
+func (s *server) events(ctx context.Context, req *calendarpb.EventsRequest, query func(int, time.Time, service.Filter) ([]model.Event, error)) (*calendarpb.EventsResponse, error) {
+	date, err := parseDate(req.GetDate())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if err := authorized(ctx, int(req.GetUserId())); err != nil {
+		return nil, err
+	}
+
+	events, err := query(int(req.GetUserId()), date, service.Filter{Tag: req.GetTag(), Category: req.GetCategory()})
+	if err != nil {
+		return nil, errstatus.GRPC(err)
+	}
+	resp := &calendarpb.EventsResponse{Events: make([]*calendarpb.Event, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toProto(e))
+	}
+	return resp, nil
+}
+
+func (s *server) WatchEvents(req *calendarpb.WatchEventsRequest, stream calendarpb.CalendarService_WatchEventsServer) error {
+	if req.GetUserId() == 0 {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if err := authorized(stream.Context(), int(req.GetUserId())); err != nil {
+		return err
+	}
+
+	svc, err := s.serviceFor(stream.Context())
+	if err != nil {
+		return err
+	}
+	w := svc.Watch(int(req.GetUserId()))
+	defer svc.Unwatch(w)
+	// Let the client know it will not miss changes made from now on.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case change, ok := <-w.Changes():
+			if !ok {
+				return errstatus.GRPC(w.Err())
+			}
+			err := stream.Send(&calendarpb.EventChange{
+				Type:  changeTypes[change.Type],
+				Event: toProto(change.Event),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+var changeTypes = map[service.ChangeType]calendarpb.EventChange_Type{
+	service.ChangeCreated: calendarpb.EventChange_TYPE_CREATED,
+	service.ChangeUpdated: calendarpb.EventChange_TYPE_UPDATED,
+	service.ChangeDeleted: calendarpb.EventChange_TYPE_DELETED,
+}
+
+func toProto(e model.Event) *calendarpb.Event {
+	return &calendarpb.Event{
+		Id:              int64(e.ID),
+		UserId:          int64(e.UserID),
+		Date:            e.Date.Format(dateLayout),
+		Title:           e.Title,
+		StartTime:       e.StartTime,
+		DurationMinutes: int32(e.DurationMinutes),
+		Description:     e.Description,
+		Location:        e.Location,
+		Category:        e.Category,
+		Color:           e.Color,
+		Tags:            e.Tags,
+		Attendees:       e.Attendees,
+		Reminders:       toInt32s(e.Reminders),
+	}
+}
+
+func fromInt32s(in []int32) []int {
+	if in == nil {
+		return nil
+	}
+	out := make([]int, len(in))
+	for i, v := range in {
+		out[i] = int(v)
+	}
+	return out
+}
+
+func toInt32s(in []int) []int32 {
+	if in == nil {
+		return nil
+	}
+	out := make([]int32, len(in))
+	for i, v := range in {
+		out[i] = int32(v)
+	}
+	return out
+}
+
+func parseDate(date string) (time.Time, error) {
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return time.Time{}, status.Error(codes.InvalidArgument, "invalid date format, use YYYY-MM-DD")
+	}
+	return t, nil
+}
@@ -0,0 +1,171 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/calendarpb"
+	"wb_l12/18/pkg/storage"
+)
+
+func newTestClient(t *testing.T) (calendarpb.CalendarServiceClient, *service.Service) {
+	t.Helper()
+	svc := service.NewService(storage.NewInMemoryStorage())
+	return dial(t, svc), svc
+}
+
+func dial(t *testing.T, services service.Provider) calendarpb.CalendarServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	calendarpb.RegisterCalendarServiceServer(srv, NewServer(services, nil))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return calendarpb.NewCalendarServiceClient(conn)
+}
+
+func TestServer_CRUDAndQueries(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	created, err := client.CreateEvent(ctx, &calendarpb.CreateEventRequest{UserId: 1, Date: "2023-12-26", Title: "Meeting"})
+	require.NoError(t, err)
+	_, err = client.CreateEvent(ctx, &calendarpb.CreateEventRequest{UserId: 1, Date: "2023-12-31", Title: "Party"})
+	require.NoError(t, err)
+
+	week, err := client.EventsForWeek(ctx, &calendarpb.EventsRequest{UserId: 1, Date: "2023-12-27"})
+	require.NoError(t, err)
+	assert.Len(t, week.GetEvents(), 2)
+
+	_, err = client.UpdateEvent(ctx, &calendarpb.UpdateEventRequest{Id: created.GetId(), UserId: 1, Date: "2023-12-26", Title: "Standup"})
+	require.NoError(t, err)
+	day, err := client.EventsForDay(ctx, &calendarpb.EventsRequest{UserId: 1, Date: "2023-12-26"})
+	require.NoError(t, err)
+	require.Len(t, day.GetEvents(), 1)
+	assert.Equal(t, "Standup", day.GetEvents()[0].GetTitle())
+	assert.Equal(t, "2023-12-26", day.GetEvents()[0].GetDate())
+
+	_, err = client.DeleteEvent(ctx, &calendarpb.DeleteEventRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	month, err := client.EventsForMonth(ctx, &calendarpb.EventsRequest{UserId: 1, Date: "2023-12-01"})
+	require.NoError(t, err)
+	assert.Len(t, month.GetEvents(), 1)
+}
+
+func TestServer_Errors(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.DeleteEvent(ctx, &calendarpb.DeleteEventRequest{Id: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.CreateEvent(ctx, &calendarpb.CreateEventRequest{UserId: 1, Date: "26.12.2023", Title: "x"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.EventsForDay(ctx, &calendarpb.EventsRequest{Date: "2023-12-26"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_WatchEvents(t *testing.T) {
+	client, svc := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEvents(ctx, &calendarpb.WatchEventsRequest{UserId: 1})
+	require.NoError(t, err)
+	// Headers are sent once the watcher is registered.
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	id, err := svc.CreateEvent(model.Event{UserID: 1, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Title: "New year"})
+	require.NoError(t, err)
+	require.NoError(t, svc.DeleteEvent(id))
+
+	change, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, calendarpb.EventChange_TYPE_CREATED, change.GetType())
+	assert.Equal(t, "New year", change.GetEvent().GetTitle())
+
+	change, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, calendarpb.EventChange_TYPE_DELETED, change.GetType())
+	assert.Equal(t, int64(id), change.GetEvent().GetId())
+}
+
+func TestServer_TenantFromMetadata(t *testing.T) {
+	client := dial(t, service.NewTenants(map[string]*service.Service{
+		"acme":   service.NewService(storage.NewInMemoryStorage()),
+		"globex": service.NewService(storage.NewInMemoryStorage()),
+	}))
+	acme := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "acme")
+	globex := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "globex")
+
+	_, err := client.CreateEvent(acme, &calendarpb.CreateEventRequest{UserId: 1, Date: "2024-01-10", Title: "Acme only"})
+	require.NoError(t, err)
+	day, err := client.EventsForDay(globex, &calendarpb.EventsRequest{UserId: 1, Date: "2024-01-10"})
+	require.NoError(t, err)
+	assert.Empty(t, day.GetEvents())
+
+	_, err = client.EventsForDay(context.Background(), &calendarpb.EventsRequest{UserId: 1, Date: "2024-01-10"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	bad := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "Not/Valid")
+	_, err = client.DeleteEvent(bad, &calendarpb.DeleteEventRequest{Id: 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_TenantPinnedByClientCert(t *testing.T) {
+	acme := service.NewService(storage.NewInMemoryStorage())
+	globex := service.NewService(storage.NewInMemoryStorage())
+	date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	_, err := acme.CreateEvent(model.Event{UserID: 1, Date: date, Title: "Acme only"})
+	require.NoError(t, err)
+	_, err = globex.CreateEvent(model.Event{UserID: 1, Date: date, Title: "Globex only"})
+	require.NoError(t, err)
+	dial := dialTLS(t, NewServer(service.NewTenants(map[string]*service.Service{"acme": acme, "globex": globex}),
+		map[string]string{"CN=acme-app": "acme"}))
+	pinned, free := dial("acme-app"), dial("tool")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	toGlobex := metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "globex")
+
+	_, err = pinned.EventsForDay(toGlobex, &calendarpb.EventsRequest{UserId: 1, Date: "2024-01-10"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = pinned.CreateEvent(toGlobex, &calendarpb.CreateEventRequest{UserId: 1, Date: "2024-01-10", Title: "Planted"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	stream, err := pinned.WatchEvents(toGlobex, &calendarpb.WatchEventsRequest{UserId: 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	day, err := pinned.EventsForDay(ctx, &calendarpb.EventsRequest{UserId: 1, Date: "2024-01-10"})
+	require.NoError(t, err)
+	require.Len(t, day.GetEvents(), 1)
+	assert.Equal(t, "Acme only", day.GetEvents()[0].GetTitle())
+
+	day, err = free.EventsForDay(toGlobex, &calendarpb.EventsRequest{UserId: 1, Date: "2024-01-10"})
+	require.NoError(t, err)
+	require.Len(t, day.GetEvents(), 1)
+	assert.Equal(t, "Globex only", day.GetEvents()[0].GetTitle())
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+	"wb_l12/18/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type adminHandler struct {
+	services service.Provider
+}
+
+func NewAdminHandler(services service.Provider) *adminHandler {
+	return &adminHandler{services: services}
+}
+
+func (h *adminHandler) RegisterRoutes(r gin.IRoutes) {
+	r.GET("/admin/stats", h.Stats)
+}
+
+func (h *adminHandler) Stats(c *gin.Context) {
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": svc.Stats()})
+}
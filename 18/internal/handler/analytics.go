@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Analytics reports the user's events per period, busiest days, tags and
+// categories from one date to another, both included, as JSON or CSV.
+func (h *eventHandler) Analytics(c *gin.Context) {
+	var req struct {
+		UserID int    `form:"user_id" binding:"required"`
+		From   string `form:"from" binding:"required"`
+		To     string `form:"to" binding:"required"`
+		Period string `form:"period" binding:"omitempty,oneof=day week month"`
+		Format string `form:"format" binding:"omitempty,oneof=json csv"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	from, err := parsedDate(req.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	to, err := parsedDate(req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+	if req.Period == "" {
+		req.Period = service.PeriodWeek
+	}
+
+	rep, err := svc.Report(req.UserID, from, to, req.Period)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	if req.Format != "csv" {
+		c.JSON(http.StatusOK, gin.H{"result": rep})
+		return
+	}
+	name := fmt.Sprintf("report_%d_%s_%s.csv", req.UserID, req.From, req.To)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := writeReportCSV(csv.NewWriter(c.Writer), rep); err != nil {
+		c.Error(err)
+	}
+}
+
+// writeReportCSV writes rep as rows of section, key, events and minutes.
+// Keys are dates, except for tags and categories.
+func writeReportCSV(w *csv.Writer, rep service.Report) error {
+	row := func(section, key string, events, minutes int) {
+		w.Write([]string{section, key, strconv.Itoa(events), strconv.Itoa(minutes)})
+	}
+	w.Write([]string{"section", "key", "events", "minutes"})
+	row("total", rep.From.Format(time.DateOnly)+"/"+rep.To.Format(time.DateOnly), rep.Events, rep.Minutes)
+	for _, p := range rep.Periods {
+		row(rep.Period, p.Start.Format(time.DateOnly), p.Events, p.Minutes)
+	}
+	for _, d := range rep.BusiestDays {
+		row("busiest_day", d.Date.Format(time.DateOnly), d.Events, d.Minutes)
+	}
+	groups := func(section string, totals []storage.GroupTotal) {
+		for _, t := range totals {
+			row(section, t.Key, t.Events, t.Minutes)
+		}
+	}
+	groups("tag", rep.Tags)
+	groups("category", rep.Categories)
+	w.Flush()
+	return w.Error()
+}
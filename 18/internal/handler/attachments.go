@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type attachmentHandler struct {
+	services service.Provider
+}
+
+func NewAttachmentHandler(services service.Provider) *attachmentHandler {
+	return &attachmentHandler{services: services}
+}
+
+// RegisterRoutes registers the attachment API on r; write middlewares run
+// for deletes. Uploads stream to the store instead of being buffered by
+// them, and uploading the same content again is harmless.
+func (h *attachmentHandler) RegisterRoutes(r gin.IRoutes, write ...gin.HandlerFunc) {
+	r.POST("/upload_attachment", h.Upload)
+	r.POST("/delete_attachment", withWrite(write, h.Delete)...)
+	r.GET("/attachments", h.List)
+	r.GET("/download_attachment", h.Download)
+}
+
+// Upload streams the "file" part of a multipart body to the store; the
+// event is given by the event_id query parameter.
+func (h *attachmentHandler) Upload(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Query("event_id"))
+	if err != nil || eventID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request, use multipart/form-data"})
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `invalid request, no "file" part`})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		a, err := svc.AddAttachment(eventID, part.FileName(), part)
+		if err != nil {
+			c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"result": a})
+		return
+	}
+}
+
+func (h *attachmentHandler) List(c *gin.Context) {
+	var req struct {
+		EventID int `form:"event_id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	list, err := svc.Attachments(req.EventID)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": list})
+}
+
+// Download sends the content as a file to save, never to render, with the
+// type sniffed on upload.
+func (h *attachmentHandler) Download(c *gin.Context) {
+	var req struct {
+		EventID int    `form:"event_id" binding:"required"`
+		ID      string `form:"id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	a, content, err := svc.OpenAttachment(req.EventID, req.ID)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, a.Size, a.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}),
+		"X-Content-Type-Options": "nosniff",
+		"ETag":                   `"` + a.ID + `"`,
+	})
+}
+
+func (h *attachmentHandler) Delete(c *gin.Context) {
+	var req struct {
+		EventID int    `json:"event_id" binding:"required"`
+		ID      string `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if err := svc.DeleteAttachment(req.EventID, req.ID); err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "successfully delete"})
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/holiday"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type bookingHandler struct {
+	services service.Provider
+}
+
+func NewBookingHandler(services service.Provider) *bookingHandler {
+	return &bookingHandler{services: services}
+}
+
+// RegisterRoutes registers the booking link API on r; write middlewares run
+// for the routes that change data. /booking_slots and /book are public:
+// the link token is all they need.
+func (h *bookingHandler) RegisterRoutes(r gin.IRoutes, write ...gin.HandlerFunc) {
+	r.POST("/create_booking_link", withWrite(write, h.CreateBookingLink)...)
+	r.GET("/booking_links", h.BookingLinks)
+	r.POST("/delete_booking_link", withWrite(write, h.DeleteBookingLink)...)
+	r.GET("/booking_slots", h.Slots)
+	r.POST("/book", withWrite(write, h.Book)...)
+}
+
+type bookingLink struct {
+	Token         string   `json:"token"`
+	UserID        int      `json:"user_id"`
+	Title         string   `json:"title"`
+	Weekdays      []string `json:"weekdays"`
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	SlotMinutes   int      `json:"slot_minutes"`
+	BufferMinutes int      `json:"buffer_minutes"`
+}
+
+func toBookingLink(l model.BookingLink) bookingLink {
+	days := make([]string, len(l.Weekdays))
+	for i, d := range l.Weekdays {
+		days[i] = strings.ToLower(d.String())
+	}
+	return bookingLink{
+		Token:         l.Token,
+		UserID:        l.UserID,
+		Title:         l.Title,
+		Weekdays:      days,
+		From:          l.From,
+		To:            l.To,
+		SlotMinutes:   l.SlotMinutes,
+		BufferMinutes: l.BufferMinutes,
+	}
+}
+
+func (h *bookingHandler) CreateBookingLink(c *gin.Context) {
+	var req struct {
+		UserID        int      `json:"user_id" binding:"required"`
+		Title         string   `json:"title" binding:"required"`
+		Weekdays      []string `json:"weekdays" binding:"required"`
+		From          string   `json:"from" binding:"required"`
+		To            string   `json:"to" binding:"required"`
+		SlotMinutes   int      `json:"slot_minutes" binding:"required"`
+		BufferMinutes int      `json:"buffer_minutes"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	weekdays := make([]time.Weekday, len(req.Weekdays))
+	for i, name := range req.Weekdays {
+		day, err := holiday.ParseWeekday(name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		weekdays[i] = day
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	link, err := svc.CreateBookingLink(model.BookingLink{
+		UserID:        req.UserID,
+		Title:         req.Title,
+		Weekdays:      weekdays,
+		From:          req.From,
+		To:            req.To,
+		SlotMinutes:   req.SlotMinutes,
+		BufferMinutes: req.BufferMinutes,
+	})
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": toBookingLink(link)})
+}
+
+func (h *bookingHandler) BookingLinks(c *gin.Context) {
+	var req struct {
+		UserID int `form:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	links, err := svc.BookingLinks(req.UserID)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	res := make([]bookingLink, len(links))
+	for i, l := range links {
+		res[i] = toBookingLink(l)
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (h *bookingHandler) DeleteBookingLink(c *gin.Context) {
+	var req struct {
+		UserID int    `json:"user_id" binding:"required"`
+		Token  string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	if err := svc.DeleteBookingLink(req.UserID, req.Token); err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "successfully delete"})
+}
+
+// Slots lists the open slots of a booking link from one date to another,
+// both included.
+func (h *bookingHandler) Slots(c *gin.Context) {
+	var req struct {
+		Token string `form:"token" binding:"required"`
+		From  string `form:"from" binding:"required"`
+		To    string `form:"to" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	from, err := parsedDate(req.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	to, err := parsedDate(req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+
+	slots, err := svc.OpenSlots(req.Token, from, to)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": slots})
+}
+
+// Book books an open slot of a booking link for a person without an
+// account.
+func (h *bookingHandler) Book(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+		Start string `json:"start" binding:"required"`
+		Name  string `json:"name" binding:"required"`
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	start, err := time.Parse(time.RFC3339, req.Start)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start, use RFC 3339 like 2024-03-04T09:30:00+03:00"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+
+	event, err := svc.Book(req.Token, start, req.Name, req.Email)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": event})
+}
@@ -3,17 +3,20 @@ package handler
 import (
 	"net/http"
 	"time"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/middleware"
+	"wb_l12/18/internal/model"
 	"wb_l12/18/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type eventHandler struct {
-	service *service.Service
+	services service.Provider
 }
 
-func NewEventHandler(service *service.Service) *eventHandler {
-	return &eventHandler{service: service}
+func NewEventHandler(services service.Provider) *eventHandler {
+	return &eventHandler{services: services}
 }
 
 func (h *eventHandler) CreateEvent(c *gin.Context) {
@@ -21,6 +24,7 @@ func (h *eventHandler) CreateEvent(c *gin.Context) {
 		UserID int    `json:"user_id" binding:"required"`
 		Date   string `json:"date" binding:"required"`
 		Title  string `json:"title" binding:"required"`
+		eventDetails
 	}
 	if err := c.ShouldBind(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
@@ -32,10 +36,17 @@ func (h *eventHandler) CreateEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
 		return
 	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
 
-	id, err := h.service.CreateEvent(req.UserID, parsedDate, req.Title)
+	id, err := svc.CreateEvent(req.event(0, req.UserID, parsedDate, req.Title))
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -48,6 +59,7 @@ func (h *eventHandler) UpdateEvent(c *gin.Context) {
 		UserID int    `json:"user_id" binding:"required"`
 		Date   string `json:"date" binding:"required"`
 		Title  string `json:"title" binding:"required"`
+		eventDetails
 	}
 
 	if err := c.ShouldBind(&req); err != nil {
@@ -60,9 +72,13 @@ func (h *eventHandler) UpdateEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
 		return
 	}
-	err = h.service.UpdateEvent(req.ID, req.UserID, parsedDate, req.Title)
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	err = svc.UpdateEvent(req.event(req.ID, req.UserID, parsedDate, req.Title))
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -78,9 +94,13 @@ func (h *eventHandler) DeleteEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
-	err := h.service.DeleteEvent(req.ID)
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	err := svc.DeleteEvent(req.ID)
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -89,8 +109,9 @@ func (h *eventHandler) DeleteEvent(c *gin.Context) {
 
 func (h *eventHandler) GetByDay(c *gin.Context) {
 	var req struct {
-		UserID int    `json:"user_id" binding:"required"`
-		Date   string `json:"date" binding:"required"`
+		UserID int    `json:"user_id" form:"user_id" binding:"required"`
+		Date   string `json:"date" form:"date" binding:"required"`
+		eventFilter
 	}
 
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -103,9 +124,16 @@ func (h *eventHandler) GetByDay(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
 		return
 	}
-	events, err := h.service.GetByDay(req.UserID, parsedDate)
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+	events, err := svc.GetByDay(req.UserID, parsedDate, req.filter())
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -114,8 +142,9 @@ func (h *eventHandler) GetByDay(c *gin.Context) {
 
 func (h *eventHandler) GetByWeek(c *gin.Context) {
 	var req struct {
-		UserID int    `json:"user_id" binding:"required"`
-		Date   string `json:"date" binding:"required"`
+		UserID int    `json:"user_id" form:"user_id" binding:"required"`
+		Date   string `json:"date" form:"date" binding:"required"`
+		eventFilter
 	}
 
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -128,9 +157,16 @@ func (h *eventHandler) GetByWeek(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
 		return
 	}
-	events, err := h.service.GetByWeek(req.UserID, parsedDate)
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+	events, err := svc.GetByWeek(req.UserID, parsedDate, req.filter())
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -139,8 +175,9 @@ func (h *eventHandler) GetByWeek(c *gin.Context) {
 
 func (h *eventHandler) GetByMonth(c *gin.Context) {
 	var req struct {
-		UserID int    `json:"user_id" binding:"required"`
-		Date   string `json:"date" binding:"required"`
+		UserID int    `json:"user_id" form:"user_id" binding:"required"`
+		Date   string `json:"date" form:"date" binding:"required"`
+		eventFilter
 	}
 
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -152,15 +189,190 @@ func (h *eventHandler) GetByMonth(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
 		return
 	}
-	events, err := h.service.GetByMonth(req.UserID, parsedDate)
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+	events, err := svc.GetByMonth(req.UserID, parsedDate, req.filter())
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"result": events})
 }
 
+// QuickAdd creates an event from a phrase like "Standup tomorrow at 10:00",
+// or only returns its interpretation when dry_run is set.
+func (h *eventHandler) QuickAdd(c *gin.Context) {
+	var req struct {
+		UserID int    `json:"user_id" binding:"required"`
+		Text   string `json:"text" binding:"required"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	res, err := svc.QuickAdd(req.UserID, req.Text, !req.DryRun)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+const defaultPerPage = 20
+
+func (h *eventHandler) SearchEvents(c *gin.Context) {
+	var req struct {
+		UserID  int    `form:"user_id" binding:"required"`
+		Query   string `form:"q" binding:"required"`
+		Page    int    `form:"page" binding:"omitempty,min=1"`
+		PerPage int    `form:"per_page" binding:"omitempty,min=1,max=100"`
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PerPage == 0 {
+		req.PerPage = defaultPerPage
+	}
+
+	events, total, err := svc.Search(req.UserID, req.Query, req.Page, req.PerPage)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": gin.H{
+		"events":   events,
+		"total":    total,
+		"page":     req.Page,
+		"per_page": req.PerPage,
+	}})
+}
+
+const defaultSyncLimit = 500
+
+// Sync returns the changes to the user's events since the token of the
+// previous sync.
+func (h *eventHandler) Sync(c *gin.Context) {
+	var req struct {
+		UserID int    `form:"user_id" binding:"required"`
+		Token  string `form:"token"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSyncLimit
+	}
+
+	res, err := svc.Sync(req.UserID, req.Token, req.Limit)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+// eventDetails are the optional fields of create and update requests.
+type eventDetails struct {
+	StartTime       string   `json:"start_time"`
+	DurationMinutes int      `json:"duration_minutes"`
+	Description     string   `json:"description"`
+	Location        string   `json:"location"`
+	Category        string   `json:"category"`
+	Color           string   `json:"color"`
+	Tags            []string `json:"tags"`
+	Attendees       []string `json:"attendees"`
+	Reminders       []int    `json:"reminders"`
+}
+
+func (d eventDetails) event(id, userID int, date time.Time, title string) model.Event {
+	return model.Event{
+		ID:              id,
+		UserID:          userID,
+		Date:            date,
+		Title:           title,
+		StartTime:       d.StartTime,
+		DurationMinutes: d.DurationMinutes,
+		Description:     d.Description,
+		Location:        d.Location,
+		Category:        d.Category,
+		Color:           d.Color,
+		Tags:            d.Tags,
+		Attendees:       d.Attendees,
+		Reminders:       d.Reminders,
+	}
+}
+
+type eventFilter struct {
+	Tag      string `form:"tag"`
+	Category string `form:"category"`
+}
+
+func (f eventFilter) filter() service.Filter {
+	return service.Filter{Tag: f.Tag, Category: f.Category}
+}
+
+// authorized rejects requests made on behalf of another user when the
+// client identity is known from its certificate.
+func authorized(c *gin.Context, userID int) bool {
+	if id, ok := middleware.UserID(c); ok && id != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user_id does not match client certificate"})
+		return false
+	}
+	return true
+}
+
 func parsedDate(date string) (time.Time, error) {
 	return time.Parse("2006-01-02", date)
 }
+
+// serviceFor returns the service of the tenant the request acts for, bound
+// to the client identity if it is known from its certificate.
+func serviceFor(c *gin.Context, p service.Provider) (*service.Service, bool) {
+	ctx := c.Request.Context()
+	if id, ok := middleware.UserID(c); ok {
+		ctx = service.WithClient(ctx, id)
+	}
+	svc, err := p.For(ctx)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return svc, true
+}
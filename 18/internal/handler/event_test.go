@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/middleware"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+)
+
+// certRouter serves the event API to clients alice (user 1) and bob (user 2).
+func certRouter(svc *service.Service) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ClientCertIdentity(map[string]int{"CN=alice": 1, "CN=bob": 2}))
+	NewEventHandler(svc).RegisterRoutes(router)
+	return router
+}
+
+func postAs(router *gin.Engine, cn, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateEvent_OnlyOwner(t *testing.T) {
+	svc := service.NewService(storage.NewInMemoryStorage())
+	date := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	id, err := svc.CreateEvent(model.Event{UserID: 1, Date: date, Title: "alice's"})
+	require.NoError(t, err)
+	router := certRouter(svc)
+
+	// bob takes alice's event over by naming himself as the new owner.
+	w := postAs(router, "bob", "/update_event", `{"id": 1, "user_id": 2, "date": "2024-05-15", "title": "bob's"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	event, err := svc.Event(id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, event.UserID)
+	assert.Equal(t, "alice's", event.Title)
+
+	w = postAs(router, "alice", "/update_event", `{"id": 1, "user_id": 1, "date": "2024-05-15", "title": "renamed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestDeleteEvent_OnlyOwner(t *testing.T) {
+	svc := service.NewService(storage.NewInMemoryStorage())
+	date := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	id, err := svc.CreateEvent(model.Event{UserID: 1, Date: date, Title: "alice's"})
+	require.NoError(t, err)
+	router := certRouter(svc)
+
+	w := postAs(router, "bob", "/delete_event", `{"id": 1}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	_, err = svc.Event(id)
+	require.NoError(t, err)
+
+	w = postAs(router, "alice", "/delete_event", `{"id": 1}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = svc.Event(id)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"wb_l12/18/internal/graceful"
+
+	"github.com/gin-gonic/gin"
+)
+
+type healthHandler struct {
+	drainer *graceful.Drainer
+}
+
+// NewHealthHandler serves the liveness and readiness probes of the server
+// drained by drainer.
+func NewHealthHandler(drainer *graceful.Drainer) *healthHandler {
+	return &healthHandler{drainer: drainer}
+}
+
+func (h *healthHandler) RegisterRoutes(r gin.IRoutes) {
+	r.GET("/healthz", h.Live)
+	r.GET("/readyz", h.Ready)
+}
+
+// Live answers while the process serves at all, draining or not.
+func (h *healthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"result": "ok"})
+}
+
+// Ready fails once the server drains before stopping, so that load
+// balancers send new requests elsewhere.
+func (h *healthHandler) Ready(c *gin.Context) {
+	st := h.drainer.Stats()
+	if !st.Ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is draining", "result": st})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": st})
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Undo reverts the user's latest change made through the API.
+func (h *eventHandler) Undo(c *gin.Context) {
+	h.replay(c, (*service.Service).Undo)
+}
+
+// Redo makes the user's latest undone change again.
+func (h *eventHandler) Redo(c *gin.Context) {
+	h.replay(c, (*service.Service).Redo)
+}
+
+func (h *eventHandler) replay(c *gin.Context, replay func(*service.Service, int) (service.Revision, error)) {
+	var req struct {
+		UserID int `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	rev, err := replay(svc, req.UserID)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": rev})
+}
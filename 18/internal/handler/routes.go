@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"wb_l12/18/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+const OpenAPIPath = "/openapi.yaml"
+
+// RegisterRoutes registers the event API on r. write middlewares run only
+// for the routes that change data.
+func (h *eventHandler) RegisterRoutes(r gin.IRoutes, write ...gin.HandlerFunc) {
+	r.POST("/create_event", withWrite(write, h.CreateEvent)...)
+	r.POST("/delete_event", withWrite(write, h.DeleteEvent)...)
+	r.POST("/update_event", withWrite(write, h.UpdateEvent)...)
+	r.POST("/quick_add", withWrite(write, h.QuickAdd)...)
+	r.POST("/undo", withWrite(write, h.Undo)...)
+	r.POST("/redo", withWrite(write, h.Redo)...)
+	r.GET("/events_for_day", h.GetByDay)
+	r.GET("/events_for_week", h.GetByWeek)
+	r.GET("/events_for_month", h.GetByMonth)
+	r.GET("/search_events", h.SearchEvents)
+	r.GET("/sync", h.Sync)
+	r.GET("/analytics", h.Analytics)
+	r.GET("/week_days", h.WeekDays)
+	r.GET("/business_days", h.BusinessDays)
+	r.GET("/user_settings", h.GetUserSettings)
+	r.POST("/user_settings", withWrite(write, h.SetUserSettings)...)
+	r.GET(OpenAPIPath, OpenAPI)
+}
+
+// withWrite runs the write middlewares before handler, on routes that
+// change data.
+func withWrite(write []gin.HandlerFunc, handler gin.HandlerFunc) []gin.HandlerFunc {
+	return append(write[:len(write):len(write)], handler)
+}
+
+func OpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml", api.OpenAPI)
+}
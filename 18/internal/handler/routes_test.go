@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"wb_l12/18/api"
+	"wb_l12/18/internal/graceful"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+)
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	svc := service.NewService(storage.NewInMemoryStorage())
+	NewEventHandler(svc).RegisterRoutes(router)
+	NewAttachmentHandler(svc).RegisterRoutes(router)
+	NewTemplateHandler(svc).RegisterRoutes(router)
+	NewBookingHandler(svc).RegisterRoutes(router)
+	NewAdminHandler(svc).RegisterRoutes(router)
+	NewHealthHandler(graceful.NewDrainer()).RegisterRoutes(router)
+	return router
+}
+
+func TestOpenAPI_DescribesEveryRoute(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]any `yaml:"paths"`
+	}
+	require.NoError(t, yaml.Unmarshal(api.OpenAPI, &spec))
+
+	routes := newRouter().Routes()
+	require.NotEmpty(t, routes)
+	for _, r := range routes {
+		_, ok := spec.Paths[r.Path][strings.ToLower(r.Method)]
+		assert.True(t, ok, "%s %s is missing from openapi.yaml", r.Method, r.Path)
+	}
+
+	documented := 0
+	for _, ops := range spec.Paths {
+		documented += len(ops)
+	}
+	assert.Equal(t, len(routes), documented, "openapi.yaml documents routes that are not registered")
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, api.OpenAPI, w.Body.Bytes())
+}
+
+func TestHealth_ReadinessFlipsWhenDraining(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	drainer := graceful.NewDrainer()
+	NewHealthHandler(drainer).RegisterRoutes(router)
+
+	probe := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, probe("/readyz"))
+	drainer.Drain()
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/readyz"))
+	assert.Equal(t, http.StatusOK, probe("/healthz"))
+}
+
+func TestAttachmentRoutes_UploadsSkipWriteMiddlewares(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	buffered := func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusTeapot)
+	}
+	NewAttachmentHandler(service.NewService(storage.NewInMemoryStorage())).RegisterRoutes(router, buffered)
+
+	for path, want := range map[string]bool{"/upload_attachment": false, "/delete_attachment": true} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		assert.Equal(t, want, w.Code == http.StatusTeapot, path)
+	}
+}
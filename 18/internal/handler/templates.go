@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"net/http"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type templateHandler struct {
+	services service.Provider
+}
+
+func NewTemplateHandler(services service.Provider) *templateHandler {
+	return &templateHandler{services: services}
+}
+
+// RegisterRoutes registers the template and duplication API on r; write
+// middlewares run for the routes that change data.
+func (h *templateHandler) RegisterRoutes(r gin.IRoutes, write ...gin.HandlerFunc) {
+	r.POST("/create_template", withWrite(write, h.CreateTemplate)...)
+	r.POST("/update_template", withWrite(write, h.UpdateTemplate)...)
+	r.POST("/delete_template", withWrite(write, h.DeleteTemplate)...)
+	r.GET("/templates", h.Templates)
+	r.POST("/instantiate_template", withWrite(write, h.InstantiateTemplate)...)
+	r.POST("/duplicate_event", withWrite(write, h.DuplicateEvent)...)
+	r.POST("/shift_events", withWrite(write, h.ShiftEvents)...)
+}
+
+func (h *templateHandler) CreateTemplate(c *gin.Context) {
+	var req struct {
+		UserID int    `json:"user_id" binding:"required"`
+		Name   string `json:"name" binding:"required"`
+		Title  string `json:"title" binding:"required"`
+		eventDetails
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	id, err := svc.CreateTemplate(req.template(0, req.UserID, req.Name, req.Title))
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": id})
+}
+
+func (h *templateHandler) UpdateTemplate(c *gin.Context) {
+	var req struct {
+		ID     int    `json:"id" binding:"required"`
+		UserID int    `json:"user_id" binding:"required"`
+		Name   string `json:"name" binding:"required"`
+		Title  string `json:"title" binding:"required"`
+		eventDetails
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	if err := svc.UpdateTemplate(req.template(req.ID, req.UserID, req.Name, req.Title)); err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "successfully update"})
+}
+
+func (h *templateHandler) DeleteTemplate(c *gin.Context) {
+	var req struct {
+		ID     int `json:"id" binding:"required"`
+		UserID int `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	if err := svc.DeleteTemplate(req.UserID, req.ID); err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": "successfully delete"})
+}
+
+func (h *templateHandler) Templates(c *gin.Context) {
+	var req struct {
+		UserID int `form:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	templates, err := svc.Templates(req.UserID)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": templates})
+}
+
+// InstantiateTemplate creates an event from a template on the given date.
+func (h *templateHandler) InstantiateTemplate(c *gin.Context) {
+	var req struct {
+		UserID     int    `json:"user_id" binding:"required"`
+		TemplateID int    `json:"template_id" binding:"required"`
+		Date       string `json:"date" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	parsedDate, err := parsedDate(req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	id, err := svc.InstantiateTemplate(req.UserID, req.TemplateID, parsedDate)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": id})
+}
+
+// DuplicateEvent copies an event to another date.
+func (h *templateHandler) DuplicateEvent(c *gin.Context) {
+	var req struct {
+		UserID int    `json:"user_id" binding:"required"`
+		ID     int    `json:"id" binding:"required"`
+		Date   string `json:"date" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	parsedDate, err := parsedDate(req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	id, err := svc.DuplicateEvent(req.UserID, req.ID, parsedDate)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": id})
+}
+
+// ShiftEvents moves a set of events by a number of days, all or none.
+func (h *templateHandler) ShiftEvents(c *gin.Context) {
+	var req struct {
+		UserID int   `json:"user_id" binding:"required"`
+		IDs    []int `json:"ids" binding:"required"`
+		Days   int   `json:"days" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	events, err := svc.ShiftEvents(req.UserID, req.IDs, req.Days)
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": events})
+}
+
+func (d eventDetails) template(id, userID int, name, title string) model.Template {
+	return model.Template{
+		ID:              id,
+		UserID:          userID,
+		Name:            name,
+		Title:           title,
+		StartTime:       d.StartTime,
+		DurationMinutes: d.DurationMinutes,
+		Description:     d.Description,
+		Location:        d.Location,
+		Category:        d.Category,
+		Color:           d.Color,
+		Tags:            d.Tags,
+		Attendees:       d.Attendees,
+		Reminders:       d.Reminders,
+	}
+}
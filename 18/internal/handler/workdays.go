@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/holiday"
+	"wb_l12/18/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type userSettings struct {
+	Region       string `json:"region"`
+	FirstWeekday string `json:"first_weekday"`
+	TimeZone     string `json:"time_zone"`
+}
+
+func toUserSettings(st model.UserSettings) userSettings {
+	return userSettings{Region: st.Region, FirstWeekday: strings.ToLower(st.FirstWeekday.String()), TimeZone: st.TimeZone}
+}
+
+func (h *eventHandler) GetUserSettings(c *gin.Context) {
+	var req struct {
+		UserID int `form:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": toUserSettings(svc.UserSettings(req.UserID))})
+}
+
+func (h *eventHandler) SetUserSettings(c *gin.Context) {
+	var req struct {
+		UserID int `json:"user_id" binding:"required"`
+		userSettings
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	st := model.UserSettings{Region: req.Region, FirstWeekday: time.Monday, TimeZone: req.TimeZone}
+	if req.FirstWeekday != "" {
+		day, err := holiday.ParseWeekday(req.FirstWeekday)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		st.FirstWeekday = day
+	}
+	if err := svc.SetUserSettings(req.UserID, st); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": toUserSettings(st)})
+}
+
+func (h *eventHandler) WeekDays(c *gin.Context) {
+	var req struct {
+		UserID int    `form:"user_id" binding:"required"`
+		Date   string `form:"date" binding:"required"`
+		eventFilter
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	parsedDate, err := parsedDate(req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	days, err := svc.WeekDays(req.UserID, parsedDate, req.filter())
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": days})
+}
+
+func (h *eventHandler) BusinessDays(c *gin.Context) {
+	var req struct {
+		UserID int    `form:"user_id" binding:"required"`
+		Date   string `form:"date" binding:"required"`
+		N      int    `form:"n" binding:"required"`
+		eventFilter
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+	parsedDate, err := parsedDate(req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, use YYYY-MM-DD"})
+		return
+	}
+	svc, ok := serviceFor(c, h.services)
+	if !ok {
+		return
+	}
+	if !authorized(c, req.UserID) {
+		return
+	}
+
+	days, err := svc.NextBusinessDays(req.UserID, parsedDate, req.N, req.filter())
+	if err != nil {
+		c.JSON(errstatus.HTTP(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": days})
+}
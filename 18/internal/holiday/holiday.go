@@ -0,0 +1,257 @@
+// Package holiday loads working calendars: public holidays and weekends of a
+// region.
+package holiday
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// maxSpan is the most days a single ICS event may mark as holidays.
+const maxSpan = 366
+
+// Calendar tells working days from non-working ones. The zero value has no
+// holidays and no weekend; use New for a Saturday and Sunday weekend.
+type Calendar struct {
+	Region string
+
+	weekend  [7]bool
+	holidays map[string]string // date -> name
+	// annual holidays repeat every year, keyed by "01-02".
+	annual map[string]string
+	// working are weekend days declared working, e.g. moved holidays.
+	working map[string]bool
+}
+
+// New returns a calendar of region without holidays and with Saturday and
+// Sunday off.
+func New(region string) *Calendar {
+	c := &Calendar{
+		Region:   region,
+		holidays: make(map[string]string),
+		annual:   make(map[string]string),
+		working:  make(map[string]bool),
+	}
+	c.weekend[time.Saturday] = true
+	c.weekend[time.Sunday] = true
+	return c
+}
+
+// AddHoliday marks date as a non-working day called name.
+func (c *Calendar) AddHoliday(date time.Time, name string) {
+	c.holidays[date.Format(dateLayout)] = name
+}
+
+// IsWorkingDay reports whether date is neither a holiday nor a weekend day,
+// unless it was declared working.
+func (c *Calendar) IsWorkingDay(date time.Time) bool {
+	if _, ok := c.Holiday(date); ok {
+		return false
+	}
+	return !c.weekend[date.Weekday()] || c.working[date.Format(dateLayout)]
+}
+
+// Holiday returns the name of the holiday on date.
+func (c *Calendar) Holiday(date time.Time) (string, bool) {
+	if name, ok := c.holidays[date.Format(dateLayout)]; ok {
+		return name, true
+	}
+	name, ok := c.annual[date.Format("01-02")]
+	return name, ok
+}
+
+// LoadDir loads every .json and .ics file in dir as the calendar of the
+// region named after the file, e.g. "ru.json" is region "ru".
+func LoadDir(dir string) (map[string]*Calendar, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("holidays: %w", err)
+	}
+	calendars := make(map[string]*Calendar)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".json" && ext != ".ics") {
+			continue
+		}
+		c, err := LoadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := calendars[c.Region]; ok {
+			return nil, fmt.Errorf("holidays: region %q is defined twice", c.Region)
+		}
+		calendars[c.Region] = c
+	}
+	return calendars, nil
+}
+
+// LoadFile loads a .json or .ics calendar; the region is the file name
+// without the extension.
+func LoadFile(path string) (*Calendar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("holidays: %w", err)
+	}
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	c := New(strings.TrimSuffix(name, ext))
+
+	switch strings.ToLower(ext) {
+	case ".json":
+		err = c.parseJSON(data)
+	case ".ics":
+		err = c.parseICS(string(data))
+	default:
+		err = fmt.Errorf("unsupported file type %q, use .json or .ics", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("holidays: %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// jsonCalendar is the JSON calendar format:
+//
+//	{
+//	  "weekend": ["saturday", "sunday"],
+//	  "holidays": [{"date": "2024-01-01", "name": "New Year"}],
+//	  "annual": [{"date": "12-25", "name": "Christmas"}],
+//	  "working_days": ["2024-04-27"]
+//	}
+//
+// Every field is optional; weekend defaults to Saturday and Sunday.
+type jsonCalendar struct {
+	Weekend  []string `json:"weekend"`
+	Holidays []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"holidays"`
+	Annual []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"annual"`
+	WorkingDays []string `json:"working_days"`
+}
+
+func (c *Calendar) parseJSON(data []byte) error {
+	var jc jsonCalendar
+	if err := json.Unmarshal(data, &jc); err != nil {
+		return err
+	}
+	if jc.Weekend != nil {
+		c.weekend = [7]bool{}
+		for _, name := range jc.Weekend {
+			d, err := ParseWeekday(name)
+			if err != nil {
+				return err
+			}
+			c.weekend[d] = true
+		}
+	}
+	for _, h := range jc.Holidays {
+		date, err := time.Parse(dateLayout, h.Date)
+		if err != nil {
+			return fmt.Errorf("holiday %q: %w", h.Name, err)
+		}
+		c.AddHoliday(date, h.Name)
+	}
+	for _, h := range jc.Annual {
+		if _, err := time.Parse("01-02", h.Date); err != nil {
+			return fmt.Errorf("annual holiday %q: %w", h.Name, err)
+		}
+		c.annual[h.Date] = h.Name
+	}
+	for _, d := range jc.WorkingDays {
+		date, err := time.Parse(dateLayout, d)
+		if err != nil {
+			return fmt.Errorf("working day: %w", err)
+		}
+		c.working[date.Format(dateLayout)] = true
+	}
+	return nil
+}
+
+// parseICS reads all-day VEVENTs as holidays. Events spanning several days
+// mark every day up to DTEND, at most maxSpan of them, and RRULE:FREQ=YEARLY
+// makes a holiday annual. Other recurrence rules are not supported.
+func (c *Calendar) parseICS(data string) error {
+	// Lines starting with a space or tab continue the previous one.
+	data = strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(data)
+
+	var inEvent bool
+	var summary, rrule string
+	var start, end time.Time
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		name, value, _ := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(name, ";")
+
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent = true
+				summary, rrule, start, end = "", "", time.Time{}, time.Time{}
+			}
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || !inEvent {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return fmt.Errorf("line %d: event %q has no DTSTART", i+1, summary)
+			}
+			if strings.Contains(strings.ToUpper(rrule), "FREQ=YEARLY") {
+				c.annual[start.Format("01-02")] = summary
+				continue
+			}
+			if end.IsZero() {
+				end = start.AddDate(0, 0, 1)
+			}
+			if end.After(start.AddDate(0, 0, maxSpan)) {
+				return fmt.Errorf("line %d: event %q spans more than %d days", i+1, summary, maxSpan)
+			}
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				c.AddHoliday(d, summary)
+			}
+		case "SUMMARY":
+			summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+		case "RRULE":
+			rrule = value
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			if len(value) < 8 {
+				return fmt.Errorf("line %d: invalid date %q", i+1, value)
+			}
+			date, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if strings.EqualFold(name, "DTSTART") {
+				start = date
+			} else {
+				end = date
+			}
+		}
+	}
+	return nil
+}
+
+// ParseWeekday parses an English weekday name such as "monday" or "Mon".
+func ParseWeekday(name string) (time.Weekday, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || (len(name) >= 3 && strings.HasPrefix(full, name)) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
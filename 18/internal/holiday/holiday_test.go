@@ -0,0 +1,74 @@
+package holiday
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadDir(t *testing.T) {
+	calendars, err := LoadDir("testdata")
+	require.NoError(t, err)
+	require.Len(t, calendars, 2)
+
+	ru := calendars["ru"]
+	assert.False(t, ru.IsWorkingDay(day(2024, 5, 9)))
+	name, ok := ru.Holiday(day(2025, 1, 1))
+	assert.True(t, ok)
+	assert.Equal(t, "New Year", name)
+	assert.True(t, ru.IsWorkingDay(day(2024, 4, 27)), "Saturday declared working")
+	assert.False(t, ru.IsWorkingDay(day(2024, 5, 4)), "Saturday")
+	assert.True(t, ru.IsWorkingDay(day(2024, 5, 8)))
+
+	us := calendars["us"]
+	name, _ = us.Holiday(day(2024, 12, 26))
+	assert.Equal(t, "Christmas, Boxing Day", name)
+	assert.True(t, us.IsWorkingDay(day(2024, 12, 27)), "DTEND is exclusive")
+	name, _ = us.Holiday(day(2031, 7, 4))
+	assert.Equal(t, "Independence Day", name, "folded line, yearly rule")
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		return path
+	}
+
+	for name, data := range map[string]string{
+		"bad-date.json":    `{"holidays": [{"date": "01.05.2024"}]}`,
+		"bad-weekend.json": `{"weekend": ["caturday"]}`,
+		"no-start.ics":     "BEGIN:VEVENT\nSUMMARY:x\nEND:VEVENT\n",
+		"holidays.txt":     "",
+	} {
+		_, err := LoadFile(write(name, data))
+		assert.Error(t, err, name)
+	}
+
+	_, err := LoadFile(write("endless.ics", "BEGIN:VEVENT\nSUMMARY:x\nDTSTART:20240101\nDTEND:99991231\nEND:VEVENT\n"))
+	assert.ErrorContains(t, err, `line 5: event "x" spans more than 366 days`)
+
+	c, err := LoadFile(write("il.json", `{"weekend": ["fri", "Saturday"]}`))
+	require.NoError(t, err)
+	assert.False(t, c.IsWorkingDay(day(2024, 6, 7)))
+	assert.True(t, c.IsWorkingDay(day(2024, 6, 9)))
+}
+
+func TestParseWeekday(t *testing.T) {
+	d, err := ParseWeekday("Sunday")
+	require.NoError(t, err)
+	assert.Equal(t, time.Sunday, d)
+	d, _ = ParseWeekday("wed")
+	assert.Equal(t, time.Wednesday, d)
+	_, err = ParseWeekday("s")
+	assert.Error(t, err)
+}
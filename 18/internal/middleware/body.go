@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBody rejects request bodies larger than limit bytes with 413. Routes
+// in larger, by path, take up to their own limit instead, such as uploads.
+//
+// Bodies of unknown length are read ahead so that an oversized one is
+// rejected before the handler runs, except on routes in larger, which
+// stream their bodies and fail with the handler's error.
+func MaxBody(limit int64, larger map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		n, streamed := larger[c.FullPath()]
+		if !streamed {
+			n = limit
+		}
+		if c.Request.ContentLength > n {
+			tooLarge(c, n)
+			return
+		}
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength < 0 && !streamed {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, n+1))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+				return
+			}
+			if int64(len(body)) > n {
+				tooLarge(c, n)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Next()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+		c.Next()
+	}
+}
+
+func tooLarge(c *gin.Context, limit int64) {
+	// The rest of the body is not read, so the connection cannot be reused.
+	c.Header("Connection", "close")
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body too large, at most %d bytes", limit)})
+}
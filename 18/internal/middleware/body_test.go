@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaxBody(8, map[string]int64{"/upload_attachment": 16}))
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	}
+	r.POST("/create_event", echo)
+	r.POST("/upload_attachment", echo)
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"within limit", "/create_event", "12345678", false, http.StatusOK},
+		{"too large", "/create_event", "123456789", false, http.StatusRequestEntityTooLarge},
+		{"chunked within limit", "/create_event", "1234", true, http.StatusOK},
+		{"chunked too large", "/create_event", "123456789", true, http.StatusRequestEntityTooLarge},
+		{"larger route", "/upload_attachment", "0123456789abcdef", false, http.StatusOK},
+		{"larger route too large", "/upload_attachment", "0123456789abcdefg", false, http.StatusRequestEntityTooLarge},
+		{"larger route chunked too large", "/upload_attachment", "0123456789abcdefg", true, http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			if tc.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.want, w.Code)
+			if tc.want == http.StatusOK {
+				assert.Equal(t, tc.body, w.Body.String())
+			}
+		})
+	}
+}
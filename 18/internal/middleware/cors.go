@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSOptions lists what browser apps on other origins may do. "*" in
+// AllowedOrigins allows any origin; with AllowCredentials the request's
+// origin is echoed instead, as browsers require. MaxAge is how long
+// browsers cache a preflight response.
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// exposedHeaders are the response headers scripts may read besides the
+// CORS-safelisted ones.
+var exposedHeaders = strings.Join([]string{IdempotentReplayHeader, "Content-Disposition"}, ", ")
+
+// CORS adds the CORS headers to responses to allowed origins and answers
+// their preflight requests with 204. Preflights from other origins, or for
+// methods not allowed, are rejected with 403; other requests from them get
+// no CORS headers, so browsers hide the response.
+func CORS(opts CORSOptions) gin.HandlerFunc {
+	anyOrigin := slices.Contains(opts.AllowedOrigins, "*")
+	methods := make([]string, len(opts.AllowedMethods))
+	for i, m := range opts.AllowedMethods {
+		methods[i] = strings.ToUpper(m)
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(opts.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge / time.Second))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		allowed := anyOrigin || slices.ContainsFunc(opts.AllowedOrigins, func(o string) bool {
+			return strings.EqualFold(o, origin)
+		})
+		if !allowed {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "origin not allowed"})
+				return
+			}
+			c.Next()
+			return
+		}
+		if preflight && !slices.Contains(methods, c.GetHeader("Access-Control-Request-Method")) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "method not allowed"})
+			return
+		}
+
+		if anyOrigin && !opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", exposedHeaders)
+			c.Next()
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if opts.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func corsRouter(opts CORSOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(opts))
+	r.POST("/create_event", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return r
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	r := corsRouter(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"get", "post"},
+		AllowedHeaders: []string{"Content-Type", "Idempotency-Key"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	preflight := func(origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/create_event", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://app.example.com", http.MethodPost)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Idempotency-Key", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	w = preflight("https://evil.example.com", http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = preflight("https://app.example.com", http.MethodDelete)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_Request(t *testing.T) {
+	r := corsRouter(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{http.MethodPost},
+	})
+
+	for origin, allowed := range map[string]bool{
+		"":                         false,
+		"https://app.example.com":  true,
+		"https://APP.example.com":  true,
+		"https://evil.example.com": false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/create_event", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code, origin)
+		if allowed {
+			assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+			assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), IdempotentReplayHeader)
+		} else {
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	for credentials, want := range map[bool]string{
+		false: "*",
+		true:  "https://app.example.com",
+	} {
+		r := corsRouter(CORSOptions{
+			AllowedOrigins:   []string{"*"},
+			AllowedMethods:   []string{http.MethodPost},
+			AllowCredentials: credentials,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/create_event", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, want, w.Header().Get("Access-Control-Allow-Origin"))
+		if credentials {
+			assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"wb_l12/18/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader is set on responses replayed from the store.
+	IdempotentReplayHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+
+	// DefaultIdempotencyEntries is the number of keys an IdempotencyStore
+	// keeps unless told otherwise.
+	DefaultIdempotencyEntries = 100000
+)
+
+// IdempotencyStore remembers responses by idempotency key for a TTL. Beyond
+// its size the oldest keys are forgotten early, so that clients sending
+// new keys cannot grow it without bound.
+type IdempotencyStore struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// order holds the keys of entries, oldest first.
+	order     *list.List
+	lastSweep time.Time
+}
+
+type idempotencyEntry struct {
+	elem        *list.Element
+	bodyHash    [sha256.Size]byte
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// NewIdempotencyStore keeps responses for ttl and at most size keys.
+func NewIdempotencyStore(ttl time.Duration, size int) *IdempotencyStore {
+	if size <= 0 {
+		size = DefaultIdempotencyEntries
+	}
+	return &IdempotencyStore{
+		ttl:     ttl,
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*idempotencyEntry),
+		order:   list.New(),
+	}
+}
+
+// Idempotency makes retries of a request with the same Idempotency-Key
+// header return the original response instead of executing it again.
+// Reusing a key with a different body is rejected with 422, and a retry
+// arriving while the original is still running gets 409. Only successes and
+// rejections of the request itself (400, 413 and 422) are remembered; other
+// responses, such as conflicts, exceeded quotas and server errors, may
+// change and the request can be retried.
+func Idempotency(store *IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the tenant, the client and the route so that one
+		// key cannot replay the response of another tenant, user or endpoint.
+		// Replays skip the handler and its user checks. Clients without a
+		// certificate are told apart by address.
+		client := "ip:" + c.ClientIP()
+		if id, ok := UserID(c); ok {
+			client = "user:" + strconv.Itoa(id)
+		}
+		scoped := tenant.FromContext(c.Request.Context()) + " " + client + " " + c.Request.Method + " " + c.FullPath() + " " + key
+		hash := sha256.Sum256(body)
+		entry, created := store.begin(scoped, hash)
+		switch {
+		case !created && entry.bodyHash != hash:
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request body"})
+			return
+		case !created && !entry.done:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			return
+		case !created:
+			c.Header(IdempotentReplayHeader, "true")
+			c.Data(entry.status, entry.contentType, entry.body)
+			c.Abort()
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		store.finish(scoped, c.Writer.Status(), c.Writer.Header().Get("Content-Type"), rec.body.Bytes())
+	}
+}
+
+// begin returns the live entry for key or registers a new in-flight one.
+func (s *IdempotencyStore) begin(key string, bodyHash [sha256.Size]byte) (idempotencyEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		return *e, false
+	}
+	s.drop(key)
+	for len(s.entries) >= s.size {
+		s.drop(s.order.Front().Value.(string))
+	}
+	e := &idempotencyEntry{bodyHash: bodyHash, expires: now.Add(s.ttl)}
+	e.elem = s.order.PushBack(key)
+	s.entries[key] = e
+	return *e, true
+}
+
+func (s *IdempotencyStore) finish(key string, status int, contentType string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	if !remembered(status) {
+		s.drop(key)
+		return
+	}
+	e.done = true
+	e.status = status
+	e.contentType = contentType
+	e.body = body
+	e.expires = s.now().Add(s.ttl)
+}
+
+// sweep drops expired entries at most once per minute; s.mu must be held.
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			s.drop(k)
+		}
+	}
+}
+
+// drop forgets key; s.mu must be held.
+func (s *IdempotencyStore) drop(key string) {
+	if e, ok := s.entries[key]; ok {
+		s.order.Remove(e.elem)
+		delete(s.entries, key)
+	}
+}
+
+// remembered tells whether a response with status is the same for every
+// retry of the request.
+func remembered(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
+	}
+	return status >= 200 && status < 300
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
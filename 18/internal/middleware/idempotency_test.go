@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func idempotencyRouter(store *IdempotencyStore, calls *atomic.Int32, status int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := func(c *gin.Context) {
+		n := calls.Add(1)
+		c.JSON(status, gin.H{"result": n})
+	}
+	r.POST("/create", Idempotency(store), handler)
+	r.POST("/update", Idempotency(store), handler)
+	return r
+}
+
+func post(r http.Handler, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotencyRouter(NewIdempotencyStore(time.Hour, 0), &calls, http.StatusOK)
+
+	first := post(r, "/create", "k1", `{"title":"a"}`)
+	retry := post(r, "/create", "k1", `{"title":"a"}`)
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+
+	post(r, "/create", "", `{"title":"a"}`)
+	post(r, "/update", "k1", `{"title":"a"}`)
+	assert.Equal(t, int32(3), calls.Load(), "requests without a key and other routes are not deduplicated")
+}
+
+func TestIdempotency_RejectsDifferentBody(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotencyRouter(NewIdempotencyStore(time.Hour, 0), &calls, http.StatusOK)
+
+	post(r, "/create", "k1", `{"title":"a"}`)
+	w := post(r, "/create", "k1", `{"title":"b"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestIdempotency_ExpiresAfterTTL(t *testing.T) {
+	var calls atomic.Int32
+	store := NewIdempotencyStore(time.Minute, 0)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	r := idempotencyRouter(store, &calls, http.StatusOK)
+
+	post(r, "/create", "k1", `{}`)
+	now = now.Add(2 * time.Minute)
+	post(r, "/create", "k1", `{}`)
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Len(t, store.entries, 1, "expired entries are swept")
+}
+
+func TestIdempotency_DoesNotRememberServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotencyRouter(NewIdempotencyStore(time.Hour, 0), &calls, http.StatusServiceUnavailable)
+
+	post(r, "/create", "k1", `{}`)
+	post(r, "/create", "k1", `{}`)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestIdempotency_ConcurrentRetryConflicts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewIdempotencyStore(time.Hour, 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	r := gin.New()
+	r.POST("/create", Idempotency(store), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		post(r, "/create", "k1", `{}`)
+		close(done)
+	}()
+	<-started
+	w := post(r, "/create", "k1", `{}`)
+	close(release)
+	<-done
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestIdempotency_ScopedToClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls atomic.Int32
+	r := gin.New()
+	r.Use(ClientCertIdentity(map[string]int{"CN=alice": 1, "CN=bob": 2}))
+	r.POST("/create", Idempotency(NewIdempotencyStore(time.Hour, 0)), func(c *gin.Context) {
+		id, _ := UserID(c)
+		calls.Add(1)
+		c.JSON(http.StatusOK, gin.H{"result": id})
+	})
+	postAs := func(cn string) *httptest.ResponseRecorder {
+		req := requestWithCert(cn)
+		req.Method = http.MethodPost
+		req.URL.Path = "/create"
+		req.Body = io.NopCloser(strings.NewReader(`{"title":"a"}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	alice := postAs("alice")
+	bob := postAs("bob")
+
+	assert.Equal(t, int32(2), calls.Load(), "another client does not get alice's response")
+	assert.JSONEq(t, `{"result":1}`, alice.Body.String())
+	assert.JSONEq(t, `{"result":2}`, bob.Body.String())
+	assert.Empty(t, bob.Header().Get(IdempotentReplayHeader))
+	assert.Equal(t, "true", postAs("alice").Header().Get(IdempotentReplayHeader))
+}
+
+func TestIdempotency_RemembersOnlyFinalResponses(t *testing.T) {
+	for status, want := range map[int]int32{
+		http.StatusCreated:         1,
+		http.StatusBadRequest:      1,
+		http.StatusConflict:        2,
+		http.StatusTooManyRequests: 2,
+		http.StatusForbidden:       2,
+	} {
+		var calls atomic.Int32
+		r := idempotencyRouter(NewIdempotencyStore(time.Hour, 0), &calls, status)
+		post(r, "/create", "k1", `{}`)
+		post(r, "/create", "k1", `{}`)
+		assert.Equal(t, want, calls.Load(), "status %d", status)
+	}
+}
+
+func TestIdempotency_ForgetsOldestKeysBeyondSize(t *testing.T) {
+	var calls atomic.Int32
+	store := NewIdempotencyStore(time.Hour, 2)
+	r := idempotencyRouter(store, &calls, http.StatusOK)
+
+	for _, key := range []string{"k1", "k2", "k3", "k3"} {
+		post(r, "/create", key, `{}`)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Len(t, store.entries, 2)
+	assert.Equal(t, 2, store.order.Len())
+	post(r, "/create", "k1", `{}`)
+	assert.Equal(t, int32(4), calls.Load(), "k1 was forgotten")
+}
+
+func TestIdempotency_ScopedToAddressWithoutCertificate(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotencyRouter(NewIdempotencyStore(time.Hour, 0), &calls, http.StatusOK)
+	postFrom := func(addr string) {
+		req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(`{}`))
+		req.RemoteAddr = addr
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	postFrom("192.0.2.1:1000")
+	postFrom("192.0.2.1:2000")
+	postFrom("192.0.2.2:1000")
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const userIDKey = "identity_user_id"
+
+// ClientCertIdentity resolves the verified client certificate subject to a
+// user ID using users. Requests with a verified certificate that is not in
+// users are rejected; requests without a client certificate pass through
+// without an identity.
+func ClientCertIdentity(users map[string]int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			c.Next()
+			return
+		}
+
+		subject := state.VerifiedChains[0][0].Subject.String()
+		userID, ok := users[subject]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "unknown client certificate"})
+			return
+		}
+		c.Set(userIDKey, userID)
+		c.Next()
+	}
+}
+
+// UserID returns the identity established by ClientCertIdentity, if any.
+func UserID(c *gin.Context) (int, bool) {
+	v, ok := c.Get(userIDKey)
+	if !ok {
+		return 0, false
+	}
+	id, ok := v.(int)
+	return id, ok
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func identityRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ClientCertIdentity(map[string]int{"CN=alice": 7}))
+	r.GET("/", func(c *gin.Context) {
+		id, ok := UserID(c)
+		c.String(http.StatusOK, strconv.FormatBool(ok)+":"+strconv.Itoa(id))
+	})
+	return r
+}
+
+func requestWithCert(cn string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cn != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return req
+}
+
+func TestClientCertIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		cn       string
+		wantCode int
+		wantBody string
+	}{
+		{name: "known subject", cn: "alice", wantCode: http.StatusOK, wantBody: "true:7"},
+		{name: "unknown subject", cn: "mallory", wantCode: http.StatusForbidden},
+		{name: "no certificate", wantCode: http.StatusOK, wantBody: "false:0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			identityRouter().ServeHTTP(w, requestWithCert(tt.cn))
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"wb_l12/18/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant puts the tenant of the request into the request context. A
+// verified client certificate whose subject is in certTenants pins the
+// tenant, and a X-Tenant-ID header naming another one is rejected;
+// otherwise the header is used, or tenant.Default without it. Whether the
+// tenant exists is left to the service.
+func Tenant(certTenants map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(tenant.Header)
+		if id != "" {
+			if err := tenant.Validate(id); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if state := c.Request.TLS; state != nil && len(state.VerifiedChains) > 0 {
+			subject := state.VerifiedChains[0][0].Subject.String()
+			if pinned, ok := certTenants[subject]; ok {
+				if id != "" && id != pinned {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "tenant does not match client certificate"})
+					return
+				}
+				id = pinned
+			}
+		}
+
+		if id != "" {
+			c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), id))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"wb_l12/18/internal/tenant"
+)
+
+func TestTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Tenant(map[string]string{"CN=alice": "acme"}))
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, tenant.FromContext(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name     string
+		cn       string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{name: "no tenant", wantCode: http.StatusOK, wantBody: tenant.Default},
+		{name: "header", header: "globex", wantCode: http.StatusOK, wantBody: "globex"},
+		{name: "invalid header", header: "../acme", wantCode: http.StatusBadRequest},
+		{name: "pinned by certificate", cn: "alice", wantCode: http.StatusOK, wantBody: "acme"},
+		{name: "same as certificate", cn: "alice", header: "acme", wantCode: http.StatusOK, wantBody: "acme"},
+		{name: "other than certificate", cn: "alice", header: "globex", wantCode: http.StatusForbidden},
+		{name: "unpinned certificate", cn: "bob", header: "globex", wantCode: http.StatusOK, wantBody: "globex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := requestWithCert(tt.cn)
+			if tt.header != "" {
+				req.Header.Set(tenant.Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// BookingLink lets people without an account book time with a user through
+// its public Token. Slots of SlotMinutes start at From on the listed
+// weekdays, BufferMinutes apart, and end by To; times are "15:04" in the
+// user's time zone.
+type BookingLink struct {
+	Token  string `json:"token"`
+	UserID int    `json:"user_id"`
+	// Title is the title of the booked events, followed by the name of
+	// whoever booked.
+	Title         string         `json:"title"`
+	Weekdays      []time.Weekday `json:"weekdays"`
+	From          string         `json:"from"`
+	To            string         `json:"to"`
+	SlotMinutes   int            `json:"slot_minutes"`
+	BufferMinutes int            `json:"buffer_minutes,omitempty"`
+}
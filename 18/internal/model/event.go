@@ -7,4 +7,18 @@ type Event struct {
 	UserID int       `json:"user_id"`
 	Date   time.Time `json:"date"`
 	Title  string    `json:"title"`
+	// StartTime is "15:04" in the user's time zone; empty for all-day events.
+	StartTime       string `json:"start_time,omitempty"`
+	DurationMinutes int    `json:"duration_minutes,omitempty"`
+	Description     string `json:"description,omitempty"`
+	Location        string `json:"location,omitempty"`
+	Category        string `json:"category,omitempty"`
+	// Color is a "#rrggbb" hex color used by clients to render the event.
+	Color string   `json:"color,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	// Attendees are e-mail addresses of the people invited.
+	Attendees []string `json:"attendees,omitempty"`
+	// Reminders are minutes before the start, or before midnight for
+	// all-day events, at which to notify the attendees.
+	Reminders []int `json:"reminders,omitempty"`
 }
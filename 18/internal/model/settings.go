@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// UserSettings are a user's calendar preferences.
+type UserSettings struct {
+	// Region selects the holiday calendar; empty means the default region.
+	Region       string       `json:"region,omitempty"`
+	FirstWeekday time.Weekday `json:"first_weekday"`
+	// TimeZone is an IANA name such as "Europe/Moscow"; empty means the
+	// default time zone of the service, UTC unless set.
+	TimeZone string `json:"time_zone,omitempty"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// Template is a user's blueprint for events created repeatedly, such as a
+// sprint planning. It has every field of an event except the date.
+type Template struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// Name tells the user's templates apart; Title is the title of the
+	// events made from it.
+	Name            string   `json:"name"`
+	Title           string   `json:"title"`
+	StartTime       string   `json:"start_time,omitempty"`
+	DurationMinutes int      `json:"duration_minutes,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Location        string   `json:"location,omitempty"`
+	Category        string   `json:"category,omitempty"`
+	Color           string   `json:"color,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Attendees       []string `json:"attendees,omitempty"`
+	Reminders       []int    `json:"reminders,omitempty"`
+}
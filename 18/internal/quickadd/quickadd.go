@@ -0,0 +1,364 @@
+// Package quickadd turns short English or Russian phrases such as
+// "Standup tomorrow at 10:00 for 15m" or "Демо в пятницу в 16:00" into event
+// fields.
+package quickadd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoTitle means nothing is left for the title once the date, time and
+// duration are taken out of the text.
+var ErrNoTitle = errors.New("quick add: no title in text")
+
+// Result is the interpretation of a phrase.
+type Result struct {
+	Title string
+	// Date is midnight of the event day in the location of now.
+	Date time.Time
+	// Start is "15:04" or empty when no time was given.
+	Start    string
+	Duration time.Duration
+	// Warnings explain the guesses made for ambiguous input.
+	Warnings []string
+}
+
+// Parse interprets text relative to now, which should be in the user's time
+// zone.
+func Parse(text string, now time.Time) (Result, error) {
+	p := newParser(text, now)
+	if err := p.duration(); err != nil {
+		return Result{}, err
+	}
+	hasTime, err := p.time()
+	if err != nil {
+		return Result{}, err
+	}
+	hasDate, err := p.date()
+	if err != nil {
+		return Result{}, err
+	}
+
+	today := midnight(now)
+	switch {
+	case !hasDate && hasTime && p.res.Start < now.Format("15:04"):
+		p.res.Date = today.AddDate(0, 0, 1)
+		p.warn("no date given and %s has passed today, assumed tomorrow %s", p.res.Start, p.res.Date.Format(dateLayout))
+	case !hasDate:
+		p.res.Date = today
+		p.warn("no date given, assumed today %s", today.Format(dateLayout))
+	case p.res.Date.Equal(today) && hasTime && p.res.Start < now.Format("15:04"):
+		p.warn("%s today has already passed", p.res.Start)
+	}
+	if hasDate {
+		for _, re := range datePatterns {
+			if m := re.FindString(p.text()); m != "" {
+				p.warn("%q also looks like a date, kept it in the title", strings.TrimSpace(m))
+				break
+			}
+		}
+	}
+
+	p.res.Title = p.title()
+	if p.res.Title == "" {
+		return Result{}, ErrNoTitle
+	}
+	return p.res, nil
+}
+
+const dateLayout = "2006-01-02"
+
+type parser struct {
+	orig string
+	// lower is orig lowercased; consumed spans are replaced with spaces.
+	lower []byte
+	now   time.Time
+	res   Result
+}
+
+func newParser(text string, now time.Time) *parser {
+	text = " " + strings.Join(strings.Fields(text), " ") + " "
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Offsets of the two strings must match; lose the case instead.
+		text = lower
+	}
+	return &parser{orig: text, lower: []byte(lower), now: now}
+}
+
+func (p *parser) text() string {
+	return string(p.lower)
+}
+
+func (p *parser) warn(format string, args ...any) {
+	p.res.Warnings = append(p.res.Warnings, fmt.Sprintf(format, args...))
+}
+
+// take consumes the first match of re and returns its submatches.
+func (p *parser) take(re *regexp.Regexp) []string {
+	loc := re.FindSubmatchIndex(p.lower)
+	if loc == nil {
+		return nil
+	}
+	m := make([]string, len(loc)/2)
+	for i := range m {
+		if loc[2*i] >= 0 {
+			m[i] = string(p.lower[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	for i := loc[0]; i < loc[1]; i++ {
+		p.lower[i] = ' '
+	}
+	return m
+}
+
+// title keeps the words of the original text that were not consumed and
+// drops prepositions left dangling at either end.
+func (p *parser) title() string {
+	var b strings.Builder
+	for i := range p.lower {
+		if p.lower[i] == ' ' {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(p.orig[i])
+		}
+	}
+	words := strings.Fields(b.String())
+	for len(words) > 0 && dangling[strings.ToLower(words[len(words)-1])] {
+		words = words[:len(words)-1]
+	}
+	for len(words) > 0 && dangling[strings.ToLower(words[0])] {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+var dangling = map[string]bool{
+	"at": true, "on": true, "in": true, "for": true, "-": true, ",": true,
+	"в": true, "во": true, "на": true, "через": true,
+}
+
+// Go regexps have no Unicode word boundaries, so patterns match the spaces
+// around words instead; the text is padded with spaces.
+var (
+	durationRe     = regexp.MustCompile(`\s(?:for|на)\s+((?:\d+(?:[.,]\d+)?\s*(?:hours?|hrs?|h|minutes?|mins?|m|часа|часов|час|ч|минуты|минуту|минут|мин|м)\s*)+)\s`)
+	durationWordRe = regexp.MustCompile(`\s(?:for\s+(half an hour|an hour|one hour)|на\s+(полчаса|час))\s`)
+	durationPartRe = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*([a-zа-я]+)`)
+
+	clockRe    = regexp.MustCompile(`\s(?:at\s+|в\s+)?(\d{1,2}):(\d{2})\s*(am|pm|утра|дня|вечера|ночи)?\s`)
+	atHourRe   = regexp.MustCompile(`\s(?:at|в)\s+(\d{1,2})\s*(am|pm|утра|дня|вечера|ночи)?\s`)
+	ampmRe     = regexp.MustCompile(`\s(\d{1,2})\s*(am|pm)\s`)
+	noonRe     = regexp.MustCompile(`\s(?:at\s+)?(noon|midnight|полдень|полночь)\s`)
+	isoDateRe  = regexp.MustCompile(`\s(?:on\s+)?(\d{4})-(\d{2})-(\d{2})\s`)
+	dotDateRe  = regexp.MustCompile(`\s(?:on\s+)?(\d{1,2})\.(\d{1,2})(?:\.(\d{4}|\d{2}))?\s`)
+	relativeRe = regexp.MustCompile(`\s(today|tonight|tomorrow|(?:the\s+)?day\s+after\s+tomorrow|сегодня|завтра|послезавтра)\s`)
+	inDaysRe   = regexp.MustCompile(`\s(?:in|через)\s+(?:(\d+|a|an|one|two|three)\s+)?(days?|weeks?|день|дня|дней|неделю|недели|недель)\s`)
+	weekdayRe  = regexp.MustCompile(`\s(?:on\s+|в\s+|во\s+)?(next\s+|this\s+|следующ(?:ий|ую|ее)\s+|эт(?:от|у)\s+)?(monday|tuesday|wednesday|thursday|friday|saturday|sunday|понедельник|вторник|среду|четверг|пятницу|субботу|воскресенье)\s`)
+	monthDayRe = regexp.MustCompile(`\s(?:on\s+)?(?:(\d{1,2})(?:st|nd|rd|th)?\s+(` + monthNames + `)|(` + monthNames + `)\s+(\d{1,2})(?:st|nd|rd|th)?)(?:,?\s+(\d{4})(?:\s+(?:года|г\.?))?)?\s`)
+)
+
+const monthNames = `january|february|march|april|may|june|july|august|september|october|november|december|` +
+	`jan|feb|mar|apr|jun|jul|aug|sep|sept|oct|nov|dec|` +
+	`января|февраля|марта|апреля|мая|июня|июля|августа|сентября|октября|ноября|декабря`
+
+var datePatterns = []*regexp.Regexp{isoDateRe, dotDateRe, relativeRe, inDaysRe, weekdayRe, monthDayRe}
+
+func (p *parser) duration() error {
+	if m := p.take(durationWordRe); m != nil {
+		if m[1] == "half an hour" || m[2] == "полчаса" {
+			p.res.Duration = 30 * time.Minute
+		} else {
+			p.res.Duration = time.Hour
+		}
+		return nil
+	}
+	m := p.take(durationRe)
+	if m == nil {
+		return nil
+	}
+	for _, part := range durationPartRe.FindAllStringSubmatch(m[1], -1) {
+		n, err := strconv.ParseFloat(strings.Replace(part[1], ",", ".", 1), 64)
+		if err != nil {
+			return fmt.Errorf("quick add: invalid duration %q", part[0])
+		}
+		unit := time.Minute
+		if strings.HasPrefix(part[2], "h") || strings.HasPrefix(part[2], "ч") {
+			unit = time.Hour
+		}
+		p.res.Duration += time.Duration(n * float64(unit))
+	}
+	if p.res.Duration <= 0 || p.res.Duration > 24*time.Hour {
+		return fmt.Errorf("quick add: duration %s is out of range", p.res.Duration)
+	}
+	return nil
+}
+
+func (p *parser) time() (bool, error) {
+	hour, minute, suffix := -1, 0, ""
+	if m := p.take(noonRe); m != nil {
+		hour = 12
+		if m[1] == "midnight" || m[1] == "полночь" {
+			hour = 0
+		}
+	} else if m := p.take(clockRe); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		minute, _ = strconv.Atoi(m[2])
+		suffix = m[3]
+	} else if m := p.take(atHourRe); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		suffix = m[2]
+		if suffix == "" && hour >= 1 && hour <= 7 {
+			hour += 12
+			p.warn("%s read as %02d:00, add am or pm to be explicit", strings.TrimSpace(m[0]), hour)
+		}
+	} else if m := p.take(ampmRe); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		suffix = m[2]
+	} else {
+		return false, nil
+	}
+
+	switch suffix {
+	case "am", "ночи", "утра":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm", "дня", "вечера":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return false, fmt.Errorf("quick add: invalid time %02d:%02d", hour, minute)
+	}
+	p.res.Start = fmt.Sprintf("%02d:%02d", hour, minute)
+	return true, nil
+}
+
+func (p *parser) date() (bool, error) {
+	today := midnight(p.now)
+	if m := p.take(isoDateRe); m != nil {
+		date, err := time.ParseInLocation(dateLayout, m[1]+"-"+m[2]+"-"+m[3], p.now.Location())
+		if err != nil {
+			return false, fmt.Errorf("quick add: invalid date %q", strings.TrimSpace(m[0]))
+		}
+		p.setDate(date, true)
+		return true, nil
+	}
+	if m := p.take(dotDateRe); m != nil {
+		day, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		return true, p.dayMonth(day, month, m[3], m[0])
+	}
+	if m := p.take(monthDayRe); m != nil {
+		dayStr, name := m[1], m[2]
+		if name == "" {
+			dayStr, name = m[4], m[3]
+		}
+		day, _ := strconv.Atoi(dayStr)
+		return true, p.dayMonth(day, monthNumber(name), m[5], m[0])
+	}
+	if m := p.take(relativeRe); m != nil {
+		switch {
+		case m[1] == "tomorrow" || m[1] == "завтра":
+			p.res.Date = today.AddDate(0, 0, 1)
+		case strings.HasSuffix(m[1], "after tomorrow") || m[1] == "послезавтра":
+			p.res.Date = today.AddDate(0, 0, 2)
+		default:
+			p.res.Date = today
+		}
+		return true, nil
+	}
+	if m := p.take(inDaysRe); m != nil {
+		n := 1
+		switch m[1] {
+		case "", "a", "an", "one":
+		case "two":
+			n = 2
+		case "three":
+			n = 3
+		default:
+			n, _ = strconv.Atoi(m[1])
+		}
+		if strings.HasPrefix(m[2], "week") || strings.HasPrefix(m[2], "недел") {
+			n *= 7
+		}
+		p.res.Date = today.AddDate(0, 0, n)
+		return true, nil
+	}
+	if m := p.take(weekdayRe); m != nil {
+		target := weekdays[m[2]]
+		days := (int(target) - int(today.Weekday()) + 7) % 7
+		next := strings.HasPrefix(m[1], "next") || strings.HasPrefix(m[1], "следующ")
+		switch {
+		case next && days == 0:
+			days = 7
+		case next:
+			p.warn("%q read as the coming %s, %s", strings.TrimSpace(m[0]), target, today.AddDate(0, 0, days).Format(dateLayout))
+		case days == 0:
+			p.warn("%s is today; say \"next %s\" for next week", target, strings.ToLower(target.String()))
+		}
+		p.res.Date = today.AddDate(0, 0, days)
+		return true, nil
+	}
+	return false, nil
+}
+
+// dayMonth sets a date given without a year, or with a 2 or 4 digit year.
+func (p *parser) dayMonth(day, month int, year, match string) error {
+	y, explicitYear := p.now.Year(), year != ""
+	if explicitYear {
+		y, _ = strconv.Atoi(year)
+		if len(year) == 2 {
+			y += 2000
+		}
+	}
+	date := time.Date(y, time.Month(month), day, 0, 0, 0, 0, p.now.Location())
+	if month < 1 || month > 12 || date.Day() != day {
+		return fmt.Errorf("quick add: invalid date %q", strings.TrimSpace(match))
+	}
+	if !explicitYear && date.Before(midnight(p.now)) {
+		date = date.AddDate(1, 0, 0)
+		p.warn("%s has passed this year, assumed %s", strings.TrimSpace(match), date.Format(dateLayout))
+	}
+	p.setDate(date, explicitYear)
+	return nil
+}
+
+func (p *parser) setDate(date time.Time, checkPast bool) {
+	if checkPast && date.Before(midnight(p.now)) {
+		p.warn("%s is in the past", date.Format(dateLayout))
+	}
+	p.res.Date = date
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+	"понедельник": time.Monday, "вторник": time.Tuesday, "среду": time.Wednesday,
+	"четверг": time.Thursday, "пятницу": time.Friday, "субботу": time.Saturday, "воскресенье": time.Sunday,
+}
+
+func monthNumber(name string) int {
+	ru := []string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+	for i, m := range ru {
+		if name == m {
+			return i + 1
+		}
+	}
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), name) {
+			return int(m)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package quickadd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// now is Wednesday, 8 May 2024, 11:00.
+var now = time.Date(2024, 5, 8, 11, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text     string
+		title    string
+		date     string
+		start    string
+		duration time.Duration
+	}{
+		{"Standup tomorrow at 10:00 for 15m", "Standup", "2024-05-09", "10:00", 15 * time.Minute},
+		{"Демо в пятницу в 16:00", "Демо", "2024-05-10", "16:00", 0},
+		{"Review on Friday 3pm for 1h30m", "Review", "2024-05-10", "15:00", 90 * time.Minute},
+		{"Встреча завтра в 9 утра на полчаса", "Встреча", "2024-05-09", "09:00", 30 * time.Minute},
+		{"Ужин через 3 дня в 7 вечера на 2 часа", "Ужин", "2024-05-11", "19:00", 2 * time.Hour},
+		{"Trip to Paris 10.06 for 2 hours", "Trip to Paris", "2024-06-10", "", 2 * time.Hour},
+		{"Отчёт 15 мая 2024 года", "Отчёт", "2024-05-15", "", 0},
+		{"Planning in 2 weeks at noon", "Planning", "2024-05-22", "12:00", 0},
+		{"Launch on 2024-07-01 at 12:30am", "Launch", "2024-07-01", "00:30", 0},
+		{"Retro day after tomorrow for an hour", "Retro", "2024-05-10", "", time.Hour},
+		{"Call with Anna June 3rd", "Call with Anna", "2024-06-03", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			res, err := Parse(tt.text, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.title, res.Title)
+			assert.Equal(t, tt.date, res.Date.Format(dateLayout))
+			assert.Equal(t, tt.start, res.Start)
+			assert.Equal(t, tt.duration, res.Duration)
+			assert.Empty(t, res.Warnings)
+		})
+	}
+}
+
+func TestParse_Warnings(t *testing.T) {
+	tests := []struct {
+		text  string
+		date  string
+		start string
+		warn  string
+	}{
+		{"Lunch today at 1", "2024-05-08", "13:00", "add am or pm"},
+		{"Coffee", "2024-05-08", "", "no date given"},
+		{"Breakfast 9:00", "2024-05-09", "09:00", "has passed today"},
+		{"Sync wednesday", "2024-05-08", "", "next wednesday"},
+		{"Dentist next friday", "2024-05-10", "", "coming Friday"},
+		{"Call May 3", "2025-05-03", "", "has passed this year"},
+		{"Old 2024-01-01", "2024-01-01", "", "in the past"},
+		{"Meet 2024-05-10 or 2024-05-11", "2024-05-10", "", "also looks like a date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			res, err := Parse(tt.text, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.date, res.Date.Format(dateLayout))
+			assert.Equal(t, tt.start, res.Start)
+			require.Len(t, res.Warnings, 1)
+			assert.Contains(t, res.Warnings[0], tt.warn)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := Parse("tomorrow at 10:00", now)
+	assert.ErrorIs(t, err, ErrNoTitle)
+
+	for _, text := range []string{"Party 31.02", "Party at 25:00", "Nap for 30h"} {
+		_, err := Parse(text, now)
+		assert.Error(t, err, text)
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// MaxReportDays caps the range of one Report.
+	MaxReportDays = 366
+	// busiestDays is how many days a report ranks as the busiest.
+	busiestDays = 5
+)
+
+// Report periods.
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Report sums a user's events over a range of days.
+type Report struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Period  string    `json:"period"`
+	Events  int       `json:"events"`
+	Minutes int       `json:"minutes"`
+	// Periods cover the range without gaps; the first one may start before
+	// From and the last one end after To.
+	Periods []PeriodTotal `json:"periods"`
+	// BusiestDays are the days with the most events, then the most minutes.
+	BusiestDays []storage.DayTotal `json:"busiest_days"`
+	// Tags count an event once under each of its tags; the key of untagged
+	// events is empty.
+	Tags       []storage.GroupTotal `json:"tags"`
+	Categories []storage.GroupTotal `json:"categories"`
+}
+
+// PeriodTotal sums the events of the day, week or month starting at Start.
+type PeriodTotal struct {
+	Start   time.Time `json:"start"`
+	Events  int       `json:"events"`
+	Minutes int       `json:"minutes"`
+}
+
+// Report sums the user's events dated from from to to, both included, per
+// period, which is PeriodDay, PeriodWeek or PeriodMonth. Weeks start on the
+// user's first weekday.
+func (s *Service) Report(userID int, from, to time.Time, period string) (rep Report, err error) {
+	s, span := s.start("Report",
+		attribute.Int("user_id", userID),
+		attribute.String("from", from.Format(time.DateOnly)),
+		attribute.String("to", to.Format(time.DateOnly)),
+		attribute.String("period", period))
+	defer func() { tracing.End(span, err) }()
+
+	from, to = midnight(from), midnight(to)
+	if to.Before(from) || to.Sub(from) >= MaxReportDays*24*time.Hour {
+		return Report{}, fmt.Errorf("%w: report on 1..%d days", ErrInvalidRange, MaxReportDays)
+	}
+	var bucket func(time.Time) time.Time
+	switch period {
+	case PeriodDay:
+		bucket = func(d time.Time) time.Time { return d }
+	case PeriodWeek:
+		first := s.UserSettings(userID).FirstWeekday
+		bucket = func(d time.Time) time.Time { return weekStart(d, first) }
+	case PeriodMonth:
+		bucket = func(d time.Time) time.Time { return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC) }
+	default:
+		return Report{}, fmt.Errorf("%w: unknown period %q", ErrInvalidRange, period)
+	}
+
+	end := to.AddDate(0, 0, 1)
+	daily, err := s.store().DailyTotals(userID, from, end)
+	if err != nil {
+		return Report{}, err
+	}
+	tags, err := s.store().GroupTotals(userID, from, end, storage.ByTag)
+	if err != nil {
+		return Report{}, err
+	}
+	categories, err := s.store().GroupTotals(userID, from, end, storage.ByCategory)
+	if err != nil {
+		return Report{}, err
+	}
+
+	rep = Report{From: from, To: to, Period: period, Periods: []PeriodTotal{}, Tags: tags, Categories: categories}
+	for d := from; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if start := bucket(d); len(rep.Periods) == 0 || !rep.Periods[len(rep.Periods)-1].Start.Equal(start) {
+			rep.Periods = append(rep.Periods, PeriodTotal{Start: start})
+		}
+	}
+	i := 0
+	for _, t := range daily {
+		start := bucket(t.Date)
+		for !rep.Periods[i].Start.Equal(start) {
+			i++
+		}
+		rep.Periods[i].Events += t.Events
+		rep.Periods[i].Minutes += t.Minutes
+		rep.Events += t.Events
+		rep.Minutes += t.Minutes
+	}
+
+	sort.SliceStable(daily, func(i, j int) bool {
+		if daily[i].Events != daily[j].Events {
+			return daily[i].Events > daily[j].Events
+		}
+		return daily[i].Minutes > daily[j].Minutes
+	})
+	rep.BusiestDays = daily[:min(len(daily), busiestDays)]
+	return rep, nil
+}
+
+func midnight(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
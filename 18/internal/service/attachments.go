@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrAttachmentsDisabled means the service has no attachment store.
+var ErrAttachmentsDisabled = errors.New("attachments are disabled")
+
+// WithAttachments stores event attachments in store. They are removed with
+// the event by DeleteEvent and by retention purges; archived events keep
+// theirs.
+func WithAttachments(store storage.AttachmentStore) Option {
+	return func(s *Service) {
+		s.attachments = store
+	}
+}
+
+// Event returns the event with id.
+func (s *Service) Event(id int) (model.Event, error) {
+	return s.store().GetByID(id)
+}
+
+// AddAttachment attaches the content read from r to the event as name.
+func (s *Service) AddAttachment(eventID int, name string, r io.Reader) (a storage.Attachment, err error) {
+	s, span := s.start("AddAttachment", attribute.Int("event_id", eventID))
+	defer func() {
+		span.SetAttributes(attribute.Int64("size", a.Size))
+		tracing.End(span, err)
+	}()
+
+	if s.attachments == nil {
+		return storage.Attachment{}, ErrAttachmentsDisabled
+	}
+	if _, err := s.clientEvent(eventID); err != nil {
+		return storage.Attachment{}, err
+	}
+	// The upload is not made under the lock of the owner, which would hold
+	// up the user's writes. An event deleted meanwhile has had its
+	// attachments dropped already, so the new one is removed here.
+	if a, err = s.attachments.Put(eventID, name, r); err != nil {
+		return storage.Attachment{}, err
+	}
+	_, unlock, err := s.lockEvent(eventID)
+	if err != nil {
+		if err := s.attachments.Delete(eventID, a.ID); err != nil {
+			log.Printf("Error delete attachment of deleted event %d: %v", eventID, err)
+		}
+		return storage.Attachment{}, err
+	}
+	unlock()
+	return a, nil
+}
+
+func (s *Service) Attachments(eventID int) ([]storage.Attachment, error) {
+	if s.attachments == nil {
+		return nil, ErrAttachmentsDisabled
+	}
+	if _, err := s.clientEvent(eventID); err != nil {
+		return nil, err
+	}
+	return s.attachments.List(eventID)
+}
+
+// OpenAttachment returns the attachment and its content, which the caller
+// closes.
+func (s *Service) OpenAttachment(eventID int, id string) (storage.Attachment, io.ReadCloser, error) {
+	if s.attachments == nil {
+		return storage.Attachment{}, nil, ErrAttachmentsDisabled
+	}
+	if _, err := s.clientEvent(eventID); err != nil {
+		return storage.Attachment{}, nil, err
+	}
+	return s.attachments.Open(eventID, id)
+}
+
+func (s *Service) DeleteAttachment(eventID int, id string) error {
+	if s.attachments == nil {
+		return ErrAttachmentsDisabled
+	}
+	event, unlock, err := s.lockEvent(eventID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.checkOwner(event); err != nil {
+		return err
+	}
+	return s.attachments.Delete(eventID, id)
+}
+
+// dropAttachments removes the attachments of a deleted event. The event is
+// already gone, so a failure is only logged.
+func (s *Service) dropAttachments(eventID int) {
+	if s.attachments == nil {
+		return
+	}
+	if err := s.attachments.DeleteAll(eventID); err != nil {
+		log.Printf("Error delete attachments of event %d: %v", eventID, err)
+	}
+}
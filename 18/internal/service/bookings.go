@@ -0,0 +1,258 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	maxBookingLinks = 20
+	// MaxBookingDays caps the days OpenSlots looks at in one call.
+	MaxBookingDays = 62
+	// BookingCategory is the category of booked events.
+	BookingCategory = "booking"
+)
+
+// ErrSlotUnavailable means the slot to book is not offered by the link, has
+// passed or overlaps an event, such as one booked meanwhile.
+var ErrSlotUnavailable = errors.New("slot is not available")
+
+// Slot is a span of time that can be booked.
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// WithBookingLinks stores booking links in store. Without it they are kept
+// in memory.
+func WithBookingLinks(store storage.BookingLinkStore) Option {
+	return func(s *Service) {
+		s.bookingLinks = store
+	}
+}
+
+func newBookingLinkStore() storage.BookingLinkStore {
+	return storage.NewInMemoryBookingLinkStore()
+}
+
+// CreateBookingLink stores l under a new random token and returns it as
+// stored.
+func (s *Service) CreateBookingLink(l model.BookingLink) (link model.BookingLink, err error) {
+	s, span := s.start("CreateBookingLink", attribute.Int("user_id", l.UserID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := normalizeBookingLink(&l); err != nil {
+		return model.BookingLink{}, err
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return model.BookingLink{}, err
+	}
+	l.Token = base64.RawURLEncoding.EncodeToString(token)
+
+	s.bookingLinksMu.Lock()
+	defer s.bookingLinksMu.Unlock()
+	existing, err := s.bookingLinks.ListByUser(l.UserID)
+	if err != nil {
+		return model.BookingLink{}, err
+	}
+	if len(existing) >= maxBookingLinks {
+		return model.BookingLink{}, fmt.Errorf("%w: at most %d booking links per user", ErrQuotaExceeded, maxBookingLinks)
+	}
+	if err := s.bookingLinks.Create(l); err != nil {
+		return model.BookingLink{}, err
+	}
+	return l, nil
+}
+
+// BookingLinks returns the user's booking links ordered by title.
+func (s *Service) BookingLinks(userID int) ([]model.BookingLink, error) {
+	return s.bookingLinks.ListByUser(userID)
+}
+
+// DeleteBookingLink removes the user's link; events booked through it stay.
+func (s *Service) DeleteBookingLink(userID int, token string) error {
+	l, err := s.bookingLinks.Get(token)
+	if err != nil {
+		return err
+	}
+	if l.UserID != userID {
+		return storage.ErrBookingLinkNotFound
+	}
+	return s.bookingLinks.Delete(token)
+}
+
+// OpenSlots returns the slots of the link from from to to, both included,
+// that have not started yet and keep the link's buffer to the user's
+// events. All-day events do not take time.
+func (s *Service) OpenSlots(token string, from, to time.Time) (slots []Slot, err error) {
+	s, span := s.start("OpenSlots",
+		attribute.String("from", from.Format(time.DateOnly)),
+		attribute.String("to", to.Format(time.DateOnly)))
+	defer func() { tracing.End(span, err) }()
+
+	from, to = midnight(from), midnight(to)
+	if to.Before(from) || to.Sub(from) >= MaxBookingDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: look at 1..%d days", ErrInvalidRange, MaxBookingDays)
+	}
+	l, err := s.bookingLinks.Get(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.openSlots(l, from, to)
+}
+
+// Book creates an event of the link's user in the open slot starting at
+// start, with the e-mail address as attendee, and returns it. Bookings are
+// serialized with the other event writes of that user, so a slot is booked
+// only once. They are left out of the user's history, so that undo does not
+// cancel someone else's booking.
+func (s *Service) Book(token string, start time.Time, name, email string) (event model.Event, err error) {
+	s, span := s.start("Book", attribute.String("start", start.Format(time.RFC3339)))
+	defer func() { tracing.End(span, err) }()
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return model.Event{}, fmt.Errorf("%w: name must be 1..100 characters", ErrInvalidEvent)
+	}
+	if strings.TrimSpace(email) == "" {
+		return model.Event{}, fmt.Errorf("%w: e-mail address is required", ErrInvalidEvent)
+	}
+	l, err := s.bookingLinks.Get(token)
+	if err != nil {
+		return model.Event{}, err
+	}
+	loc, err := time.LoadLocation(s.UserSettings(l.UserID).TimeZone)
+	if err != nil {
+		return model.Event{}, err
+	}
+	start = start.In(loc)
+	event = model.Event{
+		UserID:          l.UserID,
+		Date:            midnight(start),
+		Title:           l.Title + ": " + name,
+		StartTime:       start.Format("15:04"),
+		DurationMinutes: l.SlotMinutes,
+		Category:        BookingCategory,
+		Attendees:       []string{email},
+	}
+	if err := normalize(&event); err != nil {
+		return model.Event{}, err
+	}
+
+	defer s.locks.lock(l.UserID)()
+	open, err := s.openSlots(l, event.Date, event.Date)
+	if err != nil {
+		return model.Event{}, err
+	}
+	if !slices.ContainsFunc(open, func(slot Slot) bool { return slot.Start.Equal(start) }) {
+		return model.Event{}, fmt.Errorf("%w: %s", ErrSlotUnavailable, start.Format(time.RFC3339))
+	}
+	if event.ID, err = s.create(event); err != nil {
+		return model.Event{}, err
+	}
+	return event, nil
+}
+
+// openSlots lists the free slots of l on the days from from to to, given
+// as midnight UTC like event dates.
+func (s *Service) openSlots(l model.BookingLink, from, to time.Time) ([]Slot, error) {
+	loc, err := time.LoadLocation(s.UserSettings(l.UserID).TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	first, _ := time.Parse("15:04", l.From)
+	last, _ := time.Parse("15:04", l.To)
+	slot := time.Duration(l.SlotMinutes) * time.Minute
+	buffer := time.Duration(l.BufferMinutes) * time.Minute
+
+	var busy []Slot
+	// Events of the day before may last past midnight.
+	for day := from.AddDate(0, 0, -1); !day.After(to); day = day.AddDate(0, 0, 1) {
+		events, err := s.store().GetByDay(l.UserID, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if e.StartTime == "" {
+				continue
+			}
+			t, _ := time.Parse("15:04", e.StartTime)
+			start := localTime(day, t.Hour(), t.Minute(), loc)
+			// Events without a duration take the minute they start at.
+			busy = append(busy, Slot{Start: start, End: start.Add(max(time.Duration(e.DurationMinutes)*time.Minute, time.Minute))})
+		}
+	}
+
+	slots := []Slot{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !slices.Contains(l.Weekdays, day.Weekday()) {
+			continue
+		}
+		end := localTime(day, last.Hour(), last.Minute(), loc)
+		for start := localTime(day, first.Hour(), first.Minute(), loc); !start.Add(slot).After(end); start = start.Add(slot + buffer) {
+			candidate := Slot{Start: start, End: start.Add(slot)}
+			if !candidate.Start.After(now) {
+				continue
+			}
+			if !slices.ContainsFunc(busy, func(b Slot) bool {
+				return b.Start.Before(candidate.End.Add(buffer)) && b.End.After(candidate.Start.Add(-buffer))
+			}) {
+				slots = append(slots, candidate)
+			}
+		}
+	}
+	return slots, nil
+}
+
+func localTime(date time.Time, hour, minute int, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, loc)
+}
+
+// normalizeBookingLink validates the rules of l and sorts its weekdays.
+func normalizeBookingLink(l *model.BookingLink) error {
+	l.Title = strings.TrimSpace(l.Title)
+	if l.Title == "" {
+		return fmt.Errorf("%w: booking link title is required", ErrInvalidEvent)
+	}
+	if len(l.Weekdays) == 0 {
+		return fmt.Errorf("%w: booking link needs at least one weekday", ErrInvalidEvent)
+	}
+	for _, d := range l.Weekdays {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("%w: invalid weekday %d", ErrInvalidEvent, d)
+		}
+	}
+	slices.Sort(l.Weekdays)
+	l.Weekdays = slices.Compact(l.Weekdays)
+
+	from, err := time.Parse("15:04", l.From)
+	if err != nil {
+		return fmt.Errorf("%w: from must look like 09:30, got %q", ErrInvalidEvent, l.From)
+	}
+	to, err := time.Parse("15:04", l.To)
+	if err != nil {
+		return fmt.Errorf("%w: to must look like 17:00, got %q", ErrInvalidEvent, l.To)
+	}
+	if l.SlotMinutes < 5 || l.SlotMinutes > 8*60 {
+		return fmt.Errorf("%w: slot must be within 5..%d minutes", ErrInvalidEvent, 8*60)
+	}
+	if l.BufferMinutes < 0 || l.BufferMinutes > 4*60 {
+		return fmt.Errorf("%w: buffer must be within 0..%d minutes", ErrInvalidEvent, 4*60)
+	}
+	if to.Sub(from) < time.Duration(l.SlotMinutes)*time.Minute {
+		return fmt.Errorf("%w: from %s to %s does not fit one slot", ErrInvalidEvent, l.From, l.To)
+	}
+	return nil
+}
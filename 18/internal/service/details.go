@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"wb_l12/18/internal/model"
+)
+
+// ErrInvalidEvent means the event fields do not pass validation.
+var ErrInvalidEvent = errors.New("invalid event")
+
+const (
+	maxTags      = 20
+	maxAttendees = 100
+	maxReminders = 5
+	// maxReminder is the earliest reminder, a week before the event.
+	maxReminder = 7 * 24 * 60
+)
+
+var colorRe = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// normalize trims the optional event fields, lowercases tags, attendees and
+// the color, drops empty and duplicate tags, attendees and reminders, sorts
+// reminders and validates the result.
+func normalize(e *model.Event) error {
+	e.Location = strings.TrimSpace(e.Location)
+	e.Category = strings.TrimSpace(e.Category)
+	e.Color = strings.ToLower(strings.TrimSpace(e.Color))
+	if e.Color != "" && !colorRe.MatchString(e.Color) {
+		return fmt.Errorf("%w: color must look like #1a2b3c, got %q", ErrInvalidEvent, e.Color)
+	}
+
+	if e.StartTime != "" {
+		if _, err := time.Parse("15:04", e.StartTime); err != nil {
+			return fmt.Errorf("%w: start_time must look like 09:30, got %q", ErrInvalidEvent, e.StartTime)
+		}
+	}
+	if e.DurationMinutes < 0 || e.DurationMinutes > 24*60 {
+		return fmt.Errorf("%w: duration must be within 0..%d minutes", ErrInvalidEvent, 24*60)
+	}
+
+	var tags []string
+	seen := make(map[string]bool, len(e.Tags))
+	for _, t := range e.Tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	if len(tags) > maxTags {
+		return fmt.Errorf("%w: at most %d tags allowed", ErrInvalidEvent, maxTags)
+	}
+	e.Tags = tags
+
+	var attendees []string
+	seen = make(map[string]bool, len(e.Attendees))
+	for _, a := range e.Attendees {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(a)
+		if err != nil || addr.Name != "" {
+			return fmt.Errorf("%w: attendee must be an e-mail address, got %q", ErrInvalidEvent, a)
+		}
+		a = strings.ToLower(addr.Address)
+		if !seen[a] {
+			seen[a] = true
+			attendees = append(attendees, a)
+		}
+	}
+	if len(attendees) > maxAttendees {
+		return fmt.Errorf("%w: at most %d attendees allowed", ErrInvalidEvent, maxAttendees)
+	}
+	e.Attendees = attendees
+
+	var reminders []int
+	for _, r := range e.Reminders {
+		if r < 0 || r > maxReminder {
+			return fmt.Errorf("%w: reminders must be within 0..%d minutes", ErrInvalidEvent, maxReminder)
+		}
+		if !slices.Contains(reminders, r) {
+			reminders = append(reminders, r)
+		}
+	}
+	if len(reminders) > maxReminders {
+		return fmt.Errorf("%w: at most %d reminders allowed", ErrInvalidEvent, maxReminders)
+	}
+	slices.Sort(reminders)
+	e.Reminders = reminders
+	return nil
+}
+
+// Filter narrows event queries. Empty fields match every event; both are
+// compared case-insensitively.
+type Filter struct {
+	Tag      string
+	Category string
+}
+
+func (f Filter) match(e model.Event) bool {
+	if f.Category != "" && !strings.EqualFold(f.Category, e.Category) {
+		return false
+	}
+	if f.Tag == "" {
+		return true
+	}
+	for _, t := range e.Tags {
+		if strings.EqualFold(f.Tag, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) apply(events []model.Event, err error) ([]model.Event, error) {
+	if err != nil || f == (Filter{}) {
+		return events, err
+	}
+	var res []model.Event
+	for _, e := range events {
+		if f.match(e) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// historySize is how many commands of a user can be undone.
+const historySize = 50
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrEventChanged means an event touched by the command to undo or redo
+	// was changed by a later command.
+	ErrEventChanged = errors.New("event changed since")
+)
+
+// Revision describes an undone or redone command.
+type Revision struct {
+	// Action is the method that made the command, such as "update_event".
+	Action string `json:"action"`
+	// Events are the created or updated events as they are now.
+	Events []model.Event `json:"events"`
+	// Deleted are the IDs of the removed events.
+	Deleted []int `json:"deleted,omitempty"`
+}
+
+// step is the change of one event; a nil before or after means the event
+// did not exist.
+type step struct {
+	before, after *model.Event
+}
+
+// command is one mutation made by a user, which may touch several events.
+type command struct {
+	action string
+	steps  []step
+}
+
+type history struct {
+	mu    sync.Mutex
+	users map[int]*userHistory
+}
+
+type userHistory struct {
+	undo, redo []*command
+}
+
+// user returns the history of userID; h.mu must be held.
+func (h *history) user(userID int) *userHistory {
+	if h.users == nil {
+		h.users = make(map[int]*userHistory)
+	}
+	u, ok := h.users[userID]
+	if !ok {
+		u = &userHistory{}
+		h.users[userID] = u
+	}
+	return u
+}
+
+// record remembers a new command of the user and forgets the undone ones.
+// Inside a batch the steps are added to the batch instead.
+func (s *Service) record(action string, userID int, st step) {
+	if s.batch != nil {
+		s.batch.steps = append(s.batch.steps, st)
+		return
+	}
+	s.recordCommand(userID, command{action: action, steps: []step{st}})
+}
+
+func (s *Service) recordCommand(userID int, cmd command) {
+	if len(cmd.steps) == 0 {
+		return
+	}
+	s.history.mu.Lock()
+	defer s.history.mu.Unlock()
+	u := s.history.user(userID)
+	u.undo = append(u.undo, &cmd)
+	if len(u.undo) > historySize {
+		u.undo = append(u.undo[:0:0], u.undo[len(u.undo)-historySize:]...)
+	}
+	u.redo = nil
+}
+
+// batched returns s recording its changes into one command; recordCommand
+// stores it once all of them are made.
+func (s *Service) batched(action string) (*Service, *command) {
+	cmd := &command{action: action}
+	return &Service{state: s.state, ctx: s.ctx, batch: cmd}, cmd
+}
+
+// Undo reverts the user's latest command that is not undone yet. It is
+// refused with ErrEventChanged, and the history kept, if one of its events
+// was changed since. Attachments of deleted events are not restored.
+func (s *Service) Undo(userID int) (rev Revision, err error) {
+	s, span := s.start("Undo", attribute.Int("user_id", userID))
+	defer func() { tracing.End(span, err) }()
+	return s.replay(userID, true)
+}
+
+// Redo makes the user's latest undone command again, under the same rules
+// as Undo.
+func (s *Service) Redo(userID int) (rev Revision, err error) {
+	s, span := s.start("Redo", attribute.Int("user_id", userID))
+	defer func() { tracing.End(span, err) }()
+	return s.replay(userID, false)
+}
+
+func (s *Service) replay(userID int, undo bool) (Revision, error) {
+	s.history.mu.Lock()
+	u := s.history.user(userID)
+	from, to, empty := &u.undo, &u.redo, ErrNothingToUndo
+	if !undo {
+		from, to, empty = &u.redo, &u.undo, ErrNothingToRedo
+	}
+	if len(*from) == 0 {
+		s.history.mu.Unlock()
+		return Revision{}, empty
+	}
+	// Other commands of the user may be pushed while this one is applied,
+	// so it is removed by identity rather than as the top of the stack.
+	cmd := (*from)[len(*from)-1]
+	s.history.mu.Unlock()
+
+	// Every event of the command is owned by one of these users unless it
+	// changed since, which expect refuses.
+	users := []int{userID}
+	for _, st := range cmd.steps {
+		for _, e := range []*model.Event{st.before, st.after} {
+			if e != nil {
+				users = append(users, e.UserID)
+			}
+		}
+	}
+	defer s.locks.lock(users...)()
+
+	steps := cmd.steps
+	if undo {
+		steps = make([]step, len(cmd.steps))
+		for i, st := range cmd.steps {
+			steps[len(steps)-1-i] = step{before: st.after, after: st.before}
+		}
+	}
+	rev, err := s.applySteps(steps)
+	if err != nil {
+		return Revision{}, err
+	}
+	rev.Action = cmd.action
+
+	s.history.mu.Lock()
+	for i := len(*from) - 1; i >= 0; i-- {
+		if (*from)[i] == cmd {
+			*from = append((*from)[:i], (*from)[i+1:]...)
+			break
+		}
+	}
+	*to = append(*to, cmd)
+	s.history.mu.Unlock()
+	return rev, nil
+}
+
+// applySteps makes all steps or none; the locks of the users of the steps
+// must be held. Every event
+// must still be as the step expects it before anything is changed.
+func (s *Service) applySteps(steps []step) (Revision, error) {
+	for _, st := range steps {
+		if err := s.expect(st.before, st.after); err != nil {
+			return Revision{}, err
+		}
+	}
+
+	var rev Revision
+	for i, st := range steps {
+		if err := s.applyStep(st, true); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if err := s.applyStep(step{before: steps[j].after, after: steps[j].before}, false); err != nil {
+					log.Printf("Error roll back event change after failed undo: %v", err)
+				}
+			}
+			return Revision{}, err
+		}
+		if st.after != nil {
+			rev.Events = append(rev.Events, *st.after)
+		} else {
+			rev.Deleted = append(rev.Deleted, st.before.ID)
+		}
+	}
+	for _, id := range rev.Deleted {
+		s.dropAttachments(id)
+	}
+	return rev, nil
+}
+
+// expect checks that the event of the step is still as it was left by the
+// command: before, or absent for nil.
+func (s *Service) expect(before, after *model.Event) error {
+	var id int
+	if before != nil {
+		id = before.ID
+	} else {
+		id = after.ID
+	}
+	current, err := s.store().GetByID(id)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		if before != nil {
+			return fmt.Errorf("%w: event %d was deleted", ErrEventChanged, id)
+		}
+		return nil
+	case err != nil:
+		return err
+	case before == nil:
+		return fmt.Errorf("%w: event %d exists again", ErrEventChanged, id)
+	case !sameEvent(current, *before):
+		return fmt.Errorf("%w: event %d was updated", ErrEventChanged, id)
+	}
+	return nil
+}
+
+// applyStep changes the event from st.before to st.after. Deleted events
+// are restored with their IDs.
+func (s *Service) applyStep(st step, checkQuota bool) error {
+	switch {
+	case st.after == nil:
+		if err := s.store().Delete(st.before.ID); err != nil {
+			return err
+		}
+		s.publish(ChangeDeleted, *st.before)
+		return nil
+	case checkQuota:
+		if err := s.checkQuota(st.before, st.after.UserID, st.after.Date); err != nil {
+			return err
+		}
+	}
+	if st.before == nil {
+		if err := s.store().Import([]model.Event{*st.after}); err != nil {
+			return err
+		}
+		s.publish(ChangeCreated, *st.after)
+		return nil
+	}
+	event := *st.after
+	if err := s.store().Update(&event); err != nil {
+		return err
+	}
+	s.publish(ChangeUpdated, event)
+	return nil
+}
+
+func sameEvent(a, b model.Event) bool {
+	if !a.Date.Equal(b.Date) {
+		return false
+	}
+	a.Date, b.Date = time.Time{}, time.Time{}
+	return reflect.DeepEqual(a, b)
+}
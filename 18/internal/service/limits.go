@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrQuotaExceeded means the user has reached the total number of events.
+	ErrQuotaExceeded = errors.New("event quota exceeded")
+	// ErrDailyQuotaExceeded means the user has reached the number of events
+	// for that day.
+	ErrDailyQuotaExceeded = errors.New("daily event quota exceeded")
+)
+
+// Quota limits events per user. Zero values mean no limit.
+type Quota struct {
+	MaxEventsPerUser int
+	MaxEventsPerDay  int
+}
+
+// Archiver stores events removed by the retention job. Without an archiver
+// old events are purged.
+type Archiver interface {
+	Archive(events []model.Event) error
+}
+
+// RetentionPolicy removes events older than Months months.
+type RetentionPolicy struct {
+	Months   int
+	Archiver Archiver
+}
+
+type Option func(*Service)
+
+func WithQuota(q Quota) Option {
+	return func(s *Service) {
+		s.quota = q
+	}
+}
+
+func WithRetention(p RetentionPolicy) Option {
+	return func(s *Service) {
+		s.retention = p
+	}
+}
+
+// Stats are counters for administrators.
+type Stats struct {
+	QuotaRejectedTotal int64     `json:"quota_rejected_total"`
+	QuotaRejectedDaily int64     `json:"quota_rejected_daily"`
+	RetentionRuns      int64     `json:"retention_runs"`
+	EventsArchived     int64     `json:"events_archived"`
+	EventsPurged       int64     `json:"events_purged"`
+	LastRetentionRun   time.Time `json:"last_retention_run,omitempty"`
+	// Cache is set when the storage is a cache.
+	Cache *storage.CacheStats `json:"cache,omitempty"`
+}
+
+type counters struct {
+	quotaRejectedTotal atomic.Int64
+	quotaRejectedDaily atomic.Int64
+	retentionRuns      atomic.Int64
+	eventsArchived     atomic.Int64
+	eventsPurged       atomic.Int64
+	lastRetentionRun   atomic.Int64
+}
+
+func (s *Service) Stats() Stats {
+	st := Stats{
+		QuotaRejectedTotal: s.counters.quotaRejectedTotal.Load(),
+		QuotaRejectedDaily: s.counters.quotaRejectedDaily.Load(),
+		RetentionRuns:      s.counters.retentionRuns.Load(),
+		EventsArchived:     s.counters.eventsArchived.Load(),
+		EventsPurged:       s.counters.eventsPurged.Load(),
+	}
+	if last := s.counters.lastRetentionRun.Load(); last != 0 {
+		st.LastRetentionRun = time.Unix(0, last).UTC()
+	}
+	if c, ok := find[interface{ Stats() storage.CacheStats }](s.storage); ok {
+		cache := c.Stats()
+		st.Cache = &cache
+	}
+	return st
+}
+
+// checkQuota must be called with the lock of userID held so that concurrent
+// creates cannot both pass the check. current is the event being moved by
+// an update as it is now, or nil for a new event. Events given to another
+// user count against that user's total.
+func (s *Service) checkQuota(current *model.Event, userID int, date time.Time) error {
+	id := 0
+	if current != nil {
+		id = current.ID
+	}
+	if max := s.quota.MaxEventsPerUser; max > 0 {
+		if current == nil || current.UserID != userID {
+			n, err := s.store().CountByUser(userID)
+			if err != nil {
+				return err
+			}
+			if n >= max {
+				s.counters.quotaRejectedTotal.Add(1)
+				return fmt.Errorf("%w: at most %d events per user", ErrQuotaExceeded, max)
+			}
+		}
+	}
+	if max := s.quota.MaxEventsPerDay; max > 0 {
+		events, err := s.store().GetByDay(userID, date)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, e := range events {
+			if e.ID != id {
+				n++
+			}
+		}
+		if n >= max {
+			s.counters.quotaRejectedDaily.Add(1)
+			return fmt.Errorf("%w: at most %d events per day", ErrDailyQuotaExceeded, max)
+		}
+	}
+	return nil
+}
+
+// ApplyRetention archives or purges events older than the retention policy
+// allows, relative to now. It is a no-op without a policy.
+func (s *Service) ApplyRetention(now time.Time) (removed int, err error) {
+	if s.retention.Months <= 0 {
+		return 0, nil
+	}
+	s, span := s.start("ApplyRetention", attribute.Int("months", s.retention.Months))
+	defer func() {
+		span.SetAttributes(attribute.Int("events", removed))
+		tracing.End(span, err)
+	}()
+	s.counters.retentionRuns.Add(1)
+	s.counters.lastRetentionRun.Store(now.UnixNano())
+
+	cutoff := now.AddDate(0, -s.retention.Months, 0)
+	events, err := s.store().GetBefore(cutoff)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	if s.retention.Archiver != nil {
+		if err := s.retention.Archiver.Archive(events); err != nil {
+			return 0, fmt.Errorf("archive events: %w", err)
+		}
+	}
+
+	for _, e := range events {
+		e, ok, err := s.expire(e.ID, cutoff)
+		if err != nil {
+			return removed, err
+		}
+		if !ok {
+			continue
+		}
+		removed++
+		if s.retention.Archiver == nil {
+			s.dropAttachments(e.ID)
+		}
+		s.publish(ChangeDeleted, e)
+	}
+	if s.retention.Archiver != nil {
+		s.counters.eventsArchived.Add(int64(removed))
+	} else {
+		s.counters.eventsPurged.Add(int64(removed))
+	}
+	return removed, nil
+}
+
+// expire deletes the event with id under the lock of its owner if it is
+// still dated before cutoff. Events deleted or moved since are skipped.
+func (s *Service) expire(id int, cutoff time.Time) (model.Event, bool, error) {
+	event, unlock, err := s.lockEvent(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return model.Event{}, false, nil
+	}
+	if err != nil {
+		return model.Event{}, false, err
+	}
+	defer unlock()
+	if !event.Date.Before(cutoff) {
+		return model.Event{}, false, nil
+	}
+	if err := s.store().Delete(id); err != nil {
+		return model.Event{}, false, err
+	}
+	return event, true, nil
+}
+
+// RunRetention applies the retention policy every interval until ctx is done.
+func (s *Service) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.ApplyRetention(time.Now()); err != nil {
+			log.Printf("Error apply retention: %v", err)
+		} else if n > 0 {
+			log.Printf("Retention removed %d events", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"slices"
+	"sync"
+	"wb_l12/18/internal/model"
+)
+
+// userLocks serializes the event writes of each user, so that quota checks
+// and undo see no concurrent changes to the user's events while writes of
+// other users go ahead. Users share a fixed number of stripes.
+type userLocks [64]sync.Mutex
+
+// lock locks the stripes of userIDs in a fixed order, so that writes
+// touching several users cannot deadlock, and returns the unlock function.
+func (l *userLocks) lock(userIDs ...int) func() {
+	stripes := make([]int, 0, len(userIDs))
+	for _, id := range userIDs {
+		stripes = append(stripes, int(uint(id)%uint(len(l))))
+	}
+	slices.Sort(stripes)
+	stripes = slices.Compact(stripes)
+	for _, i := range stripes {
+		l[i].Lock()
+	}
+	return func() {
+		for _, i := range slices.Backward(stripes) {
+			l[i].Unlock()
+		}
+	}
+}
+
+// lockEvent locks the owner of the event with id together with userIDs and
+// returns the event as it is under the lock. Every write of an event holds
+// the lock of its owner, so the event cannot change hands until unlock.
+func (s *Service) lockEvent(id int, userIDs ...int) (model.Event, func(), error) {
+	for {
+		event, err := s.store().GetByID(id)
+		if err != nil {
+			return model.Event{}, nil, err
+		}
+		unlock := s.locks.lock(append(userIDs, event.UserID)...)
+		current, err := s.store().GetByID(id)
+		if err == nil && current.UserID == event.UserID {
+			return current, unlock, nil
+		}
+		unlock()
+		if err != nil {
+			return model.Event{}, nil, err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"wb_l12/18/internal/model"
+)
+
+// ErrNotOwner means the client of a request changed or read an event of
+// another user.
+var ErrNotOwner = errors.New("event belongs to another user")
+
+type clientKey struct{}
+
+// WithClient marks ctx as acting for the user with userID, as established
+// from a client certificate. Services bound to ctx refuse events of other
+// users with ErrNotOwner.
+func WithClient(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, clientKey{}, userID)
+}
+
+// checkOwner refuses events that do not belong to the client of s, if any.
+func (s *Service) checkOwner(events ...model.Event) error {
+	client, ok := s.ctx.Value(clientKey{}).(int)
+	if !ok {
+		return nil
+	}
+	for _, e := range events {
+		if e.UserID != client {
+			return ErrNotOwner
+		}
+	}
+	return nil
+}
+
+// clientEvent returns the event with id if it belongs to the client of s.
+func (s *Service) clientEvent(id int) (model.Event, error) {
+	event, err := s.store().GetByID(id)
+	if err != nil {
+		return model.Event{}, err
+	}
+	if err := s.checkOwner(event); err != nil {
+		return model.Event{}, err
+	}
+	return event, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/quickadd"
+	"wb_l12/18/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// QuickAddResult is the event read from a quick-add phrase. ID is zero when
+// the event was only parsed.
+type QuickAddResult struct {
+	ID       int         `json:"id,omitempty"`
+	Event    model.Event `json:"event"`
+	Warnings []string    `json:"warnings"`
+}
+
+// QuickAdd parses text such as "Standup tomorrow at 10:00 for 15m" relative
+// to the current time in the user's time zone and, if create is set, stores
+// the event.
+func (s *Service) QuickAdd(userID int, text string, create bool) (res QuickAddResult, err error) {
+	s, span := s.start("QuickAdd", attribute.Int("user_id", userID), attribute.Bool("create", create))
+	defer func() { tracing.End(span, err) }()
+
+	loc, err := time.LoadLocation(s.UserSettings(userID).TimeZone)
+	if err != nil {
+		return QuickAddResult{}, err
+	}
+	parsed, err := quickadd.Parse(text, s.now().In(loc))
+	if err != nil {
+		return QuickAddResult{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+
+	res = QuickAddResult{
+		Event: model.Event{
+			UserID: userID,
+			// Dates are stored as UTC midnight like the ones of the API.
+			Date:            time.Date(parsed.Date.Year(), parsed.Date.Month(), parsed.Date.Day(), 0, 0, 0, 0, time.UTC),
+			Title:           parsed.Title,
+			StartTime:       parsed.Start,
+			DurationMinutes: int(parsed.Duration / time.Minute),
+		},
+		Warnings: parsed.Warnings,
+	}
+	if res.Warnings == nil {
+		res.Warnings = []string{}
+	}
+	if !create {
+		return res, nil
+	}
+	res.ID, err = s.CreateEvent(res.Event)
+	if err != nil {
+		return QuickAddResult{}, err
+	}
+	res.Event.ID = res.ID
+	return res, nil
+}
@@ -1,49 +1,165 @@
 package service
 
 import (
+	"context"
+	"sync"
 	"time"
 	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
 	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// Service is the calendar of one tenant. The copies returned by For share
+// its state and carry the context of a request, under which method and
+// storage calls are traced.
 type Service struct {
-	storage storage.Storage
+	*state
+	ctx context.Context
+	// batch collects the changes of a command made of several calls.
+	batch *command
 }
 
-func NewService(storage storage.Storage) *Service {
-	return &Service{storage: storage}
+type state struct {
+	storage  storage.Storage
+	watchers watchers
+
+	// locks serializes the event writes of each user, so that quota checks
+	// and undo see no concurrent changes.
+	quota     Quota
+	locks     userLocks
+	retention RetentionPolicy
+	counters  counters
+	work      workCalendars
+	now       func() time.Time
+
+	attachments storage.AttachmentStore
+
+	templates   storage.TemplateStore
+	templatesMu sync.Mutex
+
+	bookingLinks   storage.BookingLinkStore
+	bookingLinksMu sync.Mutex
+
+	settings storage.UserSettingsStore
+
+	history history
 }
 
-func (s *Service) CreateEvent(userID int, date time.Time, title string) (int, error) {
-	event := &model.Event{
-		UserID: userID,
-		Date:   date,
-		Title:  title,
+func NewService(storage storage.Storage, opts ...Option) *Service {
+	s := &Service{state: &state{storage: storage, now: time.Now, templates: newTemplateStore(), bookingLinks: newBookingLinkStore(), settings: newUserSettingsStore()}, ctx: context.Background()}
+	for _, opt := range opts {
+		opt(s)
 	}
-	return s.storage.Create(event)
+	s.storage = withChangeLog(s.storage)
+	return s
 }
 
-func (s *Service) UpdateEvent(id, userID int, date time.Time, title string) error {
-	event := &model.Event{
-		ID:     id,
-		UserID: userID,
-		Date:   date,
-		Title:  title,
+// CreateEvent stores event as a new event of event.UserID and returns its ID.
+func (s *Service) CreateEvent(event model.Event) (id int, err error) {
+	s, span := s.start("CreateEvent", attribute.Int("user_id", event.UserID))
+	defer func() { tracing.End(span, err) }()
+
+	event.ID = 0
+	if err := normalize(&event); err != nil {
+		return 0, err
 	}
-	return s.storage.Update(event)
+
+	defer s.locks.lock(event.UserID)()
+	if event.ID, err = s.create(event); err != nil {
+		return 0, err
+	}
+	s.record("create_event", event.UserID, step{after: &event})
+	return event.ID, nil
 }
 
-func (s *Service) DeleteEvent(id int) error {
-	return s.storage.Delete(id)
+// create stores a normalized event without recording it in the history;
+// the lock of event.UserID must be held.
+func (s *Service) create(event model.Event) (int, error) {
+	if err := s.checkQuota(nil, event.UserID, event.Date); err != nil {
+		return 0, err
+	}
+	id, err := s.store().Create(&event)
+	if err != nil {
+		return 0, err
+	}
+	event.ID = id
+	s.publish(ChangeCreated, event)
+	return id, nil
 }
 
-func (s *Service) GetByDay(userID int, date time.Time) ([]model.Event, error) {
-	return s.storage.GetByDay(userID, date)
+// UpdateEvent replaces the event with event.ID.
+func (s *Service) UpdateEvent(event model.Event) (err error) {
+	s, span := s.start("UpdateEvent", attribute.Int("user_id", event.UserID), attribute.Int("event_id", event.ID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := normalize(&event); err != nil {
+		return err
+	}
+
+	before, unlock, err := s.lockEvent(event.ID, event.UserID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.checkOwner(before, event); err != nil {
+		return err
+	}
+	if err := s.checkQuota(&before, event.UserID, event.Date); err != nil {
+		return err
+	}
+	if err := s.store().Update(&event); err != nil {
+		return err
+	}
+	s.record("update_event", event.UserID, step{before: &before, after: &event})
+	s.publish(ChangeUpdated, event)
+	return nil
+}
+
+func (s *Service) DeleteEvent(id int) (err error) {
+	s, span := s.start("DeleteEvent", attribute.Int("event_id", id))
+	defer func() { tracing.End(span, err) }()
+
+	event, unlock, err := s.lockEvent(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.checkOwner(event); err != nil {
+		return err
+	}
+	if err := s.store().Delete(id); err != nil {
+		return err
+	}
+	s.record("delete_event", event.UserID, step{before: &event})
+	s.dropAttachments(id)
+	s.publish(ChangeDeleted, event)
+	return nil
 }
 
-func (s *Service) GetByWeek(userID int, date time.Time) ([]model.Event, error) {
-	return s.storage.GetByWeek(userID, date)
+func (s *Service) GetByDay(userID int, date time.Time, f Filter) (events []model.Event, err error) {
+	s, span := s.start("GetByDay", windowAttrs(userID, date)...)
+	defer func() { tracing.End(span, err) }()
+	return f.apply(s.store().GetByDay(userID, date))
 }
-func (s *Service) GetByMonth(userID int, date time.Time) ([]model.Event, error) {
-	return s.storage.GetByMonth(userID, date)
+
+// GetByWeek returns events of the user's week containing date. Weeks start
+// on the user's first weekday, Monday by default.
+func (s *Service) GetByWeek(userID int, date time.Time, f Filter) (events []model.Event, err error) {
+	s, span := s.start("GetByWeek", windowAttrs(userID, date)...)
+	defer func() { tracing.End(span, err) }()
+	start := weekStart(date, s.UserSettings(userID).FirstWeekday)
+	return f.apply(s.userWeek(userID, start))
+}
+func (s *Service) GetByMonth(userID int, date time.Time, f Filter) (events []model.Event, err error) {
+	s, span := s.start("GetByMonth", windowAttrs(userID, date)...)
+	defer func() { tracing.End(span, err) }()
+	return f.apply(s.store().GetByMonth(userID, date))
+}
+
+func (s *Service) Search(userID int, query string, page, perPage int) (events []model.Event, total int, err error) {
+	s, span := s.start("Search", attribute.Int("user_id", userID), attribute.Int("page", page))
+	defer func() { tracing.End(span, err) }()
+	return s.store().Search(userID, query, (page-1)*perPage, perPage)
 }
@@ -1,17 +1,31 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 
+	"wb_l12/18/internal/holiday"
+	"wb_l12/18/internal/model"
 	"wb_l12/18/pkg/storage"
 )
 
 func TestCreateEvent_Success(t *testing.T) {
 	service := NewService(storage.NewInMemoryStorage())
-	id, err := service.CreateEvent(1, time.Now(), "Test")
+	id, err := service.CreateEvent(model.Event{UserID: 1, Date: time.Now(), Title: "Test"})
 	assert.NoError(t, err)
 	assert.Greater(t, id, 0)
 }
@@ -20,18 +34,18 @@ func TestGetByDay_ReturnsMatchingEvents(t *testing.T) {
 	service := NewService(storage.NewInMemoryStorage())
 	now := time.Now()
 
-	service.CreateEvent(1, now, "Event 1")
-	service.CreateEvent(1, now, "Event 2")
-	service.CreateEvent(2, now, "Other user")
+	service.CreateEvent(model.Event{UserID: 1, Date: now, Title: "Event 1"})
+	service.CreateEvent(model.Event{UserID: 1, Date: now, Title: "Event 2"})
+	service.CreateEvent(model.Event{UserID: 2, Date: now, Title: "Other user"})
 
-	events, _ := service.GetByDay(1, now)
+	events, _ := service.GetByDay(1, now, Filter{})
 
 	assert.Len(t, events, 2)
 }
 
 func TestGetByDay_WithNoEvents(t *testing.T) {
 	service := NewService(storage.NewInMemoryStorage())
-	events, err := service.GetByDay(1, time.Now())
+	events, err := service.GetByDay(1, time.Now(), Filter{})
 	assert.NoError(t, err)
 	assert.Empty(t, events)
 }
@@ -40,12 +54,12 @@ func TestUpdateEvent_Success(t *testing.T) {
 	storage := storage.NewInMemoryStorage()
 	service := NewService(storage)
 
-	id, _ := service.CreateEvent(1, time.Now(), "Old Title")
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: time.Now(), Title: "Old Title"})
 
-	err := service.UpdateEvent(id, 1, time.Now(), "New Title")
+	err := service.UpdateEvent(model.Event{ID: id, UserID: 1, Date: time.Now(), Title: "New Title"})
 	assert.NoError(t, err)
 
-	events, _ := service.GetByDay(1, time.Now())
+	events, _ := service.GetByDay(1, time.Now(), Filter{})
 	assert.Equal(t, "New Title", events[0].Title)
 }
 
@@ -53,12 +67,12 @@ func TestDeleteEvent_RemovesEvent(t *testing.T) {
 	storage := storage.NewInMemoryStorage()
 	service := NewService(storage)
 
-	id, _ := service.CreateEvent(1, time.Now(), "To delete")
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: time.Now(), Title: "To delete"})
 
 	err := service.DeleteEvent(id)
 	assert.NoError(t, err)
 
-	events, _ := service.GetByDay(1, time.Now())
+	events, _ := service.GetByDay(1, time.Now(), Filter{})
 	assert.Empty(t, events)
 }
 
@@ -69,10 +83,857 @@ func TestGetByWeek_IncludesEventsFromSameWeek(t *testing.T) {
 	wednesday := time.Date(2023, 12, 27, 0, 0, 0, 0, time.UTC)
 	tuesday := time.Date(2023, 12, 26, 0, 0, 0, 0, time.UTC)
 
-	service.CreateEvent(1, tuesday, "Meeting")
-	service.CreateEvent(1, wednesday, "Party")
+	service.CreateEvent(model.Event{UserID: 1, Date: tuesday, Title: "Meeting"})
+	service.CreateEvent(model.Event{UserID: 1, Date: wednesday, Title: "Party"})
 
-	events, err := service.GetByWeek(1, wednesday)
+	events, err := service.GetByWeek(1, wednesday, Filter{})
 	assert.NoError(t, err)
 	assert.Len(t, events, 2)
 }
+
+func TestCreateEvent_NormalizesDetails(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Trip", Category: " Travel ", Color: "#A1B2C3", Tags: []string{" Flight", "", "flight", "hotel"}})
+	assert.NoError(t, err)
+	events, _ := service.GetByDay(1, day, Filter{})
+	assert.Equal(t, "Travel", events[0].Category)
+	assert.Equal(t, "#a1b2c3", events[0].Color)
+	assert.Equal(t, []string{"flight", "hotel"}, events[0].Tags)
+
+	_, err = service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Bad", Color: "red"})
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+	tags := make([]string, maxTags+1)
+	for i := range tags {
+		tags[i] = fmt.Sprint("tag", i)
+	}
+	_, err = service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Bad", Tags: tags})
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+}
+
+func TestGetByWeek_Filter(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Standup", Category: "Work", Tags: []string{"daily"}})
+	service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Gym", Category: "Personal", Tags: []string{"daily"}})
+	service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Review", Category: "work"})
+
+	events, _ := service.GetByWeek(1, day, Filter{Category: "WORK"})
+	assert.Len(t, events, 2)
+	events, _ = service.GetByWeek(1, day, Filter{Tag: "daily", Category: "work"})
+	assert.Len(t, events, 1)
+	events, _ = service.GetByWeek(1, day, Filter{Tag: "weekly"})
+	assert.Empty(t, events)
+}
+
+func TestWatch_ReceivesOwnChanges(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	w := service.Watch(1)
+	defer service.Unwatch(w)
+
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: time.Now(), Title: "Mine"})
+	service.CreateEvent(model.Event{UserID: 2, Date: time.Now(), Title: "Other user"})
+	service.UpdateEvent(model.Event{ID: id, UserID: 1, Date: time.Now(), Title: "Renamed"})
+	service.DeleteEvent(id)
+
+	var got []ChangeType
+	for i := 0; i < 3; i++ {
+		change := <-w.Changes()
+		assert.Equal(t, id, change.Event.ID)
+		got = append(got, change.Type)
+	}
+	assert.Equal(t, []ChangeType{ChangeCreated, ChangeUpdated, ChangeDeleted}, got)
+	assert.Empty(t, w.Changes())
+}
+
+func TestWatch_SlowWatcherIsDisconnected(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	w := service.Watch(1)
+
+	for i := 0; i <= watcherBuffer; i++ {
+		service.CreateEvent(model.Event{UserID: 1, Date: time.Now(), Title: "Spam"})
+	}
+	for range w.Changes() {
+	}
+	assert.ErrorIs(t, w.Err(), ErrWatcherTooSlow)
+}
+
+func TestCreateEvent_AttendeesAndReminders(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	id, err := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Sync",
+		Attendees: []string{" Ann@Example.com", "ann@example.com", "", "bob@example.com"}, Reminders: []int{30, 5, 30}})
+	assert.NoError(t, err)
+	event, _ := service.Event(id)
+	assert.Equal(t, []string{"ann@example.com", "bob@example.com"}, event.Attendees)
+	assert.Equal(t, []int{5, 30}, event.Reminders)
+
+	for _, bad := range []model.Event{
+		{Attendees: []string{"not an address"}},
+		{Attendees: []string{"Ann <ann@example.com>"}},
+		{Reminders: []int{-1}},
+		{Reminders: []int{maxReminder + 1}},
+		{Reminders: []int{1, 2, 3, 4, 5, 6}},
+	} {
+		bad.UserID, bad.Date, bad.Title = 1, day, "Bad"
+		_, err = service.CreateEvent(bad)
+		assert.ErrorIs(t, err, ErrInvalidEvent, "%+v", bad)
+	}
+}
+
+func TestTemplates_Instantiate(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.CreateTemplate(model.Template{UserID: 1, Name: " ", Title: "Review"})
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+	_, err = service.CreateTemplate(model.Template{UserID: 1, Name: "Review", Title: "Review", Color: "red"})
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+
+	id, err := service.CreateTemplate(model.Template{UserID: 1, Name: "Review", Title: "Code review",
+		StartTime: "14:00", DurationMinutes: 60, Tags: []string{"Work"}, Attendees: []string{"Team@Example.com"}, Reminders: []int{10}})
+	assert.NoError(t, err)
+	list, _ := service.Templates(1)
+	assert.Equal(t, []string{"work"}, list[0].Tags)
+	assert.Equal(t, []string{"team@example.com"}, list[0].Attendees)
+
+	eventID, err := service.InstantiateTemplate(1, id, day)
+	assert.NoError(t, err)
+	event, _ := service.Event(eventID)
+	assert.Equal(t, model.Event{ID: eventID, UserID: 1, Date: day, Title: "Code review", StartTime: "14:00", DurationMinutes: 60,
+		Tags: []string{"work"}, Attendees: []string{"team@example.com"}, Reminders: []int{10}}, event)
+
+	_, err = service.InstantiateTemplate(2, id, day)
+	assert.ErrorIs(t, err, storage.ErrTemplateNotFound)
+	assert.ErrorIs(t, service.UpdateTemplate(model.Template{ID: id, UserID: 2, Name: "Mine", Title: "Mine"}), storage.ErrTemplateNotFound)
+	assert.ErrorIs(t, service.DeleteTemplate(2, id), storage.ErrTemplateNotFound)
+	assert.NoError(t, service.DeleteTemplate(1, id))
+	list, _ = service.Templates(1)
+	assert.Empty(t, list)
+}
+
+func TestDuplicateEvent(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Demo", Tags: []string{"work"}})
+
+	copyID, err := service.DuplicateEvent(1, id, day.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, copyID)
+	copied, _ := service.Event(copyID)
+	assert.Equal(t, model.Event{ID: copyID, UserID: 1, Date: day.AddDate(0, 0, 7), Title: "Demo", Tags: []string{"work"}}, copied)
+
+	_, err = service.DuplicateEvent(2, id, day)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestWithClient_ChecksOwnerUnderLock(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "alice's"})
+	alice, _ := service.For(WithClient(context.Background(), 1))
+	bob, _ := service.For(WithClient(context.Background(), 2))
+
+	assert.ErrorIs(t, bob.UpdateEvent(model.Event{ID: id, UserID: 2, Date: day, Title: "bob's"}), ErrNotOwner)
+	assert.ErrorIs(t, alice.UpdateEvent(model.Event{ID: id, UserID: 2, Date: day, Title: "bob's"}), ErrNotOwner, "the client keeps the event")
+	assert.ErrorIs(t, bob.DeleteEvent(id), ErrNotOwner)
+	event, _ := service.Event(id)
+	assert.Equal(t, "alice's", event.Title)
+
+	assert.NoError(t, alice.UpdateEvent(model.Event{ID: id, UserID: 1, Date: day, Title: "renamed"}))
+	assert.NoError(t, alice.DeleteEvent(id))
+}
+
+func TestShiftEvents_AllOrNothing(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage(), WithQuota(Quota{MaxEventsPerDay: 1}))
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, _ := service.CreateEvent(model.Event{UserID: 1, Date: monday, Title: "a"})
+	b, _ := service.CreateEvent(model.Event{UserID: 1, Date: monday.AddDate(0, 0, 1), Title: "b"})
+	c, _ := service.CreateEvent(model.Event{UserID: 1, Date: monday.AddDate(0, 0, 3), Title: "c"})
+	other, _ := service.CreateEvent(model.Event{UserID: 2, Date: monday, Title: "other"})
+
+	// Moving a onto b's day works because b moves away first.
+	moved, err := service.ShiftEvents(1, []int{a, b}, 1)
+	assert.NoError(t, err)
+	assert.Len(t, moved, 2)
+	event, _ := service.Event(a)
+	assert.Equal(t, monday.AddDate(0, 0, 1), event.Date)
+
+	// b lands on c's day, which stays; a has already moved and goes back.
+	_, err = service.ShiftEvents(1, []int{a, b}, 2)
+	assert.ErrorIs(t, err, ErrDailyQuotaExceeded)
+	event, _ = service.Event(a)
+	assert.Equal(t, monday.AddDate(0, 0, 1), event.Date)
+	event, _ = service.Event(b)
+	assert.Equal(t, monday.AddDate(0, 0, 2), event.Date)
+
+	_, err = service.ShiftEvents(1, []int{a, other}, 1)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = service.ShiftEvents(1, []int{a, a}, 1)
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+	_, err = service.ShiftEvents(1, []int{a}, 0)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = service.ShiftEvents(1, []int{c}, MaxShiftDays+1)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestUndoRedo(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.Undo(1)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "Draft"})
+	assert.NoError(t, service.UpdateEvent(model.Event{ID: id, UserID: 1, Date: day, Title: "Final", Tags: []string{"work"}}))
+	assert.NoError(t, service.DeleteEvent(id))
+	other, _ := service.CreateEvent(model.Event{UserID: 2, Date: day, Title: "Other user"})
+
+	rev, err := service.Undo(1)
+	assert.NoError(t, err)
+	assert.Equal(t, Revision{Action: "delete_event", Events: []model.Event{{ID: id, UserID: 1, Date: day, Title: "Final", Tags: []string{"work"}}}}, rev)
+	event, err := service.Event(id)
+	assert.NoError(t, err, "deleted events come back with their ID")
+
+	rev, err = service.Undo(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "update_event", rev.Action)
+	event, _ = service.Event(id)
+	assert.Equal(t, "Draft", event.Title)
+
+	rev, err = service.Undo(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{id}, rev.Deleted)
+	_, err = service.Event(id)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = service.Undo(1)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+	_, err = service.Event(other)
+	assert.NoError(t, err, "other users' history is separate")
+
+	_, err = service.Redo(1)
+	assert.NoError(t, err)
+	_, err = service.Redo(1)
+	assert.NoError(t, err)
+	event, _ = service.Event(id)
+	assert.Equal(t, "Final", event.Title)
+
+	// A new change forgets what was undone.
+	_, _ = service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "New"})
+	_, err = service.Redo(1)
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+}
+
+func TestUndo_RefusesChangedEvents(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	a, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "a"})
+	b, _ := service.CreateEvent(model.Event{UserID: 1, Date: day.AddDate(0, 0, 1), Title: "b"})
+
+	_, err := service.ShiftEvents(1, []int{a, b}, 7)
+	assert.NoError(t, err)
+	// Someone renames b directly in storage, bypassing the history.
+	event, _ := service.Event(b)
+	event.Title = "b renamed"
+	assert.NoError(t, service.storage.Update(&event))
+
+	_, err = service.Undo(1)
+	assert.ErrorIs(t, err, ErrEventChanged)
+	event, _ = service.Event(a)
+	assert.Equal(t, day.AddDate(0, 0, 7), event.Date, "a refused undo changes nothing")
+
+	event, _ = service.Event(b)
+	event.Title = "b"
+	assert.NoError(t, service.storage.Update(&event))
+	rev, err := service.Undo(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "shift_events", rev.Action)
+	assert.Len(t, rev.Events, 2, "the shift is undone as a whole")
+	event, _ = service.Event(a)
+	assert.Equal(t, day, event.Date)
+}
+
+func TestUndo_KeepsBoundedHistory(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < historySize+5; i++ {
+		service.CreateEvent(model.Event{UserID: 1, Date: day, Title: fmt.Sprint(i)})
+	}
+	for i := 0; i < historySize; i++ {
+		_, err := service.Undo(1)
+		assert.NoError(t, err)
+	}
+	_, err := service.Undo(1)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+	events, _ := service.GetByDay(1, day, Filter{})
+	assert.Len(t, events, 5)
+}
+
+// updateHook runs onUpdate once before the next update of the storage.
+type updateHook struct {
+	storage.Storage
+	onUpdate func()
+}
+
+func (h *updateHook) Update(event *model.Event) error {
+	if f := h.onUpdate; f != nil {
+		h.onUpdate = nil
+		f()
+	}
+	return h.Storage.Update(event)
+}
+
+func TestUndo_CommandRecordedMeanwhile(t *testing.T) {
+	store := &updateHook{Storage: storage.NewInMemoryStorage()}
+	service := NewService(store)
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	a, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "a"})
+	b, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "b"})
+	assert.NoError(t, service.UpdateEvent(model.Event{ID: a, UserID: 1, Date: day, Title: "a v2"}))
+
+	// A shift of b finishes its updates and records its command while the
+	// update of a is being undone.
+	before, _ := service.Event(b)
+	after := before
+	after.Date = day.AddDate(0, 0, 1)
+	assert.NoError(t, store.Storage.Update(&after))
+	store.onUpdate = func() {
+		service.recordCommand(1, command{action: "shift_events", steps: []step{{before: &before, after: &after}}})
+	}
+
+	rev, err := service.Undo(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "update_event", rev.Action)
+	rev, err = service.Undo(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "shift_events", rev.Action, "the shift stays in the history")
+	event, _ := service.Event(b)
+	assert.Equal(t, day, event.Date)
+}
+
+func TestSync(t *testing.T) {
+	store := storage.NewChangeLog(storage.NewInMemoryStorage(), 3)
+	service := NewService(store)
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.Sync(1, "", 0)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	start, err := service.Sync(1, "", 10)
+	assert.NoError(t, err)
+
+	a, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "a"})
+	assert.NoError(t, service.DeleteEvent(a))
+	res, err := service.Sync(1, start.Token, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.ChangeKind{storage.ChangeCreated, storage.ChangeDeleted},
+		[]storage.ChangeKind{res.Changes[0].Kind, res.Changes[1].Kind})
+	assert.Equal(t, "a", res.Changes[1].Event.Title, "tombstones keep the deleted event")
+
+	// Undo restores the event, which is a change like any other.
+	_, err = service.Undo(1)
+	assert.NoError(t, err)
+	next, err := service.Sync(1, res.Token, 10)
+	assert.NoError(t, err)
+	assert.Len(t, next.Changes, 1)
+	assert.Equal(t, storage.ChangeCreated, next.Changes[0].Kind)
+
+	// The log keeps 3 changes, so after a fourth one the first token has
+	// fallen out of it.
+	_, _ = service.CreateEvent(model.Event{UserID: 2, Date: day, Title: "b"})
+	_, err = service.Sync(1, start.Token, 10)
+	assert.ErrorIs(t, err, ErrSyncTokenExpired)
+	_, err = service.Sync(1, "other.0", 10)
+	assert.ErrorIs(t, err, ErrSyncTokenExpired)
+	_, err = service.Sync(1, store.Epoch()+".99", 10)
+	assert.ErrorIs(t, err, ErrSyncTokenExpired)
+}
+
+func TestSync_OwnerChange(t *testing.T) {
+	service := NewService(storage.NewChangeLog(storage.NewInMemoryStorage(), 0))
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: day, Title: "a"})
+	alice, _ := service.Sync(1, "", 10)
+	bob, _ := service.Sync(2, "", 10)
+
+	assert.NoError(t, service.UpdateEvent(model.Event{ID: id, UserID: 2, Date: day, Title: "a"}))
+	res, err := service.Sync(1, alice.Token, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, res.Changes, 1) {
+		assert.Equal(t, storage.ChangeDeleted, res.Changes[0].Kind, "the previous owner gets a tombstone")
+		assert.Equal(t, id, res.Changes[0].Event.ID)
+	}
+	res, err = service.Sync(2, bob.Token, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, res.Changes, 1) {
+		assert.Equal(t, storage.ChangeCreated, res.Changes[0].Kind, "the new owner never had the event")
+	}
+
+	// Undo hands the event back the same way.
+	_, err = service.Undo(2)
+	assert.NoError(t, err)
+	res, err = service.Sync(2, res.Token, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, res.Changes, 1) {
+		assert.Equal(t, storage.ChangeDeleted, res.Changes[0].Kind)
+	}
+}
+
+func TestQuota_PerUserAndPerDay(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage(), WithQuota(Quota{MaxEventsPerUser: 3, MaxEventsPerDay: 2}))
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	_, err := service.CreateEvent(model.Event{UserID: 1, Date: monday, Title: "1"})
+	assert.NoError(t, err)
+	id, err := service.CreateEvent(model.Event{UserID: 1, Date: monday, Title: "2"})
+	assert.NoError(t, err)
+	_, err = service.CreateEvent(model.Event{UserID: 1, Date: monday, Title: "3"})
+	assert.ErrorIs(t, err, ErrDailyQuotaExceeded)
+
+	assert.NoError(t, service.UpdateEvent(model.Event{ID: id, UserID: 1, Date: monday, Title: "2 renamed"}), "updating within the same day is allowed")
+
+	_, err = service.CreateEvent(model.Event{UserID: 1, Date: tuesday, Title: "3"})
+	assert.NoError(t, err)
+	_, err = service.CreateEvent(model.Event{UserID: 1, Date: tuesday, Title: "4"})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, err = service.CreateEvent(model.Event{UserID: 2, Date: tuesday, Title: "other user"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, service.UpdateEvent(model.Event{ID: id, UserID: 1, Date: tuesday, Title: "moved"}))
+	assert.ErrorIs(t, service.UpdateEvent(model.Event{ID: id - 1, UserID: 1, Date: tuesday, Title: "moved too"}), ErrDailyQuotaExceeded)
+
+	stats := service.Stats()
+	assert.Equal(t, int64(1), stats.QuotaRejectedTotal)
+	assert.Equal(t, int64(2), stats.QuotaRejectedDaily)
+}
+
+func TestQuota_PerUserOnOwnerChange(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage(), WithQuota(Quota{MaxEventsPerUser: 1}))
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.CreateEvent(model.Event{UserID: 1, Date: monday, Title: "1's"})
+	assert.NoError(t, err)
+	id, err := service.CreateEvent(model.Event{UserID: 2, Date: monday, Title: "2's"})
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, service.UpdateEvent(model.Event{ID: id, UserID: 1, Date: monday, Title: "given to 1"}), ErrQuotaExceeded)
+	assert.NoError(t, service.UpdateEvent(model.Event{ID: id, UserID: 3, Date: monday, Title: "given to 3"}))
+	assert.NoError(t, service.UpdateEvent(model.Event{ID: id, UserID: 3, Date: monday.AddDate(0, 0, 1), Title: "moved"}),
+		"the owner's own event does not count twice")
+
+	_, err = service.Undo(3)
+	assert.NoError(t, err)
+	// Undoing the hand-over gives the event back to user 2, who is full by now.
+	_, err = service.CreateEvent(model.Event{UserID: 2, Date: monday, Title: "2's again"})
+	assert.NoError(t, err)
+	_, err = service.Undo(3)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Equal(t, int64(2), service.Stats().QuotaRejectedTotal)
+}
+
+func TestQuota_ConcurrentWrites(t *testing.T) {
+	service := NewService(storage.NewShardedStorage(4), WithQuota(Quota{MaxEventsPerUser: 5}))
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	for user := 1; user <= 4; user++ {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				service.CreateEvent(model.Event{UserID: user, Date: day, Title: "e"})
+			}()
+		}
+	}
+	wg.Wait()
+	for user := 1; user <= 4; user++ {
+		n, _ := service.storage.CountByUser(user)
+		assert.Equal(t, 5, n, "user %d", user)
+	}
+}
+
+type archiveFunc func([]model.Event) error
+
+func (f archiveFunc) Archive(events []model.Event) error { return f(events) }
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	newService := func(archiver Archiver) *Service {
+		service := NewService(storage.NewInMemoryStorage(), WithRetention(RetentionPolicy{Months: 3, Archiver: archiver}))
+		service.CreateEvent(model.Event{UserID: 1, Date: time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), Title: "Old"})
+		service.CreateEvent(model.Event{UserID: 2, Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Title: "Ancient"})
+		service.CreateEvent(model.Event{UserID: 1, Date: time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC), Title: "Recent"})
+		return service
+	}
+
+	t.Run("purge", func(t *testing.T) {
+		service := newService(nil)
+		n, err := service.ApplyRetention(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		events, _ := service.GetByMonth(1, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Filter{})
+		assert.Len(t, events, 1)
+		assert.Equal(t, "Recent", events[0].Title)
+
+		stats := service.Stats()
+		assert.Equal(t, int64(2), stats.EventsPurged)
+		assert.Equal(t, int64(1), stats.RetentionRuns)
+		assert.Equal(t, now, stats.LastRetentionRun)
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		var archived []model.Event
+		service := newService(archiveFunc(func(events []model.Event) error {
+			archived = append(archived, events...)
+			return nil
+		}))
+		_, err := service.ApplyRetention(now)
+		assert.NoError(t, err)
+		assert.Len(t, archived, 2)
+		assert.Equal(t, int64(2), service.Stats().EventsArchived)
+	})
+
+	t.Run("failed archive keeps events", func(t *testing.T) {
+		service := newService(archiveFunc(func([]model.Event) error {
+			return errors.New("disk full")
+		}))
+		_, err := service.ApplyRetention(now)
+		assert.Error(t, err)
+
+		events, _ := service.GetByMonth(1, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Filter{})
+		assert.Len(t, events, 2)
+	})
+
+	t.Run("events moved meanwhile stay", func(t *testing.T) {
+		var service *Service
+		service = newService(archiveFunc(func(events []model.Event) error {
+			for _, e := range events {
+				if e.Title == "Old" {
+					e.Date = now
+					assert.NoError(t, service.UpdateEvent(e))
+				}
+			}
+			return nil
+		}))
+		n, err := service.ApplyRetention(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		events, _ := service.GetByDay(1, now, Filter{})
+		assert.Len(t, events, 1)
+	})
+}
+
+func TestWeekDays_FirstWeekdayAndHolidays(t *testing.T) {
+	ru := holiday.New("ru")
+	ru.AddHoliday(time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC), "Victory Day")
+	service := NewService(storage.NewInMemoryStorage(), WithHolidays(map[string]*holiday.Calendar{"ru": ru}, "ru"))
+
+	sunday := time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)
+	thursday := time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC)
+	service.CreateEvent(model.Event{UserID: 1, Date: sunday, Title: "Brunch"})
+	service.CreateEvent(model.Event{UserID: 1, Date: thursday, Title: "Parade"})
+
+	events, _ := service.GetByWeek(1, thursday, Filter{})
+	assert.Len(t, events, 1, "ISO week starts on Monday")
+
+	assert.ErrorIs(t, service.SetUserSettings(1, model.UserSettings{Region: "us"}), ErrUnknownRegion)
+	assert.NoError(t, service.SetUserSettings(1, model.UserSettings{FirstWeekday: time.Sunday}))
+	events, _ = service.GetByWeek(1, thursday, Filter{})
+	assert.Len(t, events, 2)
+
+	days, err := service.WeekDays(1, thursday, Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, days, 7)
+	assert.Equal(t, sunday, days[0].Date)
+	assert.False(t, days[0].Working)
+	assert.Equal(t, "Brunch", days[0].Events[0].Title)
+	assert.True(t, days[1].Working)
+	assert.Empty(t, days[1].Events)
+	assert.False(t, days[4].Working)
+	assert.Equal(t, "Victory Day", days[4].Holiday)
+}
+
+func TestNextBusinessDays(t *testing.T) {
+	ru := holiday.New("ru")
+	ru.AddHoliday(time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC), "Victory Day")
+	service := NewService(storage.NewInMemoryStorage(), WithHolidays(map[string]*holiday.Calendar{"ru": ru}, ""))
+	assert.NoError(t, service.SetUserSettings(1, model.UserSettings{Region: "ru", FirstWeekday: time.Monday}))
+
+	wednesday := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+	service.CreateEvent(model.Event{UserID: 1, Date: wednesday.AddDate(0, 0, 5), Title: "Planning"})
+
+	days, err := service.NextBusinessDays(1, wednesday, 3, Filter{})
+	assert.NoError(t, err)
+	var dates []int
+	for _, d := range days {
+		dates = append(dates, d.Date.Day())
+	}
+	assert.Equal(t, []int{8, 10, 13}, dates)
+	assert.Equal(t, "Planning", days[2].Events[0].Title)
+
+	days, _ = service.NextBusinessDays(2, wednesday, 2, Filter{})
+	assert.Equal(t, 9, days[1].Date.Day(), "users without a region only skip weekends")
+
+	_, err = service.NextBusinessDays(1, wednesday, MaxBusinessDays+1, Filter{})
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestUserSettings_SurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	open := func() *Service {
+		settings, err := storage.NewFileUserSettingsStore(path)
+		assert.NoError(t, err)
+		return NewService(storage.NewInMemoryStorage(), WithUserSettings(settings), WithDefaultTimeZone("UTC"))
+	}
+	moscow := model.UserSettings{FirstWeekday: time.Sunday, TimeZone: "Europe/Moscow"}
+	assert.NoError(t, open().SetUserSettings(1, moscow))
+
+	restarted := open()
+	assert.Equal(t, moscow, restarted.UserSettings(1))
+	assert.Equal(t, model.UserSettings{FirstWeekday: time.Monday, TimeZone: "UTC"}, restarted.UserSettings(2))
+}
+
+func TestQuickAdd_UsesUserTimeZone(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	// Late Tuesday evening in UTC is already Wednesday in Moscow.
+	service.now = func() time.Time { return time.Date(2024, 5, 7, 22, 30, 0, 0, time.UTC) }
+	assert.NoError(t, service.SetUserSettings(1, model.UserSettings{FirstWeekday: time.Monday, TimeZone: "Europe/Moscow"}))
+	assert.Error(t, service.SetUserSettings(1, model.UserSettings{TimeZone: "Mars/Olympus"}))
+
+	res, err := service.QuickAdd(1, "Standup tomorrow at 10:00 for 15m", false)
+	assert.NoError(t, err)
+	assert.Zero(t, res.ID)
+	assert.Equal(t, model.Event{UserID: 1, Date: time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC), Title: "Standup", StartTime: "10:00", DurationMinutes: 15}, res.Event)
+	events, _ := service.GetByWeek(1, res.Event.Date, Filter{})
+	assert.Empty(t, events, "dry run does not create")
+
+	res, err = service.QuickAdd(1, "Демо в пятницу в 16:00", true)
+	assert.NoError(t, err)
+	assert.Equal(t, res.ID, res.Event.ID)
+	stored, _ := service.GetByDay(1, time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC), Filter{})
+	assert.Equal(t, []model.Event{res.Event}, stored)
+
+	_, err = service.QuickAdd(1, "tomorrow", true)
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+}
+
+func TestDeleteEvent_RemovesAttachments(t *testing.T) {
+	attachments, err := storage.NewDiskAttachmentStore(t.TempDir(), 1<<20)
+	assert.NoError(t, err)
+	service := NewService(storage.NewInMemoryStorage(), WithAttachments(attachments))
+
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: time.Now(), Title: "Review"})
+	_, err = service.AddAttachment(id, "draft.txt", strings.NewReader("draft"))
+	assert.NoError(t, err)
+	_, err = service.AddAttachment(id+1, "lost.txt", strings.NewReader("lost"))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	assert.NoError(t, service.DeleteEvent(id))
+	list, err := attachments.List(id)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	_, err = NewService(storage.NewInMemoryStorage()).Attachments(id)
+	assert.ErrorIs(t, err, ErrAttachmentsDisabled)
+}
+
+// readHook runs onRead before the first read of r.
+type readHook struct {
+	r      io.Reader
+	onRead func()
+}
+
+func (h *readHook) Read(p []byte) (int, error) {
+	if f := h.onRead; f != nil {
+		h.onRead = nil
+		f()
+	}
+	return h.r.Read(p)
+}
+
+func TestAddAttachment_EventDeletedDuringUpload(t *testing.T) {
+	attachments, err := storage.NewDiskAttachmentStore(t.TempDir(), 1<<20)
+	assert.NoError(t, err)
+	service := NewService(storage.NewInMemoryStorage(), WithAttachments(attachments))
+	id, _ := service.CreateEvent(model.Event{UserID: 1, Date: time.Now(), Title: "Review"})
+
+	_, err = service.AddAttachment(id, "draft.txt", &readHook{r: strings.NewReader("draft"), onRead: func() {
+		assert.NoError(t, service.DeleteEvent(id))
+	}})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	list, err := attachments.List(id)
+	assert.NoError(t, err)
+	assert.Empty(t, list, "no orphaned attachment is left")
+}
+
+func TestTracing_NestsServiceAndStorageSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	service := NewService(storage.NewTracedStorage(storage.NewInMemoryStorage()),
+		WithQuota(Quota{MaxEventsPerDay: 5}))
+	ctx, root := otel.Tracer("test").Start(context.Background(), "request")
+	svc, err := service.For(ctx)
+	assert.NoError(t, err)
+	_, err = svc.QuickAdd(7, "Standup tomorrow at 10:00", true)
+	assert.NoError(t, err)
+	root.End()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range exporter.GetSpans().Snapshots() {
+		spans[s.Name()] = s
+	}
+	parentOf := func(name string) string {
+		for n, s := range spans {
+			if s.SpanContext().SpanID() == spans[name].Parent().SpanID() {
+				return n
+			}
+		}
+		return ""
+	}
+	assert.Equal(t, "request", parentOf("service.QuickAdd"))
+	assert.Equal(t, "service.QuickAdd", parentOf("service.CreateEvent"))
+	assert.Equal(t, "service.CreateEvent", parentOf("storage.GetByDay"))
+	assert.Equal(t, "service.CreateEvent", parentOf("storage.Create"))
+	assert.Contains(t, spans["storage.GetByDay"].Attributes(), attribute.Int("user_id", 7))
+	assert.Contains(t, spans["storage.GetByDay"].Attributes(), attribute.String("window", "day"))
+
+	// Calls outside a request start their own traces.
+	exporter.Reset()
+	service.GetByMonth(7, time.Now(), Filter{})
+	got := exporter.GetSpans()
+	if assert.Len(t, got, 2) {
+		assert.False(t, got[1].Parent.IsValid())
+		assert.Equal(t, got[1].SpanContext.SpanID(), got[0].Parent.SpanID())
+	}
+}
+
+func TestReport_PeriodsAndBusiestDays(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	assert.NoError(t, service.SetUserSettings(1, model.UserSettings{FirstWeekday: time.Sunday}))
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	for _, e := range []model.Event{
+		{UserID: 1, Date: day(1), Title: "Fri", DurationMinutes: 30},
+		{UserID: 1, Date: day(5), Title: "Tue", DurationMinutes: 60, Tags: []string{"team"}},
+		{UserID: 1, Date: day(5), Title: "Tue again", Tags: []string{"team"}},
+		{UserID: 1, Date: day(7), Title: "Thu", DurationMinutes: 90},
+		{UserID: 1, Date: day(20), Title: "After the range"},
+		{UserID: 2, Date: day(5), Title: "Other user"},
+	} {
+		_, err := service.CreateEvent(e)
+		assert.NoError(t, err)
+	}
+
+	rep, err := service.Report(1, day(1), day(14), PeriodWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, rep.Events)
+	assert.Equal(t, 180, rep.Minutes)
+	// Weeks start on Sunday; the first one starts before the range and the
+	// empty last one is kept.
+	assert.Equal(t, []PeriodTotal{
+		{Start: time.Date(2024, 2, 25, 0, 0, 0, 0, time.UTC), Events: 1, Minutes: 30},
+		{Start: day(3), Events: 3, Minutes: 150},
+		{Start: day(10)},
+	}, rep.Periods)
+	assert.Equal(t, []storage.DayTotal{
+		{Date: day(5), Events: 2, Minutes: 60},
+		{Date: day(7), Events: 1, Minutes: 90},
+		{Date: day(1), Events: 1, Minutes: 30},
+	}, rep.BusiestDays)
+	assert.Equal(t, []storage.GroupTotal{{Key: "", Events: 2, Minutes: 120}, {Key: "team", Events: 2, Minutes: 60}}, rep.Tags)
+
+	rep, err = service.Report(1, day(1), day(3), PeriodDay)
+	assert.NoError(t, err)
+	assert.Equal(t, []PeriodTotal{{Start: day(1), Events: 1, Minutes: 30}, {Start: day(2)}, {Start: day(3)}}, rep.Periods)
+
+	_, err = service.Report(1, day(1), day(1).AddDate(0, 0, MaxReportDays), PeriodMonth)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = service.Report(1, day(1), day(1), "year")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestBooking_OpenSlotsAndBook(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	assert.NoError(t, service.SetUserSettings(1, model.UserSettings{FirstWeekday: time.Monday, TimeZone: "Europe/Moscow"}))
+	msk, _ := time.LoadLocation("Europe/Moscow")
+	mon := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time { return time.Date(2024, 3, 4, hour, minute, 0, 0, msk) }
+	service.now = func() time.Time { return at(9, 10) }
+
+	link, err := service.CreateBookingLink(model.BookingLink{
+		UserID: 1, Title: "Intro call", Weekdays: []time.Weekday{time.Monday, time.Monday},
+		From: "09:00", To: "12:00", SlotMinutes: 30, BufferMinutes: 15,
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, link.Token)
+	assert.Equal(t, []time.Weekday{time.Monday}, link.Weekdays)
+	_, err = service.CreateEvent(model.Event{UserID: 1, Date: mon, Title: "Busy", StartTime: "10:40", DurationMinutes: 20})
+	assert.NoError(t, err)
+	_, err = service.CreateEvent(model.Event{UserID: 1, Date: mon, Title: "All day"})
+	assert.NoError(t, err)
+
+	// 09:00 has started and 10:30 is within the buffer of the event.
+	slots, err := service.OpenSlots(link.Token, mon, mon.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, []Slot{{Start: at(9, 45), End: at(10, 15)}, {Start: at(11, 15), End: at(11, 45)}}, slots)
+
+	event, err := service.Book(link.Token, at(11, 15), "Ann", "Ann@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, model.Event{
+		ID: event.ID, UserID: 1, Date: mon, Title: "Intro call: Ann", StartTime: "11:15", DurationMinutes: 30,
+		Category: BookingCategory, Attendees: []string{"ann@example.com"},
+	}, event)
+	_, err = service.Book(link.Token, at(11, 15), "Bob", "bob@example.com")
+	assert.ErrorIs(t, err, ErrSlotUnavailable)
+	_, err = service.Book(link.Token, at(10, 30), "Bob", "bob@example.com")
+	assert.ErrorIs(t, err, ErrSlotUnavailable)
+	_, err = service.Book("unknown", at(11, 15), "Bob", "bob@example.com")
+	assert.ErrorIs(t, err, storage.ErrBookingLinkNotFound)
+
+	// The owner's undo reverts their own latest change, not the booking.
+	rev, err := service.Undo(1)
+	assert.NoError(t, err)
+	assert.NotContains(t, rev.Deleted, event.ID)
+	_, err = service.Event(event.ID)
+	assert.NoError(t, err)
+
+	_, err = service.OpenSlots(link.Token, mon, mon.AddDate(0, 0, MaxBookingDays))
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	assert.ErrorIs(t, service.DeleteBookingLink(2, link.Token), storage.ErrBookingLinkNotFound)
+	assert.NoError(t, service.DeleteBookingLink(1, link.Token))
+}
+
+func TestBooking_ConcurrentBookingsOfOneSlot(t *testing.T) {
+	service := NewService(storage.NewInMemoryStorage())
+	service.now = func() time.Time { return time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC) }
+	link, err := service.CreateBookingLink(model.BookingLink{
+		UserID: 1, Title: "Demo", Weekdays: []time.Weekday{time.Monday}, From: "14:00", To: "15:00", SlotMinutes: 60,
+	})
+	assert.NoError(t, err)
+
+	start := time.Date(2024, 3, 4, 14, 0, 0, 0, time.UTC)
+	errs := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := service.Book(link.Token, start, fmt.Sprintf("Guest %d", i), "guest@example.com")
+			errs <- err
+		}()
+	}
+	booked := 0
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err == nil {
+			booked++
+		} else {
+			assert.ErrorIs(t, err, ErrSlotUnavailable)
+		}
+	}
+	assert.Equal(t, 1, booked)
+	events, _ := service.GetByDay(1, start, Filter{})
+	assert.Len(t, events, 1)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MaxSyncChanges caps the changes returned by one Sync call.
+const MaxSyncChanges = 1000
+
+// ErrSyncTokenExpired means the changes since a sync token are no longer
+// known, for example after a restart; the client must fetch its events
+// again and continue from a new token.
+var ErrSyncTokenExpired = errors.New("sync token expired, do a full resync")
+
+// SyncResult is a page of changes to the user's events.
+type SyncResult struct {
+	// Token is passed to the next Sync to get the changes after this page.
+	Token   string           `json:"token"`
+	Changes []storage.Change `json:"changes"`
+	// More tells that changes were left out because of the limit; sync
+	// again right away to get them.
+	More bool `json:"more"`
+}
+
+// Sync returns up to limit changes to the user's events made after token,
+// oldest first, deletes included. An empty token returns no changes but
+// the current token, to be used after fetching all events.
+func (s *Service) Sync(userID int, token string, limit int) (res SyncResult, err error) {
+	s, span := s.start("Sync", attribute.Int("user_id", userID), attribute.Int("limit", limit))
+	defer func() { tracing.End(span, err) }()
+
+	if limit <= 0 || limit > MaxSyncChanges {
+		return SyncResult{}, fmt.Errorf("%w: limit must be within 1..%d", ErrInvalidRange, MaxSyncChanges)
+	}
+	log := s.changeLog()
+	if token == "" {
+		return SyncResult{Token: syncToken(log.Epoch(), log.Seq()), Changes: []storage.Change{}}, nil
+	}
+	seq, ok := parseSyncToken(token, log.Epoch())
+	if !ok {
+		return SyncResult{}, ErrSyncTokenExpired
+	}
+	changes, next, more, err := log.Since(userID, seq, limit)
+	if errors.Is(err, storage.ErrChangesExpired) {
+		return SyncResult{}, fmt.Errorf("%w: %v", ErrSyncTokenExpired, err)
+	}
+	if err != nil {
+		return SyncResult{}, err
+	}
+	return SyncResult{Token: syncToken(log.Epoch(), next), Changes: changes, More: more}, nil
+}
+
+// changeLog finds the ChangeLog that NewService ensures is in the storage
+// chain.
+func (s *Service) changeLog() *storage.ChangeLog {
+	log, _ := find[*storage.ChangeLog](s.storage)
+	return log
+}
+
+// withChangeLog wraps store in a ChangeLog unless the chain has one.
+func withChangeLog(store storage.Storage) storage.Storage {
+	if _, ok := find[*storage.ChangeLog](store); ok {
+		return store
+	}
+	return storage.NewChangeLog(store, storage.DefaultChangeLogSize)
+}
+
+// find returns the first storage of type T in the chain of wrapped
+// storages starting at store.
+func find[T any](store storage.Storage) (T, bool) {
+	for store != nil {
+		if t, ok := store.(T); ok {
+			return t, true
+		}
+		u, ok := store.(interface{ Unwrap() storage.Storage })
+		if !ok {
+			break
+		}
+		store = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+func syncToken(epoch string, seq int64) string {
+	return epoch + "." + strconv.FormatInt(seq, 10)
+}
+
+func parseSyncToken(token, epoch string) (int64, bool) {
+	e, seq, ok := strings.Cut(token, ".")
+	if !ok || e != epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(seq, 10, 64)
+	return n, err == nil && n >= 0
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	maxTemplates = 100
+	// MaxShiftEvents caps the events moved by one ShiftEvents call.
+	MaxShiftEvents = 100
+	// MaxShiftDays caps the offset of ShiftEvents, about ten years.
+	MaxShiftDays = 3660
+)
+
+// WithTemplates stores event templates in store. Without it they are kept
+// in memory.
+func WithTemplates(store storage.TemplateStore) Option {
+	return func(s *Service) {
+		s.templates = store
+	}
+}
+
+func newTemplateStore() storage.TemplateStore {
+	return storage.NewInMemoryTemplateStore()
+}
+
+// CreateTemplate stores t as a new template of t.UserID and returns its ID.
+func (s *Service) CreateTemplate(t model.Template) (id int, err error) {
+	s, span := s.start("CreateTemplate", attribute.Int("user_id", t.UserID))
+	defer func() { tracing.End(span, err) }()
+
+	t.ID = 0
+	if err := normalizeTemplate(&t); err != nil {
+		return 0, err
+	}
+
+	s.templatesMu.Lock()
+	defer s.templatesMu.Unlock()
+	existing, err := s.templates.ListByUser(t.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) >= maxTemplates {
+		return 0, fmt.Errorf("%w: at most %d templates per user", ErrQuotaExceeded, maxTemplates)
+	}
+	return s.templates.Create(&t)
+}
+
+// UpdateTemplate replaces the template with t.ID, which must belong to
+// t.UserID.
+func (s *Service) UpdateTemplate(t model.Template) (err error) {
+	s, span := s.start("UpdateTemplate", attribute.Int("user_id", t.UserID), attribute.Int("template_id", t.ID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := normalizeTemplate(&t); err != nil {
+		return err
+	}
+	if _, err := s.Template(t.UserID, t.ID); err != nil {
+		return err
+	}
+	return s.templates.Update(&t)
+}
+
+func (s *Service) DeleteTemplate(userID, id int) error {
+	if _, err := s.Template(userID, id); err != nil {
+		return err
+	}
+	return s.templates.Delete(id)
+}
+
+// Template returns the user's template with id. Templates of other users
+// are reported as not found.
+func (s *Service) Template(userID, id int) (model.Template, error) {
+	t, err := s.templates.Get(id)
+	if err != nil {
+		return model.Template{}, err
+	}
+	if t.UserID != userID {
+		return model.Template{}, storage.ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+// Templates returns the user's templates ordered by name.
+func (s *Service) Templates(userID int) ([]model.Template, error) {
+	return s.templates.ListByUser(userID)
+}
+
+// InstantiateTemplate creates an event from the user's template on date and
+// returns its ID.
+func (s *Service) InstantiateTemplate(userID, id int, date time.Time) (eventID int, err error) {
+	s, span := s.start("InstantiateTemplate", attribute.Int("user_id", userID), attribute.Int("template_id", id))
+	defer func() { tracing.End(span, err) }()
+
+	t, err := s.Template(userID, id)
+	if err != nil {
+		return 0, err
+	}
+	return s.CreateEvent(eventOf(t, date))
+}
+
+// DuplicateEvent copies the user's event to date and returns the ID of the
+// copy. Attachments are not copied.
+func (s *Service) DuplicateEvent(userID, id int, date time.Time) (eventID int, err error) {
+	s, span := s.start("DuplicateEvent", attribute.Int("user_id", userID), attribute.Int("event_id", id))
+	defer func() { tracing.End(span, err) }()
+
+	event, err := s.ownEvent(userID, id)
+	if err != nil {
+		return 0, err
+	}
+	event.Date = date
+	return s.CreateEvent(event)
+}
+
+// ShiftEvents moves the user's events by days and returns them as moved.
+// Either all of them move or, if one cannot, none does.
+func (s *Service) ShiftEvents(userID int, ids []int, days int) (moved []model.Event, err error) {
+	s, span := s.start("ShiftEvents", attribute.Int("user_id", userID), attribute.Int("events", len(ids)), attribute.Int("days", days))
+	defer func() { tracing.End(span, err) }()
+
+	if days == 0 || days < -MaxShiftDays || days > MaxShiftDays {
+		return nil, fmt.Errorf("%w: days must be within ±%d and not zero", ErrInvalidRange, MaxShiftDays)
+	}
+	if len(ids) == 0 || len(ids) > MaxShiftEvents {
+		return nil, fmt.Errorf("%w: shift 1..%d events at once", ErrInvalidEvent, MaxShiftEvents)
+	}
+	events := make([]model.Event, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: event %d is listed twice", ErrInvalidEvent, id)
+		}
+		seen[id] = true
+		e, err := s.ownEvent(userID, id)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	// Move the events furthest in the direction of the shift first, so that
+	// the daily quota sees each day with as few events as possible.
+	sort.SliceStable(events, func(i, j int) bool {
+		if days > 0 {
+			return events[i].Date.After(events[j].Date)
+		}
+		return events[i].Date.Before(events[j].Date)
+	})
+
+	// The shift is undone as a whole; a failed one leaves no history.
+	b, cmd := s.batched("shift_events")
+	for i, e := range events {
+		e.Date = e.Date.AddDate(0, 0, days)
+		if err := b.UpdateEvent(e); err != nil {
+			b.restore(events[:i])
+			return nil, err
+		}
+		moved = append(moved, e)
+	}
+	s.recordCommand(userID, *cmd)
+	return moved, nil
+}
+
+// restore puts back events moved before a failed shift.
+func (s *Service) restore(events []model.Event) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if err := s.UpdateEvent(events[i]); err != nil {
+			log.Printf("Error restore event %d after failed shift: %v", events[i].ID, err)
+		}
+	}
+}
+
+// ownEvent returns the user's event with id; events of other users are
+// reported as not found.
+func (s *Service) ownEvent(userID, id int) (model.Event, error) {
+	event, err := s.store().GetByID(id)
+	if err != nil {
+		return model.Event{}, err
+	}
+	if event.UserID != userID {
+		return model.Event{}, storage.ErrNotFound
+	}
+	return event, nil
+}
+
+// normalizeTemplate validates t like the events made from it.
+func normalizeTemplate(t *model.Template) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Name == "" || t.Title == "" {
+		return fmt.Errorf("%w: template name and title are required", ErrInvalidEvent)
+	}
+	e := eventOf(*t, time.Time{})
+	if err := normalize(&e); err != nil {
+		return err
+	}
+	t.Location, t.Category, t.Color = e.Location, e.Category, e.Color
+	t.Tags, t.Attendees, t.Reminders = e.Tags, e.Attendees, e.Reminders
+	return nil
+}
+
+func eventOf(t model.Template, date time.Time) model.Event {
+	return model.Event{
+		UserID:          t.UserID,
+		Date:            date,
+		Title:           t.Title,
+		StartTime:       t.StartTime,
+		DurationMinutes: t.DurationMinutes,
+		Description:     t.Description,
+		Location:        t.Location,
+		Category:        t.Category,
+		Color:           t.Color,
+		Tags:            t.Tags,
+		Attendees:       t.Attendees,
+		Reminders:       t.Reminders,
+	}
+}
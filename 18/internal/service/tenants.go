@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"wb_l12/18/internal/tenant"
+)
+
+// ErrUnknownTenant means the request names a tenant that is not configured.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// Provider returns the service of the tenant a request acts for.
+type Provider interface {
+	For(ctx context.Context) (*Service, error)
+}
+
+// For returns the service bound to ctx, whose span becomes the parent of
+// the service and storage spans. It makes a single Service a Provider for
+// deployments without tenants; the tenant of ctx is ignored.
+func (s *Service) For(ctx context.Context) (*Service, error) {
+	return &Service{state: s.state, ctx: ctx}, nil
+}
+
+// Tenants is a Provider with a separate Service, and so separate storage,
+// limits and settings, per tenant.
+type Tenants struct {
+	services map[string]*Service
+}
+
+// NewTenants serves the tenants in services; requests without a tenant use
+// the one under tenant.Default.
+func NewTenants(services map[string]*Service) *Tenants {
+	return &Tenants{services: services}
+}
+
+func (t *Tenants) For(ctx context.Context) (*Service, error) {
+	id := tenant.FromContext(ctx)
+	if s, ok := t.services[id]; ok {
+		return s.For(ctx)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownTenant, id)
+}
+
+// Each calls fn for every tenant in ID order.
+func (t *Tenants) Each(fn func(id string, s *Service)) {
+	ids := make([]string, 0, len(t.services))
+	for id := range t.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fn(id, t.services[id])
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"time"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "wb_l12/18/internal/service"
+
+// contextStorage is a storage that traces its calls under a context, such
+// as storage.TracedStorage.
+type contextStorage interface {
+	WithContext(ctx context.Context) storage.Storage
+}
+
+// start begins the span of the method name and returns s bound to it, so
+// that the calls made through the result are its children.
+func (s *Service) start(name string, attrs ...attribute.KeyValue) (*Service, trace.Span) {
+	ctx, span := tracing.Start(s.ctx, tracerName, "service."+name, attrs...)
+	return &Service{state: s.state, ctx: ctx, batch: s.batch}, span
+}
+
+// store returns the storage bound to the context of s.
+func (s *Service) store() storage.Storage {
+	if cs, ok := s.storage.(contextStorage); ok {
+		return cs.WithContext(s.ctx)
+	}
+	return s.storage
+}
+
+func windowAttrs(userID int, date time.Time) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("user_id", userID),
+		attribute.String("date", date.Format(time.DateOnly)),
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"wb_l12/18/internal/model"
+)
+
+type ChangeType int
+
+const (
+	ChangeCreated ChangeType = iota + 1
+	ChangeUpdated
+	ChangeDeleted
+)
+
+type Change struct {
+	Type  ChangeType
+	Event model.Event
+}
+
+// watcherBuffer is how many changes a watcher may lag behind before it is
+// disconnected with ErrWatcherTooSlow.
+const watcherBuffer = 64
+
+var ErrWatcherTooSlow = errors.New("watcher is too slow to keep up with changes")
+
+// Watcher receives the changes of a single user's events.
+type Watcher struct {
+	userID int
+	ch     chan Change
+	err    error
+}
+
+// Changes is closed when the watcher is stopped; Err then tells why.
+func (w *Watcher) Changes() <-chan Change {
+	return w.ch
+}
+
+// Err returns nil if the watcher was stopped with Unwatch.
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+type watchers struct {
+	mu  sync.Mutex
+	set map[*Watcher]struct{}
+}
+
+func (s *Service) Watch(userID int) *Watcher {
+	w := &Watcher{userID: userID, ch: make(chan Change, watcherBuffer)}
+
+	s.watchers.mu.Lock()
+	defer s.watchers.mu.Unlock()
+	if s.watchers.set == nil {
+		s.watchers.set = make(map[*Watcher]struct{})
+	}
+	s.watchers.set[w] = struct{}{}
+	return w
+}
+
+func (s *Service) Unwatch(w *Watcher) {
+	s.watchers.mu.Lock()
+	defer s.watchers.mu.Unlock()
+	s.stopWatcher(w, nil)
+}
+
+func (s *Service) publish(t ChangeType, event model.Event) {
+	s.watchers.mu.Lock()
+	defer s.watchers.mu.Unlock()
+
+	for w := range s.watchers.set {
+		if w.userID != event.UserID {
+			continue
+		}
+		select {
+		case w.ch <- Change{Type: t, Event: event}:
+		default:
+			s.stopWatcher(w, ErrWatcherTooSlow)
+		}
+	}
+}
+
+// stopWatcher must be called with watchers.mu held.
+func (s *Service) stopWatcher(w *Watcher, err error) {
+	if _, ok := s.watchers.set[w]; !ok {
+		return
+	}
+	delete(s.watchers.set, w)
+	w.err = err
+	close(w.ch)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"wb_l12/18/internal/holiday"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
+	"wb_l12/18/pkg/storage"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrUnknownRegion means no holiday calendar is loaded for the region.
+	ErrUnknownRegion = errors.New("unknown holiday region")
+	// ErrInvalidRange means a business day query asked for too many days.
+	ErrInvalidRange = errors.New("invalid number of days")
+)
+
+// MaxBusinessDays caps NextBusinessDays.
+const MaxBusinessDays = 100
+
+// Day is a calendar day with the user's events on it.
+type Day struct {
+	Date    time.Time     `json:"date"`
+	Working bool          `json:"working"`
+	Holiday string        `json:"holiday,omitempty"`
+	Events  []model.Event `json:"events"`
+}
+
+type workCalendars struct {
+	mu            sync.RWMutex
+	calendars     map[string]*holiday.Calendar
+	defaultRegion string
+	defaultZone   string
+}
+
+// WithHolidays sets the holiday calendars by region. defaultRegion is used
+// for users that did not choose one; it may be empty for weekends only.
+func WithHolidays(calendars map[string]*holiday.Calendar, defaultRegion string) Option {
+	return func(s *Service) {
+		s.work.calendars = calendars
+		s.work.defaultRegion = defaultRegion
+	}
+}
+
+// WithDefaultTimeZone sets the time zone of users that did not choose one.
+// The name must be valid for time.LoadLocation.
+func WithDefaultTimeZone(name string) Option {
+	return func(s *Service) {
+		s.work.defaultZone = name
+	}
+}
+
+// WithUserSettings stores user settings in store. Without it they are kept
+// in memory.
+func WithUserSettings(store storage.UserSettingsStore) Option {
+	return func(s *Service) {
+		s.settings = store
+	}
+}
+
+func newUserSettingsStore() storage.UserSettingsStore {
+	return storage.NewInMemoryUserSettingsStore()
+}
+
+func (s *Service) SetUserSettings(userID int, st model.UserSettings) error {
+	if st.FirstWeekday < time.Sunday || st.FirstWeekday > time.Saturday {
+		return fmt.Errorf("invalid first weekday %d", st.FirstWeekday)
+	}
+	if _, err := time.LoadLocation(st.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", st.TimeZone)
+	}
+	s.work.mu.RLock()
+	_, ok := s.work.calendars[st.Region]
+	s.work.mu.RUnlock()
+	if st.Region != "" && !ok {
+		return fmt.Errorf("%w %q", ErrUnknownRegion, st.Region)
+	}
+	return s.settings.Set(userID, st)
+}
+
+// UserSettings returns the user's settings; weeks start on Monday by default.
+func (s *Service) UserSettings(userID int) model.UserSettings {
+	st, ok := s.settings.Get(userID)
+	if !ok {
+		st = model.UserSettings{FirstWeekday: time.Monday}
+	}
+	if st.TimeZone == "" {
+		s.work.mu.RLock()
+		st.TimeZone = s.work.defaultZone
+		s.work.mu.RUnlock()
+	}
+	return st
+}
+
+func (s *Service) calendarOf(userID int) *holiday.Calendar {
+	region := s.UserSettings(userID).Region
+	s.work.mu.RLock()
+	defer s.work.mu.RUnlock()
+
+	if region == "" {
+		region = s.work.defaultRegion
+	}
+	if c, ok := s.work.calendars[region]; ok {
+		return c
+	}
+	return holiday.New(region)
+}
+
+// weekStart returns the first day of the user's week containing date.
+func weekStart(date time.Time, first time.Weekday) time.Time {
+	offset := (int(date.Weekday()) - int(first) + 7) % 7
+	return time.Date(date.Year(), date.Month(), date.Day()-offset, 0, 0, 0, 0, date.Location())
+}
+
+// userWeek returns the user's events in the 7 days starting at start. Weeks
+// not starting on Monday span two ISO weeks of the storage.
+func (s *Service) userWeek(userID int, start time.Time) ([]model.Event, error) {
+	end := start.AddDate(0, 0, 7)
+	if start.Weekday() == time.Monday {
+		return s.store().GetByWeek(userID, start)
+	}
+
+	var res []model.Event
+	for _, date := range []time.Time{start, end.AddDate(0, 0, -1)} {
+		events, err := s.store().GetByWeek(userID, date)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if !e.Date.Before(start) && e.Date.Before(end) {
+				res = append(res, e)
+			}
+		}
+	}
+	return res, nil
+}
+
+// WeekDays returns the 7 days of the user's week containing date, marked as
+// working or not, with their events.
+func (s *Service) WeekDays(userID int, date time.Time, f Filter) (days []Day, err error) {
+	s, span := s.start("WeekDays", windowAttrs(userID, date)...)
+	defer func() { tracing.End(span, err) }()
+
+	start := weekStart(date, s.UserSettings(userID).FirstWeekday)
+	events, err := f.apply(s.userWeek(userID, start))
+	if err != nil {
+		return nil, err
+	}
+
+	cal := s.calendarOf(userID)
+	days = make([]Day, 7)
+	for i := range days {
+		days[i] = newDay(cal, start.AddDate(0, 0, i))
+	}
+	for _, e := range events {
+		for i := range days {
+			if sameDay(days[i].Date, e.Date) {
+				days[i].Events = append(days[i].Events, e)
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// NextBusinessDays returns the first n working days starting at from,
+// inclusive, with the user's events.
+func (s *Service) NextBusinessDays(userID int, from time.Time, n int, f Filter) (days []Day, err error) {
+	s, span := s.start("NextBusinessDays", append(windowAttrs(userID, from), attribute.Int("days", n))...)
+	defer func() { tracing.End(span, err) }()
+
+	if n < 1 || n > MaxBusinessDays {
+		return nil, fmt.Errorf("%w: n must be in 1..%d", ErrInvalidRange, MaxBusinessDays)
+	}
+	cal := s.calendarOf(userID)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	// A calendar without working days must not loop forever.
+	limit := day.AddDate(2, 0, 0)
+
+	for ; len(days) < n && day.Before(limit); day = day.AddDate(0, 0, 1) {
+		d := newDay(cal, day)
+		if !d.Working {
+			continue
+		}
+		events, err := f.apply(s.store().GetByDay(userID, day))
+		if err != nil {
+			return nil, err
+		}
+		d.Events = append(d.Events, events...)
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+func newDay(cal *holiday.Calendar, date time.Time) Day {
+	name, _ := cal.Holiday(date)
+	return Day{
+		Date:    date,
+		Working: cal.IsWorkingDay(date),
+		Holiday: name,
+		Events:  []model.Event{},
+	}
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -0,0 +1,58 @@
+// Package tenant carries the tenant a request acts for in its context.
+// Tenants share a deployment but nothing else: every tenant has its own
+// events, IDs, settings and limits.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Default is the tenant of requests that do not name one. It keeps the
+// storage and files of a single-tenant deployment.
+const Default = "default"
+
+// Header is the HTTP header and, lowercased, the gRPC metadata key naming
+// the tenant.
+const Header = "X-Tenant-ID"
+
+// IDs end up in file names, so they are kept to a safe alphabet.
+var idRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Validate checks that id is lowercase letters, digits, "-" and "_", at
+// most 63 characters long.
+func Validate(id string) error {
+	if !idRe.MatchString(id) {
+		return fmt.Errorf("invalid tenant %q: use lowercase letters, digits, - and _", id)
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant of ctx, or Default.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+// Path returns the file of tenant id derived from path: "events.json"
+// becomes "events.acme.json" for tenant "acme". The default tenant keeps
+// path itself.
+func Path(path, id string) string {
+	if id == Default || path == "" {
+		return path
+	}
+	path = filepath.Clean(path)
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + id + ext
+}
@@ -0,0 +1,29 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	for _, id := range []string{"acme", "team-7", "a_b"} {
+		assert.NoError(t, Validate(id), id)
+	}
+	for _, id := range []string{"", "Acme", "../etc", "-x", "a.b", string(make([]byte, 64))} {
+		assert.Error(t, Validate(id), id)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, Default, FromContext(context.Background()))
+	assert.Equal(t, "acme", FromContext(NewContext(context.Background(), "acme")))
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "data/events.json", Path("data/events.json", Default))
+	assert.Equal(t, "data/events.acme.json", Path("data/events.json", "acme"))
+	assert.Equal(t, "data/attachments.acme", Path("data/attachments/", "acme"))
+	assert.Equal(t, "", Path("", "acme"))
+}
@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSetup_FileExporter(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup("file:"+path, 1)
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "test", "service.CreateEvent")
+	End(span, nil)
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"service.CreateEvent"`)
+	assert.Contains(t, string(data), ServiceName)
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup("jaeger", 1)
+	assert.ErrorContains(t, err, "unknown trace exporter")
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	csrfCookie = "calendar_csrf"
+	csrfField  = "csrf_token"
+	csrfKey    = "web_csrf_token"
+)
+
+// csrf protects the forms with a double-submit token: every page sets a
+// random token in a SameSite=Strict cookie and puts it into its forms, and
+// a post is accepted only if both are present and equal. Another site can
+// make the browser send the cookie but cannot read it to fill in the form.
+// Posts with an Origin header of another host are rejected as well.
+func (h *Handler) csrf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sent, _ := c.Cookie(csrfCookie)
+		token := sent
+		if len(token) != base64.RawURLEncoding.EncodedLen(32) {
+			token = newToken()
+			c.SetSameSite(http.SameSiteStrictMode)
+			c.SetCookie(csrfCookie, token, 0, Prefix, "", c.Request.TLS != nil, true)
+		}
+		c.Set(csrfKey, token)
+
+		if c.Request.Method == http.MethodPost {
+			form := c.PostForm(csrfField)
+			if !sameOrigin(c.Request) || sent == "" || subtle.ConstantTimeCompare([]byte(form), []byte(sent)) != 1 {
+				h.fail(c, http.StatusForbidden, "The form has expired. Reload the page and try again.")
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func csrfToken(c *gin.Context) string {
+	return c.GetString(csrfKey)
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
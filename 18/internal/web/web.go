@@ -0,0 +1,491 @@
+// Package web serves a small HTML interface to the calendar for people who
+// do not use the JSON API. Pages are rendered on the server from embedded
+// templates and work without JavaScript or external assets.
+package web
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"wb_l12/18/internal/errstatus"
+	"wb_l12/18/internal/middleware"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Prefix is the path the interface is served under.
+const Prefix = "/ui"
+
+//go:embed templates static
+var files embed.FS
+
+const dateLayout = "2006-01-02"
+
+type Handler struct {
+	services service.Provider
+	pages    map[string]*template.Template
+}
+
+func NewHandler(services service.Provider) *Handler {
+	pages := make(map[string]*template.Template)
+	for _, name := range []string{"calendar", "event", "error", "user"} {
+		pages[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(files, "templates/layout.html", "templates/"+name+".html"))
+	}
+	return &Handler{services: services, pages: pages}
+}
+
+func (h *Handler) RegisterRoutes(r gin.IRouter) {
+	static, _ := fs.Sub(files, "static")
+	ui := r.Group(Prefix)
+	ui.StaticFS("/static", http.FS(static))
+
+	pages := ui.Group("", h.csrf())
+	pages.GET("", h.Calendar)
+	pages.GET("/events/new", h.NewEvent)
+	pages.POST("/events", h.CreateEvent)
+	pages.GET("/events/:id", h.EditEvent)
+	pages.POST("/events/:id", h.UpdateEvent)
+	pages.POST("/events/:id/delete", h.DeleteEvent)
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format(dateLayout) },
+}
+
+// page is the data every template gets.
+type page struct {
+	Title  string
+	UserID int
+	CSRF   string
+	Today  string
+}
+
+// cell is a day of a calendar view.
+type cell struct {
+	service.Day
+	Outside bool // not in the month shown
+	Today   bool
+	NewURL  string
+}
+
+type calendarPage struct {
+	page
+	View                string
+	Heading             string
+	Prev, Next, Current string
+	Views               []viewLink
+	Weekdays            []string
+	Rows                [][]cell
+}
+
+type viewLink struct {
+	Name, URL string
+	Active    bool
+}
+
+type eventPage struct {
+	page
+	Event  eventForm
+	Action string
+	Delete string
+	Back   string
+	Error  string
+}
+
+// eventForm holds the fields of the event form as the user typed them.
+type eventForm struct {
+	ID              int    `form:"-"`
+	Date            string `form:"date"`
+	Title           string `form:"title"`
+	StartTime       string `form:"start_time"`
+	DurationMinutes string `form:"duration_minutes"`
+	Description     string `form:"description"`
+	Location        string `form:"location"`
+	Category        string `form:"category"`
+	Color           string `form:"color"`
+	Tags            string `form:"tags"`
+}
+
+// Calendar shows the day, week or month containing the date parameter,
+// the current week by default.
+func (h *Handler) Calendar(c *gin.Context) {
+	svc, userID, ok := h.begin(c)
+	if !ok {
+		return
+	}
+	if userID == 0 {
+		h.render(c, http.StatusOK, "user", page{Title: "Calendar", CSRF: csrfToken(c)})
+		return
+	}
+
+	today := h.today(svc, userID)
+	date := today
+	if d := c.Query("date"); d != "" {
+		parsed, err := time.Parse(dateLayout, d)
+		if err != nil {
+			h.fail(c, http.StatusBadRequest, "Invalid date, use YYYY-MM-DD.")
+			return
+		}
+		date = parsed
+	}
+	view := c.DefaultQuery("view", "week")
+
+	var from, to, prev, next time.Time
+	var heading string
+	switch view {
+	case "day":
+		from, to = date, date
+		prev, next = date.AddDate(0, 0, -1), date.AddDate(0, 0, 1)
+		heading = date.Format("Monday, 2 January 2006")
+	case "week":
+		from, to = date, date
+		prev, next = date.AddDate(0, 0, -7), date.AddDate(0, 0, 7)
+	case "month":
+		from = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 1, -1)
+		prev, next = from.AddDate(0, -1, 0), from.AddDate(0, 1, 0)
+		heading = from.Format("January 2006")
+	default:
+		h.fail(c, http.StatusBadRequest, "Unknown view, use day, week or month.")
+		return
+	}
+
+	var rows [][]cell
+	for d := from; !d.After(to); {
+		days, err := svc.WeekDays(userID, d, service.Filter{})
+		if err != nil {
+			h.fail(c, errstatus.HTTP(err), err.Error())
+			return
+		}
+		row := make([]cell, 0, len(days))
+		for _, day := range days {
+			sortEvents(day.Events)
+			row = append(row, cell{
+				Day:     day,
+				Outside: view == "month" && day.Date.Month() != from.Month(),
+				Today:   day.Date.Equal(today),
+				NewURL:  link("/events/new", userID, url.Values{"date": {day.Date.Format(dateLayout)}}),
+			})
+		}
+		if view == "day" {
+			for _, cl := range row {
+				if cl.Date.Equal(date) {
+					row = []cell{cl}
+					break
+				}
+			}
+		}
+		rows = append(rows, row)
+		d = days[len(days)-1].Date.AddDate(0, 0, 1)
+	}
+	if view == "week" {
+		first, last := rows[0][0].Date, rows[0][6].Date
+		heading = first.Format("2 Jan") + " – " + last.Format("2 Jan 2006")
+	}
+
+	var weekdays []string
+	if view != "day" {
+		for _, cl := range rows[0] {
+			weekdays = append(weekdays, cl.Date.Weekday().String()[:3])
+		}
+	}
+	at := func(v string, d time.Time) string {
+		return link("", userID, url.Values{"view": {v}, "date": {d.Format(dateLayout)}})
+	}
+	var views []viewLink
+	for _, v := range []string{"day", "week", "month"} {
+		views = append(views, viewLink{Name: v, URL: at(v, date), Active: v == view})
+	}
+	h.render(c, http.StatusOK, "calendar", calendarPage{
+		page:     h.page(c, heading, userID),
+		View:     view,
+		Heading:  heading,
+		Prev:     at(view, prev),
+		Next:     at(view, next),
+		Current:  at(view, today),
+		Views:    views,
+		Weekdays: weekdays,
+		Rows:     rows,
+	})
+}
+
+func (h *Handler) NewEvent(c *gin.Context) {
+	svc, userID, ok := h.user(c)
+	if !ok {
+		return
+	}
+	today := h.today(svc, userID)
+	date := c.DefaultQuery("date", today.Format(dateLayout))
+	h.render(c, http.StatusOK, "event", eventPage{
+		page:   h.page(c, "New event", userID),
+		Event:  eventForm{Date: date},
+		Action: link("/events", userID, nil),
+		Back:   link("", userID, url.Values{"date": {date}}),
+	})
+}
+
+func (h *Handler) CreateEvent(c *gin.Context) {
+	svc, userID, ok := h.user(c)
+	if !ok {
+		return
+	}
+	var form eventForm
+	if err := c.ShouldBind(&form); err != nil {
+		h.fail(c, http.StatusBadRequest, "Invalid form.")
+		return
+	}
+	event, err := form.event(userID)
+	if err == nil {
+		form.ID, err = svc.CreateEvent(event)
+	}
+	if err != nil {
+		h.formError(c, userID, form, err)
+		return
+	}
+	h.redirect(c, userID, form.Date)
+}
+
+func (h *Handler) EditEvent(c *gin.Context) {
+	_, userID, event, ok := h.ownEvent(c)
+	if !ok {
+		return
+	}
+	form := formOf(event)
+	h.render(c, http.StatusOK, "event", eventPage{
+		page:   h.page(c, event.Title, userID),
+		Event:  form,
+		Action: link("/events/"+strconv.Itoa(event.ID), userID, nil),
+		Delete: link("/events/"+strconv.Itoa(event.ID)+"/delete", userID, nil),
+		Back:   link("", userID, url.Values{"date": {form.Date}}),
+	})
+}
+
+func (h *Handler) UpdateEvent(c *gin.Context) {
+	svc, userID, event, ok := h.ownEvent(c)
+	if !ok {
+		return
+	}
+	var form eventForm
+	if err := c.ShouldBind(&form); err != nil {
+		h.fail(c, http.StatusBadRequest, "Invalid form.")
+		return
+	}
+	form.ID = event.ID
+	updated, err := form.event(userID)
+	if err == nil {
+		// The form does not edit these; keep them.
+		updated.Attendees, updated.Reminders = event.Attendees, event.Reminders
+		err = svc.UpdateEvent(updated)
+	}
+	if err != nil {
+		h.formError(c, userID, form, err)
+		return
+	}
+	h.redirect(c, userID, form.Date)
+}
+
+func (h *Handler) DeleteEvent(c *gin.Context) {
+	svc, userID, event, ok := h.ownEvent(c)
+	if !ok {
+		return
+	}
+	if err := svc.DeleteEvent(event.ID); err != nil {
+		h.fail(c, errstatus.HTTP(err), err.Error())
+		return
+	}
+	h.redirect(c, userID, event.Date.Format(dateLayout))
+}
+
+// begin returns the service of the request and the user it acts for: the
+// user_id parameter or the identity of the client certificate, zero if
+// neither is given. It fails the request if they disagree.
+func (h *Handler) begin(c *gin.Context) (*service.Service, int, bool) {
+	svc, err := h.services.For(c.Request.Context())
+	if err != nil {
+		h.fail(c, errstatus.HTTP(err), err.Error())
+		return nil, 0, false
+	}
+	certID, hasCert := middleware.UserID(c)
+	userID := certID
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			h.fail(c, http.StatusBadRequest, "Invalid user_id.")
+			return nil, 0, false
+		}
+		userID = id
+	}
+	if hasCert && userID != certID {
+		h.fail(c, http.StatusForbidden, "user_id does not match client certificate.")
+		return nil, 0, false
+	}
+	return svc, userID, true
+}
+
+// user is begin for pages that need a user.
+func (h *Handler) user(c *gin.Context) (*service.Service, int, bool) {
+	svc, userID, ok := h.begin(c)
+	if ok && userID == 0 {
+		h.fail(c, http.StatusBadRequest, "user_id is required.")
+		return nil, 0, false
+	}
+	return svc, userID, ok
+}
+
+// ownEvent loads the event of the id path parameter, which must belong to
+// the user.
+func (h *Handler) ownEvent(c *gin.Context) (*service.Service, int, model.Event, bool) {
+	svc, userID, ok := h.user(c)
+	if !ok {
+		return nil, 0, model.Event{}, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.fail(c, http.StatusNotFound, "Event not found.")
+		return nil, 0, model.Event{}, false
+	}
+	event, err := svc.Event(id)
+	if err == nil && event.UserID != userID {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		h.fail(c, errstatus.HTTP(err), "Event not found.")
+		return nil, 0, model.Event{}, false
+	}
+	return svc, userID, event, true
+}
+
+// today is the current date in the user's time zone.
+func (h *Handler) today(svc *service.Service, userID int) time.Time {
+	now := time.Now()
+	if loc, err := time.LoadLocation(svc.UserSettings(userID).TimeZone); err == nil {
+		now = now.In(loc)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (h *Handler) page(c *gin.Context, title string, userID int) page {
+	return page{Title: title, UserID: userID, CSRF: csrfToken(c), Today: link("", userID, nil)}
+}
+
+func (h *Handler) formError(c *gin.Context, userID int, form eventForm, err error) {
+	p := eventPage{
+		page:  h.page(c, "New event", userID),
+		Event: form,
+		Back:  link("", userID, url.Values{"date": {form.Date}}),
+		Error: err.Error(),
+	}
+	p.Action = link("/events", userID, nil)
+	if form.ID != 0 {
+		p.Title = form.Title
+		p.Action = link("/events/"+strconv.Itoa(form.ID), userID, nil)
+		p.Delete = link("/events/"+strconv.Itoa(form.ID)+"/delete", userID, nil)
+	}
+	h.render(c, errstatus.HTTP(err), "event", p)
+}
+
+// redirect sends the browser to the day of a changed event with a 303, so
+// that reloading the page does not repeat the post.
+func (h *Handler) redirect(c *gin.Context, userID int, date string) {
+	c.Redirect(http.StatusSeeOther, link("", userID, url.Values{"view": {"day"}, "date": {date}}))
+}
+
+func (h *Handler) fail(c *gin.Context, status int, msg string) {
+	h.render(c, status, "error", struct {
+		page
+		Message string
+	}{page{Title: http.StatusText(status), CSRF: csrfToken(c), Today: Prefix}, msg})
+	c.Abort()
+}
+
+// render executes the page into a buffer first, so that a template error
+// does not leave a half-written page.
+func (h *Handler) render(c *gin.Context, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := h.pages[name].ExecuteTemplate(&buf, "layout", data); err != nil {
+		log.Printf("Error render %s page: %v", name, err)
+		c.String(http.StatusInternalServerError, "internal error")
+		return
+	}
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'self'; form-action 'self'; frame-ancestors 'none'")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+func (f eventForm) event(userID int) (model.Event, error) {
+	date, err := time.Parse(dateLayout, f.Date)
+	if err != nil {
+		return model.Event{}, fmt.Errorf("%w: date must look like 2024-05-01", service.ErrInvalidEvent)
+	}
+	if strings.TrimSpace(f.Title) == "" {
+		return model.Event{}, fmt.Errorf("%w: title is required", service.ErrInvalidEvent)
+	}
+	duration := 0
+	if f.DurationMinutes != "" {
+		if duration, err = strconv.Atoi(f.DurationMinutes); err != nil {
+			return model.Event{}, fmt.Errorf("%w: duration must be a number of minutes", service.ErrInvalidEvent)
+		}
+	}
+	var tags []string
+	for _, t := range strings.Split(f.Tags, ",") {
+		tags = append(tags, strings.TrimSpace(t))
+	}
+	return model.Event{
+		ID:              f.ID,
+		UserID:          userID,
+		Date:            date,
+		Title:           strings.TrimSpace(f.Title),
+		StartTime:       f.StartTime,
+		DurationMinutes: duration,
+		Description:     f.Description,
+		Location:        f.Location,
+		Category:        f.Category,
+		Color:           f.Color,
+		Tags:            tags,
+	}, nil
+}
+
+func formOf(e model.Event) eventForm {
+	f := eventForm{
+		ID:          e.ID,
+		Date:        e.Date.Format(dateLayout),
+		Title:       e.Title,
+		StartTime:   e.StartTime,
+		Description: e.Description,
+		Location:    e.Location,
+		Category:    e.Category,
+		Color:       e.Color,
+		Tags:        strings.Join(e.Tags, ", "),
+	}
+	if e.DurationMinutes > 0 {
+		f.DurationMinutes = strconv.Itoa(e.DurationMinutes)
+	}
+	return f
+}
+
+// sortEvents puts all-day events first, then orders by start time.
+func sortEvents(events []model.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime < events[j].StartTime
+	})
+}
+
+// link returns the URL of path under Prefix for the user with query.
+func link(path string, userID int, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("user_id", strconv.Itoa(userID))
+	return Prefix + path + "?" + query.Encode()
+}
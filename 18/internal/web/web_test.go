@@ -0,0 +1,157 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+)
+
+func newRouter(svc *service.Service) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewHandler(svc).RegisterRoutes(r)
+	return r
+}
+
+var tokenRe = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// browser keeps the CSRF cookie between requests like a browser would.
+type browser struct {
+	t      *testing.T
+	router *gin.Engine
+	cookie *http.Cookie
+}
+
+func (b *browser) get(path string) *httptest.ResponseRecorder {
+	return b.do(httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+func (b *browser) post(path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return b.do(req)
+}
+
+func (b *browser) do(req *http.Request) *httptest.ResponseRecorder {
+	if b.cookie != nil {
+		req.AddCookie(b.cookie)
+	}
+	w := httptest.NewRecorder()
+	b.router.ServeHTTP(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookie {
+			b.cookie = c
+		}
+	}
+	return w
+}
+
+// token returns the CSRF token of the form on the page at path.
+func (b *browser) token(path string) string {
+	w := b.get(path)
+	require.Equal(b.t, http.StatusOK, w.Code, w.Body.String())
+	m := tokenRe.FindStringSubmatch(w.Body.String())
+	require.NotNil(b.t, m, "no csrf token on %s", path)
+	return m[1]
+}
+
+func TestCalendar_Views(t *testing.T) {
+	svc := service.NewService(storage.NewInMemoryStorage())
+	date := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	svc.CreateEvent(model.Event{UserID: 1, Date: date, Title: "Retro <b>", StartTime: "15:00"})
+	svc.CreateEvent(model.Event{UserID: 1, Date: date, Title: "Standup", StartTime: "09:30"})
+	svc.CreateEvent(model.Event{UserID: 2, Date: date, Title: "Someone else's"})
+	b := &browser{t: t, router: newRouter(svc)}
+
+	for _, view := range []string{"day", "week", "month"} {
+		w := b.get("/ui?user_id=1&view=" + view + "&date=2024-05-15")
+		require.Equal(t, http.StatusOK, w.Code, view)
+		body := w.Body.String()
+		assert.Contains(t, body, "Retro &lt;b&gt;", view)
+		assert.NotContains(t, body, "Someone else", view)
+		assert.Less(t, strings.Index(body, "Standup"), strings.Index(body, "Retro"), view)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	}
+	assert.Contains(t, b.get("/ui?user_id=1&view=month&date=2024-05-15").Body.String(), "May 2024")
+	assert.Equal(t, http.StatusBadRequest, b.get("/ui?user_id=1&view=year").Code)
+	assert.Contains(t, b.get("/ui").Body.String(), `name="user_id"`)
+	assert.Equal(t, http.StatusOK, b.get("/ui/static/style.css").Code)
+}
+
+func TestEvents_CreateUpdateDelete(t *testing.T) {
+	svc := service.NewService(storage.NewInMemoryStorage())
+	b := &browser{t: t, router: newRouter(svc)}
+	date := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+
+	token := b.token("/ui/events/new?user_id=1&date=2024-05-15")
+	w := b.post("/ui/events?user_id=1", url.Values{
+		"csrf_token": {token}, "date": {"2024-05-15"}, "title": {"Planning"},
+		"start_time": {"10:00"}, "duration_minutes": {"45"}, "tags": {"Work, planning"},
+	})
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	assert.Equal(t, "/ui?date=2024-05-15&user_id=1&view=day", w.Header().Get("Location"))
+	events, _ := svc.GetByDay(1, date, service.Filter{})
+	require.Len(t, events, 1)
+	assert.Equal(t, []string{"work", "planning"}, events[0].Tags)
+	id := events[0].ID
+	edit := "/ui/events/" + strconv.Itoa(id) + "?user_id=1"
+
+	w = b.post(edit, url.Values{"csrf_token": {token}, "date": {"2024-05-15"}, "title": {"Planning"}, "color": {"red"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "color must look like")
+	assert.Contains(t, w.Body.String(), `value="red"`)
+
+	w = b.post(edit, url.Values{"csrf_token": {b.token(edit)}, "date": {"2024-05-16"}, "title": {"Planning v2"}})
+	require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+	event, _ := svc.Event(id)
+	assert.Equal(t, "Planning v2", event.Title)
+
+	assert.Equal(t, http.StatusNotFound, b.get("/ui/events/"+strconv.Itoa(id)+"?user_id=2").Code)
+	w = b.post("/ui/events/"+strconv.Itoa(id)+"/delete?user_id=2", url.Values{"csrf_token": {token}})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = b.post("/ui/events/"+strconv.Itoa(id)+"/delete?user_id=1", url.Values{"csrf_token": {token}})
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	_, err := svc.Event(id)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestCSRF(t *testing.T) {
+	svc := service.NewService(storage.NewInMemoryStorage())
+	b := &browser{t: t, router: newRouter(svc)}
+	form := url.Values{"date": {"2024-05-15"}, "title": {"Forged"}}
+
+	// No cookie yet, as for a cross-site form.
+	form.Set("csrf_token", "x")
+	assert.Equal(t, http.StatusForbidden, b.post("/ui/events?user_id=1", form).Code)
+
+	token := b.token("/ui/events/new?user_id=1")
+	form.Set("csrf_token", token+"x")
+	assert.Equal(t, http.StatusForbidden, b.post("/ui/events?user_id=1", form).Code)
+
+	form.Set("csrf_token", token)
+	req := httptest.NewRequest(http.MethodPost, "/ui/events?user_id=1", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://evil.example")
+	assert.Equal(t, http.StatusForbidden, b.do(req).Code)
+
+	all, _ := svc.GetByDay(1, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), service.Filter{})
+	assert.Empty(t, all)
+
+	assert.Equal(t, http.StatusSeeOther, b.post("/ui/events?user_id=1", form).Code)
+	assert.Equal(t, http.SameSiteStrictMode, b.cookie.SameSite)
+	assert.True(t, b.cookie.HttpOnly)
+}
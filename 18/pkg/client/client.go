@@ -0,0 +1,791 @@
+// Package client is a typed Go client for the calendar HTTP API described in
+// api/openapi.yaml.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+type Event struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"user_id"`
+	Date            time.Time `json:"date"`
+	Title           string    `json:"title"`
+	StartTime       string    `json:"start_time,omitempty"`
+	DurationMinutes int       `json:"duration_minutes,omitempty"`
+	Description     string    `json:"description,omitempty"`
+	Location        string    `json:"location,omitempty"`
+	Category        string    `json:"category,omitempty"`
+	Color           string    `json:"color,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
+	Attendees       []string  `json:"attendees,omitempty"`
+	Reminders       []int     `json:"reminders,omitempty"`
+}
+
+// Details are the optional fields of an event.
+type Details struct {
+	// StartTime is "15:04" in the user's time zone; empty for all-day events.
+	StartTime       string
+	DurationMinutes int
+	Description     string
+	Location        string
+	Category        string
+	// Color is a hex color like "#1a2b3c".
+	Color string
+	Tags  []string
+	// Attendees are e-mail addresses.
+	Attendees []string
+	// Reminders are minutes before the start.
+	Reminders []int
+}
+
+// Template is a named set of event fields to create events from.
+type Template struct {
+	ID              int      `json:"id"`
+	UserID          int      `json:"user_id"`
+	Name            string   `json:"name"`
+	Title           string   `json:"title"`
+	StartTime       string   `json:"start_time,omitempty"`
+	DurationMinutes int      `json:"duration_minutes,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Location        string   `json:"location,omitempty"`
+	Category        string   `json:"category,omitempty"`
+	Color           string   `json:"color,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Attendees       []string `json:"attendees,omitempty"`
+	Reminders       []int    `json:"reminders,omitempty"`
+}
+
+type CreateTemplateRequest struct {
+	UserID int
+	Name   string
+	Title  string
+	Details
+}
+
+type UpdateTemplateRequest struct {
+	ID     int
+	UserID int
+	Name   string
+	Title  string
+	Details
+}
+
+type CreateEventRequest struct {
+	UserID int
+	Date   time.Time
+	Title  string
+	Details
+}
+
+type UpdateEventRequest struct {
+	ID     int
+	UserID int
+	Date   time.Time
+	Title  string
+	Details
+}
+
+// QueryOption narrows the EventsFor* queries.
+type QueryOption func(url.Values)
+
+// WithTag returns only events having the tag.
+func WithTag(tag string) QueryOption {
+	return func(q url.Values) { q.Set("tag", tag) }
+}
+
+// WithCategory returns only events of the category.
+func WithCategory(category string) QueryOption {
+	return func(q url.Values) { q.Set("category", category) }
+}
+
+// Day is a calendar day with the user's events on it.
+type Day struct {
+	Date    time.Time `json:"date"`
+	Working bool      `json:"working"`
+	Holiday string    `json:"holiday,omitempty"`
+	Events  []Event   `json:"events"`
+}
+
+// UserSettings are calendar preferences of a user. FirstWeekday is an
+// English weekday name such as "sunday".
+type UserSettings struct {
+	Region       string `json:"region"`
+	FirstWeekday string `json:"first_weekday"`
+	TimeZone     string `json:"time_zone"`
+}
+
+// QuickAddResult is the event read from a quick-add phrase. ID is zero for
+// dry runs.
+type QuickAddResult struct {
+	ID       int      `json:"id"`
+	Event    Event    `json:"event"`
+	Warnings []string `json:"warnings"`
+}
+
+// Attachment is a file attached to an event. ID is the SHA-256 of the
+// content.
+type Attachment struct {
+	ID          string    `json:"id"`
+	EventID     int       `json:"event_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Revision is what Undo or Redo changed.
+type Revision struct {
+	// Action is the undone or redone request, such as "update_event".
+	Action string `json:"action"`
+	// Events are the created or updated events as they are now.
+	Events  []Event `json:"events"`
+	Deleted []int   `json:"deleted,omitempty"`
+}
+
+// Change is a created, updated or deleted event; deleted ones carry the
+// event as it was.
+type Change struct {
+	Seq   int64  `json:"seq"`
+	Kind  string `json:"kind"`
+	Event Event  `json:"event"`
+}
+
+// SyncResult is a page of changes; Token is passed to the next Sync.
+type SyncResult struct {
+	Token   string   `json:"token"`
+	Changes []Change `json:"changes"`
+	More    bool     `json:"more"`
+}
+
+// BookingLink lets people without an account book slots of a user's time;
+// From and To are "15:04" in the user's time zone.
+type BookingLink struct {
+	Token         string   `json:"token"`
+	UserID        int      `json:"user_id"`
+	Title         string   `json:"title"`
+	Weekdays      []string `json:"weekdays"`
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	SlotMinutes   int      `json:"slot_minutes"`
+	BufferMinutes int      `json:"buffer_minutes"`
+}
+
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Report sums a user's events from From to To; see Client.Report.
+type Report struct {
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Period      string        `json:"period"`
+	Events      int           `json:"events"`
+	Minutes     int           `json:"minutes"`
+	Periods     []PeriodTotal `json:"periods"`
+	BusiestDays []DayTotal    `json:"busiest_days"`
+	Tags        []GroupTotal  `json:"tags"`
+	Categories  []GroupTotal  `json:"categories"`
+}
+
+type PeriodTotal struct {
+	Start   time.Time `json:"start"`
+	Events  int       `json:"events"`
+	Minutes int       `json:"minutes"`
+}
+
+type DayTotal struct {
+	Date    time.Time `json:"date"`
+	Events  int       `json:"events"`
+	Minutes int       `json:"minutes"`
+}
+
+type GroupTotal struct {
+	Key     string `json:"key"`
+	Events  int    `json:"events"`
+	Minutes int    `json:"minutes"`
+}
+
+type SearchResult struct {
+	Events  []Event `json:"events"`
+	Total   int     `json:"total"`
+	Page    int     `json:"page"`
+	PerPage int     `json:"per_page"`
+}
+
+// Error is returned for every non-2xx response.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("calendar: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes the write call made with ctx send key in the
+// Idempotency-Key header, so that retrying it with the same key and
+// request is safe.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	tenant     string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient, e.g. to configure TLS.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.httpClient = c
+	}
+}
+
+// WithTenant sends every request on behalf of tenant on servers hosting
+// several; without it the server uses its default tenant.
+func WithTenant(tenant string) Option {
+	return func(cl *Client) {
+		cl.tenant = tenant
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) CreateEvent(ctx context.Context, req CreateEventRequest) (int, error) {
+	var id int
+	body := req.body()
+	body["user_id"] = req.UserID
+	body["date"] = req.Date.Format(dateLayout)
+	body["title"] = req.Title
+	err := c.post(ctx, "/create_event", body, &id)
+	return id, err
+}
+
+func (c *Client) UpdateEvent(ctx context.Context, req UpdateEventRequest) error {
+	body := req.body()
+	body["id"] = req.ID
+	body["user_id"] = req.UserID
+	body["date"] = req.Date.Format(dateLayout)
+	body["title"] = req.Title
+	return c.post(ctx, "/update_event", body, nil)
+}
+
+// body leaves out empty fields, so older servers keep accepting requests
+// that do not use them.
+func (d Details) body() map[string]any {
+	body := make(map[string]any)
+	if d.DurationMinutes > 0 {
+		body["duration_minutes"] = d.DurationMinutes
+	}
+	for key, value := range map[string]string{
+		"start_time":  d.StartTime,
+		"description": d.Description,
+		"location":    d.Location,
+		"category":    d.Category,
+		"color":       d.Color,
+	} {
+		if value != "" {
+			body[key] = value
+		}
+	}
+	if len(d.Tags) > 0 {
+		body["tags"] = d.Tags
+	}
+	if len(d.Attendees) > 0 {
+		body["attendees"] = d.Attendees
+	}
+	if len(d.Reminders) > 0 {
+		body["reminders"] = d.Reminders
+	}
+	return body
+}
+
+func (c *Client) CreateTemplate(ctx context.Context, req CreateTemplateRequest) (int, error) {
+	var id int
+	body := req.body()
+	body["user_id"] = req.UserID
+	body["name"] = req.Name
+	body["title"] = req.Title
+	err := c.post(ctx, "/create_template", body, &id)
+	return id, err
+}
+
+func (c *Client) UpdateTemplate(ctx context.Context, req UpdateTemplateRequest) error {
+	body := req.body()
+	body["id"] = req.ID
+	body["user_id"] = req.UserID
+	body["name"] = req.Name
+	body["title"] = req.Title
+	return c.post(ctx, "/update_template", body, nil)
+}
+
+func (c *Client) DeleteTemplate(ctx context.Context, userID, id int) error {
+	return c.post(ctx, "/delete_template", map[string]any{"user_id": userID, "id": id}, nil)
+}
+
+// Templates returns the user's templates ordered by name.
+func (c *Client) Templates(ctx context.Context, userID int) ([]Template, error) {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	var list []Template
+	if err := c.get(ctx, "/templates", q, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// InstantiateTemplate creates an event from the template on date and returns
+// its ID.
+func (c *Client) InstantiateTemplate(ctx context.Context, userID, templateID int, date time.Time) (int, error) {
+	var id int
+	err := c.post(ctx, "/instantiate_template", map[string]any{
+		"user_id":     userID,
+		"template_id": templateID,
+		"date":        date.Format(dateLayout),
+	}, &id)
+	return id, err
+}
+
+// DuplicateEvent copies the event to date and returns the ID of the copy.
+func (c *Client) DuplicateEvent(ctx context.Context, userID, id int, date time.Time) (int, error) {
+	var copyID int
+	err := c.post(ctx, "/duplicate_event", map[string]any{
+		"user_id": userID,
+		"id":      id,
+		"date":    date.Format(dateLayout),
+	}, &copyID)
+	return copyID, err
+}
+
+// ShiftEvents moves the events by days, all or none, and returns them at
+// their new dates.
+func (c *Client) ShiftEvents(ctx context.Context, userID int, ids []int, days int) ([]Event, error) {
+	var events []Event
+	err := c.post(ctx, "/shift_events", map[string]any{
+		"user_id": userID,
+		"ids":     ids,
+		"days":    days,
+	}, &events)
+	return events, err
+}
+
+func (c *Client) DeleteEvent(ctx context.Context, id int) error {
+	return c.post(ctx, "/delete_event", map[string]any{"id": id}, nil)
+}
+
+func (c *Client) EventsForDay(ctx context.Context, userID int, date time.Time, opts ...QueryOption) ([]Event, error) {
+	return c.events(ctx, "/events_for_day", userID, date, opts)
+}
+
+func (c *Client) EventsForWeek(ctx context.Context, userID int, date time.Time, opts ...QueryOption) ([]Event, error) {
+	return c.events(ctx, "/events_for_week", userID, date, opts)
+}
+
+func (c *Client) EventsForMonth(ctx context.Context, userID int, date time.Time, opts ...QueryOption) ([]Event, error) {
+	return c.events(ctx, "/events_for_month", userID, date, opts)
+}
+
+// SearchEvents returns one page of matches; zero page and perPage use the
+// server defaults.
+func (c *Client) SearchEvents(ctx context.Context, userID int, query string, page, perPage int) (*SearchResult, error) {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	q.Set("q", query)
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if perPage > 0 {
+		q.Set("per_page", strconv.Itoa(perPage))
+	}
+
+	var res SearchResult
+	if err := c.get(ctx, "/search_events", q, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Sync returns the changes to the user's events after token; an empty token
+// returns only the current one. A token that is too old fails with a 410
+// Error, after which the client fetches everything again. Zero limit uses
+// the server default.
+func (c *Client) Sync(ctx context.Context, userID int, token string, limit int) (*SyncResult, error) {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	if token != "" {
+		q.Set("token", token)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var res SyncResult
+	if err := c.get(ctx, "/sync", q, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Report sums the user's events dated from from to to, both included, per
+// period: "day", "week" or "month"; empty uses the server default.
+func (c *Client) Report(ctx context.Context, userID int, from, to time.Time, period string) (*Report, error) {
+	var rep Report
+	if err := c.get(ctx, "/analytics", reportQuery(userID, from, to, period), &rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// ReportCSV returns the report of Report as CSV; the caller closes it.
+func (c *Client) ReportCSV(ctx context.Context, userID int, from, to time.Time, period string) (io.ReadCloser, error) {
+	q := reportQuery(userID, from, to, period)
+	q.Set("format", "csv")
+	return c.download(ctx, "/analytics", q)
+}
+
+func reportQuery(userID int, from, to time.Time, period string) url.Values {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	q.Set("from", from.Format(dateLayout))
+	q.Set("to", to.Format(dateLayout))
+	if period != "" {
+		q.Set("period", period)
+	}
+	return q
+}
+
+// CreateBookingLink stores link, whose Token is ignored, and returns it with
+// its new token.
+func (c *Client) CreateBookingLink(ctx context.Context, link BookingLink) (*BookingLink, error) {
+	var res BookingLink
+	err := c.post(ctx, "/create_booking_link", map[string]any{
+		"user_id":        link.UserID,
+		"title":          link.Title,
+		"weekdays":       link.Weekdays,
+		"from":           link.From,
+		"to":             link.To,
+		"slot_minutes":   link.SlotMinutes,
+		"buffer_minutes": link.BufferMinutes,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) BookingLinks(ctx context.Context, userID int) ([]BookingLink, error) {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	var links []BookingLink
+	if err := c.get(ctx, "/booking_links", q, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (c *Client) DeleteBookingLink(ctx context.Context, userID int, token string) error {
+	return c.post(ctx, "/delete_booking_link", map[string]any{"user_id": userID, "token": token}, nil)
+}
+
+// BookingSlots returns the open slots of the booking link from from to to,
+// both included. It needs no credentials.
+func (c *Client) BookingSlots(ctx context.Context, token string, from, to time.Time) ([]Slot, error) {
+	q := url.Values{}
+	q.Set("token", token)
+	q.Set("from", from.Format(dateLayout))
+	q.Set("to", to.Format(dateLayout))
+	var slots []Slot
+	if err := c.get(ctx, "/booking_slots", q, &slots); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+// Book books the open slot starting at start and returns the created event.
+// A slot booked meanwhile fails with a 409 Error.
+func (c *Client) Book(ctx context.Context, token string, start time.Time, name, email string) (*Event, error) {
+	var event Event
+	err := c.post(ctx, "/book", map[string]any{
+		"token": token,
+		"start": start.Format(time.RFC3339),
+		"name":  name,
+		"email": email,
+	}, &event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// WeekDays returns the days of the user's week containing date.
+func (c *Client) WeekDays(ctx context.Context, userID int, date time.Time, opts ...QueryOption) ([]Day, error) {
+	var days []Day
+	err := c.get(ctx, "/week_days", dayQuery(userID, date, opts), &days)
+	return days, err
+}
+
+// BusinessDays returns the next n working days starting at date.
+func (c *Client) BusinessDays(ctx context.Context, userID int, date time.Time, n int, opts ...QueryOption) ([]Day, error) {
+	q := dayQuery(userID, date, opts)
+	q.Set("n", strconv.Itoa(n))
+	var days []Day
+	err := c.get(ctx, "/business_days", q, &days)
+	return days, err
+}
+
+func (c *Client) UserSettings(ctx context.Context, userID int) (*UserSettings, error) {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	var st UserSettings
+	if err := c.get(ctx, "/user_settings", q, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (c *Client) SetUserSettings(ctx context.Context, userID int, st UserSettings) error {
+	return c.post(ctx, "/user_settings", map[string]any{
+		"user_id":       userID,
+		"region":        st.Region,
+		"first_weekday": st.FirstWeekday,
+		"time_zone":     st.TimeZone,
+	}, nil)
+}
+
+// QuickAdd creates an event from a phrase like "Standup tomorrow at 10:00";
+// with dryRun it only returns the interpretation.
+func (c *Client) QuickAdd(ctx context.Context, userID int, text string, dryRun bool) (*QuickAddResult, error) {
+	var res QuickAddResult
+	err := c.post(ctx, "/quick_add", map[string]any{
+		"user_id": userID,
+		"text":    text,
+		"dry_run": dryRun,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Undo reverts the user's latest change; it fails with a 409 Error if an
+// event of it was changed since.
+func (c *Client) Undo(ctx context.Context, userID int) (*Revision, error) {
+	return c.replay(ctx, "/undo", userID)
+}
+
+// Redo makes the user's latest undone change again.
+func (c *Client) Redo(ctx context.Context, userID int) (*Revision, error) {
+	return c.replay(ctx, "/redo", userID)
+}
+
+func (c *Client) replay(ctx context.Context, path string, userID int) (*Revision, error) {
+	var rev Revision
+	if err := c.post(ctx, path, map[string]any{"user_id": userID}, &rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// UploadAttachment attaches the content read from r to the event as name.
+// The content is streamed, not buffered.
+func (c *Client) UploadAttachment(ctx context.Context, eventID int, name string, r io.Reader) (*Attachment, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", name)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	q := url.Values{}
+	q.Set("event_id", strconv.Itoa(eventID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/upload_attachment?"+q.Encode(), pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	setIdempotencyKey(ctx, req)
+
+	var a Attachment
+	if err := c.do(req, &a); err != nil {
+		// Unblock the writer if the server answered before reading it all.
+		pr.CloseWithError(err)
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (c *Client) Attachments(ctx context.Context, eventID int) ([]Attachment, error) {
+	q := url.Values{}
+	q.Set("event_id", strconv.Itoa(eventID))
+	var list []Attachment
+	if err := c.get(ctx, "/attachments", q, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DownloadAttachment returns the content of the attachment; the caller
+// closes it.
+func (c *Client) DownloadAttachment(ctx context.Context, eventID int, id string) (io.ReadCloser, error) {
+	q := url.Values{}
+	q.Set("event_id", strconv.Itoa(eventID))
+	q.Set("id", id)
+	return c.download(ctx, "/download_attachment", q)
+}
+
+// download returns the body of a GET request that does not answer JSON.
+func (c *Client) download(ctx context.Context, path string, q url.Values) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, apiError(resp.StatusCode, body)
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) DeleteAttachment(ctx context.Context, eventID int, id string) error {
+	return c.post(ctx, "/delete_attachment", map[string]any{"event_id": eventID, "id": id}, nil)
+}
+
+func dayQuery(userID int, date time.Time, opts []QueryOption) url.Values {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	q.Set("date", date.Format(dateLayout))
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+func (c *Client) events(ctx context.Context, path string, userID int, date time.Time, opts []QueryOption) ([]Event, error) {
+	var events []Event
+	if err := c.get(ctx, path, dayQuery(userID, date, opts), &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, q url.Values, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, result)
+}
+
+func (c *Client) post(ctx context.Context, path string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setIdempotencyKey(ctx, req)
+	return c.do(req, result)
+}
+
+func setIdempotencyKey(ctx context.Context, req *http.Request) {
+	if key, ok := ctx.Value(idempotencyKey{}).(string); ok && key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+}
+
+// do sends req and decodes the "result" field of the response into result,
+// which may be nil when the caller does not need it.
+func (c *Client) do(req *http.Request, result any) error {
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apiError(resp.StatusCode, body)
+	}
+
+	if result == nil {
+		return nil
+	}
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("calendar: decode response: %w", err)
+	}
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return fmt.Errorf("calendar: decode result: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	return c.httpClient.Do(req)
+}
+
+// apiError decodes the "error" field of a failed response, falling back to
+// the raw body.
+func apiError(status int, body []byte) error {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Error == "" {
+		e.Error = strings.TrimSpace(string(body))
+	}
+	return &Error{StatusCode: status, Message: e.Error}
+}
@@ -0,0 +1,467 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/handler"
+	"wb_l12/18/internal/middleware"
+	"wb_l12/18/internal/service"
+	"wb_l12/18/pkg/storage"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	svc := service.NewService(storage.NewInMemoryStorage())
+	handler.NewEventHandler(svc).RegisterRoutes(router)
+	handler.NewTemplateHandler(svc).RegisterRoutes(router)
+	handler.NewBookingHandler(svc).RegisterRoutes(router)
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return New(srv.URL+"/", WithHTTPClient(srv.Client()))
+}
+
+func TestClient_EventLifecycle(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+
+	id, err := c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Leap"})
+	require.NoError(t, err)
+	assert.Greater(t, id, 0)
+
+	events, err := c.EventsForDay(ctx, 1, day)
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{ID: id, UserID: 1, Date: day, Title: "Leap"}}, events)
+
+	require.NoError(t, c.UpdateEvent(ctx, UpdateEventRequest{ID: id, UserID: 1, Date: day, Title: "Renamed"}))
+	events, err = c.EventsForMonth(ctx, 1, day.AddDate(0, 0, -10))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Renamed", events[0].Title)
+
+	require.NoError(t, c.DeleteEvent(ctx, id))
+	events, err = c.EventsForWeek(ctx, 1, day)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestClient_DecodesErrors(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	err := c.DeleteEvent(ctx, 42)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, storage.ErrNotFound.Error(), apiErr.Message)
+
+	_, err = c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: time.Now()})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "invalid request", apiErr.Message)
+}
+
+func TestClient_SearchEvents(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	day := time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)
+
+	for _, title := range []string{"Sprint planning", "Sprint review", "Lunch"} {
+		_, err := c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: title, Details: Details{Description: "team"}})
+		require.NoError(t, err)
+	}
+
+	res, err := c.SearchEvents(ctx, 1, "sprint", 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Total)
+	assert.Equal(t, 2, res.Page)
+	assert.Equal(t, 1, res.PerPage)
+	require.Len(t, res.Events, 1)
+	assert.Equal(t, "team", res.Events[0].Description)
+
+	res, err = c.SearchEvents(ctx, 1, "nothing", 0, 0)
+	require.NoError(t, err)
+	assert.Zero(t, res.Total)
+	assert.NotNil(t, res.Events)
+
+	_, err = c.SearchEvents(ctx, 1, "sprint", 0, 1000)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestClient_DetailsAndFilters(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	day := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	id, err := c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Offsite", Details: Details{
+		Location: "Berlin",
+		Category: "Work",
+		Color:    "#FF8800",
+		Tags:     []string{"Travel", "team", "travel"},
+	}})
+	require.NoError(t, err)
+	_, err = c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Dentist", Details: Details{Category: "personal"}})
+	require.NoError(t, err)
+
+	events, err := c.EventsForWeek(ctx, 1, day, WithTag("TRAVEL"))
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{
+		ID: id, UserID: 1, Date: day, Title: "Offsite",
+		Location: "Berlin", Category: "Work", Color: "#ff8800", Tags: []string{"travel", "team"},
+	}}, events)
+
+	events, err = c.EventsForMonth(ctx, 1, day, WithCategory("personal"))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Dentist", events[0].Title)
+
+	_, err = c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Bad", Details: Details{Color: "orange"}})
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestClient_UserSettingsAndWeekDays(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	saturday := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	st, err := c.UserSettings(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &UserSettings{FirstWeekday: "monday"}, st)
+
+	require.NoError(t, c.SetUserSettings(ctx, 1, UserSettings{FirstWeekday: "Sat"}))
+	st, _ = c.UserSettings(ctx, 1)
+	assert.Equal(t, "saturday", st.FirstWeekday)
+	var apiErr *Error
+	require.True(t, errors.As(c.SetUserSettings(ctx, 1, UserSettings{Region: "mars"}), &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	days, err := c.WeekDays(ctx, 1, saturday.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	require.Len(t, days, 7)
+	assert.Equal(t, saturday, days[0].Date)
+	assert.False(t, days[0].Working)
+
+	days, err = c.BusinessDays(ctx, 1, saturday, 1)
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, time.Monday, days[0].Date.Weekday())
+}
+
+func TestClient_QuickAdd(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	res, err := c.QuickAdd(ctx, 1, "Coffee at 10:00 for 15m", true)
+	require.NoError(t, err)
+	assert.Zero(t, res.ID)
+	assert.Equal(t, "Coffee", res.Event.Title)
+	assert.Equal(t, "10:00", res.Event.StartTime)
+	assert.Equal(t, 15, res.Event.DurationMinutes)
+	assert.NotEmpty(t, res.Warnings)
+
+	res, err = c.QuickAdd(ctx, 1, "Coffee tomorrow", false)
+	require.NoError(t, err)
+	events, err := c.EventsForDay(ctx, 1, res.Event.Date)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, res.ID, events[0].ID)
+
+	_, err = c.QuickAdd(ctx, 1, "at 10:00", false)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestClient_Attachments(t *testing.T) {
+	attachments, err := storage.NewDiskAttachmentStore(t.TempDir(), 16)
+	require.NoError(t, err)
+	svc := service.NewService(storage.NewInMemoryStorage(), service.WithAttachments(attachments))
+	router := gin.New()
+	handler.NewEventHandler(svc).RegisterRoutes(router)
+	handler.NewAttachmentHandler(svc).RegisterRoutes(router)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	c := New(srv.URL, WithHTTPClient(srv.Client()))
+	ctx := context.Background()
+
+	id, err := c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: time.Now(), Title: "Review"})
+	require.NoError(t, err)
+	a, err := c.UploadAttachment(ctx, id, "agenda.txt", strings.NewReader("1. intro"))
+	require.NoError(t, err)
+	assert.Equal(t, "agenda.txt", a.Name)
+	assert.Equal(t, int64(8), a.Size)
+
+	list, err := c.Attachments(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []Attachment{*a}, list)
+
+	content, err := c.DownloadAttachment(ctx, id, a.ID)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, "1. intro", string(data))
+
+	var apiErr *Error
+	_, err = c.UploadAttachment(ctx, id, "big.txt", strings.NewReader(strings.Repeat("x", 17)))
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, apiErr.StatusCode)
+
+	require.NoError(t, c.DeleteAttachment(ctx, id, a.ID))
+	_, err = c.DownloadAttachment(ctx, id, a.ID)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestClient_TenantIsolation(t *testing.T) {
+	acme := service.NewService(storage.NewInMemoryStorage(), service.WithQuota(service.Quota{MaxEventsPerUser: 1}))
+	globex := service.NewService(storage.NewInMemoryStorage(), service.WithDefaultTimeZone("Asia/Tokyo"))
+	router := gin.New()
+	router.Use(middleware.Tenant(nil))
+	handler.NewEventHandler(service.NewTenants(map[string]*service.Service{
+		"acme":   acme,
+		"globex": globex,
+	})).RegisterRoutes(router, middleware.Idempotency(middleware.NewIdempotencyStore(time.Hour, 0)))
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	newClient := func(tenant string) *Client {
+		return New(srv.URL, WithHTTPClient(srv.Client()), WithTenant(tenant))
+	}
+	a, g := newClient("acme"), newClient("globex")
+	ctx := context.Background()
+	day := time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC)
+
+	// The same idempotency key in another tenant is a new request.
+	keyed := WithIdempotencyKey(ctx, "k1")
+	idA, err := a.CreateEvent(keyed, CreateEventRequest{UserID: 1, Date: day, Title: "Acme board"})
+	require.NoError(t, err)
+	idG, err := g.CreateEvent(keyed, CreateEventRequest{UserID: 1, Date: day, Title: "Globex board"})
+	require.NoError(t, err)
+	assert.Equal(t, idA, idG, "every tenant numbers its events from 1")
+
+	events, err := a.EventsForMonth(ctx, 1, day)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Acme board", events[0].Title)
+	res, err := g.SearchEvents(ctx, 1, "acme", 0, 0)
+	require.NoError(t, err)
+	assert.Zero(t, res.Total)
+
+	// Deleting an ID in one tenant leaves the same ID of the other alone.
+	require.NoError(t, g.DeleteEvent(ctx, idG))
+	events, err = a.EventsForDay(ctx, 1, day)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// Limits and defaults are per tenant.
+	var apiErr *Error
+	_, err = a.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Second"})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	_, err = g.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Second"})
+	require.NoError(t, err)
+	st, err := g.UserSettings(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", st.TimeZone)
+	st, err = a.UserSettings(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, st.TimeZone)
+
+	_, err = newClient("initech").EventsForDay(ctx, 1, day)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	_, err = New(srv.URL, WithHTTPClient(srv.Client())).EventsForDay(ctx, 1, day)
+	require.True(t, errors.As(err, &apiErr), "there is no default tenant in this setup")
+}
+
+func TestClient_TemplatesAndDuplication(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	monday := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	id, err := c.CreateTemplate(ctx, CreateTemplateRequest{UserID: 1, Name: "1:1", Title: "One on one", Details: Details{
+		StartTime: "11:00", DurationMinutes: 30, Tags: []string{"Team"},
+		Attendees: []string{"Lead@Example.com"}, Reminders: []int{15, 5},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, c.UpdateTemplate(ctx, UpdateTemplateRequest{ID: id, UserID: 1, Name: "1:1", Title: "1:1 with lead", Details: Details{
+		StartTime: "11:00", DurationMinutes: 30, Attendees: []string{"lead@example.com"}, Reminders: []int{15, 5},
+	}}))
+	templates, err := c.Templates(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, []int{5, 15}, templates[0].Reminders)
+
+	eventID, err := c.InstantiateTemplate(ctx, 1, id, monday)
+	require.NoError(t, err)
+	copyID, err := c.DuplicateEvent(ctx, 1, eventID, monday.AddDate(0, 0, 7))
+	require.NoError(t, err)
+
+	moved, err := c.ShiftEvents(ctx, 1, []int{eventID, copyID}, 1)
+	require.NoError(t, err)
+	require.Len(t, moved, 2)
+	events, err := c.EventsForDay(ctx, 1, monday.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, []Event{{
+		ID: eventID, UserID: 1, Date: monday.AddDate(0, 0, 1), Title: "1:1 with lead", StartTime: "11:00",
+		DurationMinutes: 30, Attendees: []string{"lead@example.com"}, Reminders: []int{5, 15},
+	}}, events)
+
+	require.NoError(t, c.DeleteTemplate(ctx, 1, id))
+	_, err = c.InstantiateTemplate(ctx, 1, id, monday)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestClient_UndoRedo(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	id, err := c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Draft"})
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteEvent(ctx, id))
+
+	rev, err := c.Undo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &Revision{Action: "delete_event", Events: []Event{{ID: id, UserID: 1, Date: day, Title: "Draft"}}}, rev)
+	rev, err = c.Redo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{id}, rev.Deleted)
+
+	_, err = c.Redo(ctx, 1)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestClient_Sync(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	res, err := c.Sync(ctx, 1, "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, res.Changes)
+	token := res.Token
+
+	id, err := c.CreateEvent(ctx, CreateEventRequest{UserID: 1, Date: day, Title: "Draft"})
+	require.NoError(t, err)
+	require.NoError(t, c.UpdateEvent(ctx, UpdateEventRequest{ID: id, UserID: 1, Date: day, Title: "Final"}))
+	_, err = c.CreateEvent(ctx, CreateEventRequest{UserID: 2, Date: day, Title: "Other user"})
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteEvent(ctx, id))
+
+	res, err = c.Sync(ctx, 1, token, 2)
+	require.NoError(t, err)
+	assert.True(t, res.More)
+	assert.Equal(t, []string{"created", "updated"}, []string{res.Changes[0].Kind, res.Changes[1].Kind})
+	res, err = c.Sync(ctx, 1, res.Token, 2)
+	require.NoError(t, err)
+	assert.False(t, res.More)
+	require.Len(t, res.Changes, 1)
+	assert.Equal(t, "deleted", res.Changes[0].Kind)
+	assert.Equal(t, Event{ID: id, UserID: 1, Date: day, Title: "Final"}, res.Changes[0].Event)
+
+	_, err = c.Sync(ctx, 1, "stale.1", 0)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusGone, apiErr.StatusCode)
+}
+
+func TestClient_Report(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	mon := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	for _, req := range []CreateEventRequest{
+		{UserID: 1, Date: mon, Title: "Standup", Details: Details{DurationMinutes: 15, Category: "work", Tags: []string{"team"}}},
+		{UserID: 1, Date: mon, Title: "Review", Details: Details{DurationMinutes: 60, Category: "work"}},
+		{UserID: 1, Date: mon.AddDate(0, 0, 8), Title: "Gym", Details: Details{DurationMinutes: 45, Category: "personal"}},
+	} {
+		_, err := c.CreateEvent(ctx, req)
+		require.NoError(t, err)
+	}
+
+	rep, err := c.Report(ctx, 1, mon, mon.AddDate(0, 0, 13), "")
+	require.NoError(t, err)
+	assert.Equal(t, "week", rep.Period)
+	assert.Equal(t, 3, rep.Events)
+	assert.Equal(t, 120, rep.Minutes)
+	assert.Equal(t, []PeriodTotal{{Start: mon, Events: 2, Minutes: 75}, {Start: mon.AddDate(0, 0, 7), Events: 1, Minutes: 45}}, rep.Periods)
+	assert.Equal(t, DayTotal{Date: mon, Events: 2, Minutes: 75}, rep.BusiestDays[0])
+	assert.Equal(t, []GroupTotal{{Key: "work", Events: 2, Minutes: 75}, {Key: "personal", Events: 1, Minutes: 45}}, rep.Categories)
+
+	body, err := c.ReportCSV(ctx, 1, mon, mon.AddDate(0, 0, 13), "month")
+	require.NoError(t, err)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "section,key,events,minutes\n"+
+		"total,2024-03-04/2024-03-17,3,120\n"+
+		"month,2024-03-01,3,120\n"+
+		"busiest_day,2024-03-04,2,75\n"+
+		"busiest_day,2024-03-12,1,45\n"+
+		"tag,,2,105\n"+
+		"tag,team,1,15\n"+
+		"category,work,2,75\n"+
+		"category,personal,1,45\n", string(data))
+
+	_, err = c.Report(ctx, 1, mon, mon.AddDate(0, 0, -1), "day")
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestClient_Booking(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	// A Monday far enough ahead for its slots not to have started.
+	day := time.Now().UTC().AddDate(0, 0, 7)
+	day = time.Date(day.Year(), day.Month(), day.Day()-int(day.Weekday())+int(time.Monday), 0, 0, 0, 0, time.UTC)
+
+	link, err := c.CreateBookingLink(ctx, BookingLink{
+		UserID: 1, Title: "Intro", Weekdays: []string{"mon"}, From: "09:00", To: "10:00", SlotMinutes: 30,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"monday"}, link.Weekdays)
+	links, err := c.BookingLinks(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []BookingLink{*link}, links)
+
+	slots, err := c.BookingSlots(ctx, link.Token, day, day)
+	require.NoError(t, err)
+	require.Len(t, slots, 2)
+	event, err := c.Book(ctx, link.Token, slots[0].Start, "Ann", "ann@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Intro: Ann", event.Title)
+	assert.Equal(t, "09:00", event.StartTime)
+
+	_, err = c.Book(ctx, link.Token, slots[0].Start, "Bob", "bob@example.com")
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+
+	require.NoError(t, c.DeleteBookingLink(ctx, 1, link.Token))
+	_, err = c.BookingSlots(ctx, link.Token, day, day)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"sort"
+	"time"
+	"wb_l12/18/internal/model"
+)
+
+// DayTotal sums the events of one calendar day. Date is midnight UTC of
+// the events' date.
+type DayTotal struct {
+	Date    time.Time `json:"date"`
+	Events  int       `json:"events"`
+	Minutes int       `json:"minutes"`
+}
+
+// GroupTotal sums the events with one tag or category; Key is empty for
+// events without any.
+type GroupTotal struct {
+	Key     string `json:"key"`
+	Events  int    `json:"events"`
+	Minutes int    `json:"minutes"`
+}
+
+// Grouping selects what GroupTotals groups events by.
+type Grouping int
+
+const (
+	// ByTag counts an event once under each of its tags.
+	ByTag Grouping = iota + 1
+	ByCategory
+)
+
+// dayKey is a calendar date without a location, usable as a map key.
+type dayKey struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+type dailyAcc map[dayKey]*DayTotal
+
+func (a dailyAcc) add(e model.Event) {
+	y, m, d := e.Date.Date()
+	k := dayKey{y, m, d}
+	t, ok := a[k]
+	if !ok {
+		t = &DayTotal{Date: time.Date(y, m, d, 0, 0, 0, 0, time.UTC)}
+		a[k] = t
+	}
+	t.Events++
+	t.Minutes += e.DurationMinutes
+}
+
+// result returns the totals ordered by day.
+func (a dailyAcc) result() []DayTotal {
+	res := make([]DayTotal, 0, len(a))
+	for _, t := range a {
+		res = append(res, *t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Date.Before(res[j].Date) })
+	return res
+}
+
+type groupAcc struct {
+	by     Grouping
+	groups map[string]*GroupTotal
+}
+
+func newGroupAcc(by Grouping) *groupAcc {
+	return &groupAcc{by: by, groups: make(map[string]*GroupTotal)}
+}
+
+func (a *groupAcc) add(e model.Event) {
+	keys := []string{e.Category}
+	if a.by == ByTag {
+		keys = e.Tags
+		if len(keys) == 0 {
+			keys = []string{""}
+		}
+	}
+	for _, k := range keys {
+		t, ok := a.groups[k]
+		if !ok {
+			t = &GroupTotal{Key: k}
+			a.groups[k] = t
+		}
+		t.Events++
+		t.Minutes += e.DurationMinutes
+	}
+}
+
+// result returns the totals with the most events first, then by key.
+func (a *groupAcc) result() []GroupTotal {
+	res := make([]GroupTotal, 0, len(a.groups))
+	for _, t := range a.groups {
+		res = append(res, *t)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Events != res[j].Events {
+			return res[i].Events > res[j].Events
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+func inRange(date, from, to time.Time) bool {
+	return !date.Before(from) && date.Before(to)
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"wb_l12/18/internal/model"
+)
+
+// FileArchive appends archived events to a file as JSON lines.
+type FileArchive struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileArchive(path string) *FileArchive {
+	return &FileArchive{path: path}
+}
+
+func (a *FileArchive) Archive(events []model.Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrAttachmentNotFound means the event has no attachment with that ID.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentTooLarge means the content exceeds the store size limit.
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+)
+
+// Attachment describes a file attached to an event. ID is the SHA-256 of
+// the content, so attaching the same file twice to an event is a no-op.
+type Attachment struct {
+	ID          string    `json:"id"`
+	EventID     int       `json:"event_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentStore keeps the files attached to events.
+type AttachmentStore interface {
+	// Put reads the content from r and attaches it to the event as name.
+	Put(eventID int, name string, r io.Reader) (Attachment, error)
+	// Open returns the attachment and its content, which the caller closes.
+	Open(eventID int, id string) (Attachment, io.ReadCloser, error)
+	List(eventID int) ([]Attachment, error)
+	Delete(eventID int, id string) error
+	// DeleteAll removes every attachment of the event.
+	DeleteAll(eventID int) error
+}
+
+// DiskAttachmentStore is an AttachmentStore in a local directory. Contents
+// are stored once under blobs/ by their SHA-256 and shared between events;
+// the attachments of every event are listed in events/<id>.json. A blob is
+// removed when no event refers to it anymore.
+type DiskAttachmentStore struct {
+	dir     string
+	maxSize int64
+
+	mu   sync.Mutex
+	refs map[string]int // blob -> number of attachments
+	now  func() time.Time
+}
+
+// NewDiskAttachmentStore opens or creates a store in dir. Contents larger
+// than maxSize bytes are rejected.
+func NewDiskAttachmentStore(dir string, maxSize int64) (*DiskAttachmentStore, error) {
+	s := &DiskAttachmentStore{
+		dir:     dir,
+		maxSize: maxSize,
+		refs:    make(map[string]int),
+		now:     time.Now,
+	}
+	for _, sub := range []string{"blobs", "events", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("attachments: %w", err)
+		}
+	}
+	// Leftovers of interrupted uploads.
+	tmp, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	if err != nil {
+		return nil, fmt.Errorf("attachments: %w", err)
+	}
+	for _, entry := range tmp {
+		os.Remove(filepath.Join(dir, "tmp", entry.Name()))
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "events"))
+	if err != nil {
+		return nil, fmt.Errorf("attachments: %w", err)
+	}
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		list, err := s.list(id)
+		if err != nil {
+			return nil, fmt.Errorf("attachments: %w", err)
+		}
+		for _, a := range list {
+			s.refs[a.ID]++
+		}
+	}
+	return s, nil
+}
+
+func (s *DiskAttachmentStore) Put(eventID int, name string, r io.Reader) (Attachment, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "upload-*")
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	// The content type is sniffed from the first 512 bytes rather than taken
+	// from the client.
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		tmp.Close()
+		return Attachment{}, err
+	}
+	head = head[:n]
+
+	hash := sha256.New()
+	w := io.MultiWriter(tmp, hash)
+	size, err := io.Copy(w, io.MultiReader(bytes.NewReader(head), io.LimitReader(r, s.maxSize+1-int64(n))))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Attachment{}, err
+	}
+	if size > s.maxSize {
+		return Attachment{}, fmt.Errorf("%w: at most %d bytes", ErrAttachmentTooLarge, s.maxSize)
+	}
+
+	a := Attachment{
+		ID:          hex.EncodeToString(hash.Sum(nil)),
+		EventID:     eventID,
+		Name:        cleanName(name),
+		ContentType: http.DetectContentType(head),
+		Size:        size,
+		CreatedAt:   s.now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.list(eventID)
+	if err != nil {
+		return Attachment{}, err
+	}
+	for _, old := range list {
+		if old.ID == a.ID {
+			return old, nil
+		}
+	}
+	if s.refs[a.ID] == 0 {
+		blob := s.blobPath(a.ID)
+		if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+			return Attachment{}, err
+		}
+		if err := os.Rename(tmp.Name(), blob); err != nil {
+			return Attachment{}, err
+		}
+	}
+	if err := s.save(eventID, append(list, a)); err != nil {
+		if s.refs[a.ID] == 0 {
+			os.Remove(s.blobPath(a.ID))
+		}
+		return Attachment{}, err
+	}
+	s.refs[a.ID]++
+	return a, nil
+}
+
+func (s *DiskAttachmentStore) Open(eventID int, id string) (Attachment, io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.list(eventID)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	for _, a := range list {
+		if a.ID == id {
+			f, err := os.Open(s.blobPath(id))
+			if err != nil {
+				return Attachment{}, nil, err
+			}
+			return a, f, nil
+		}
+	}
+	return Attachment{}, nil, ErrAttachmentNotFound
+}
+
+func (s *DiskAttachmentStore) List(eventID int) ([]Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(eventID)
+}
+
+func (s *DiskAttachmentStore) Delete(eventID int, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.list(eventID)
+	if err != nil {
+		return err
+	}
+	for i, a := range list {
+		if a.ID == id {
+			if err := s.save(eventID, append(list[:i:i], list[i+1:]...)); err != nil {
+				return err
+			}
+			return s.release(id)
+		}
+	}
+	return ErrAttachmentNotFound
+}
+
+func (s *DiskAttachmentStore) DeleteAll(eventID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.list(eventID)
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	if err := os.Remove(s.eventPath(eventID)); err != nil {
+		return err
+	}
+	var errs []error
+	for _, a := range list {
+		errs = append(errs, s.release(a.ID))
+	}
+	return errors.Join(errs...)
+}
+
+// release drops a reference to the blob; s.mu must be held.
+func (s *DiskAttachmentStore) release(id string) error {
+	s.refs[id]--
+	if s.refs[id] > 0 {
+		return nil
+	}
+	delete(s.refs, id)
+	if err := os.Remove(s.blobPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// list reads the attachments of the event; s.mu must be held.
+func (s *DiskAttachmentStore) list(eventID int) ([]Attachment, error) {
+	data, err := os.ReadFile(s.eventPath(eventID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Attachment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Attachment
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.eventPath(eventID), err)
+	}
+	return list, nil
+}
+
+// save writes the attachments of the event atomically; s.mu must be held.
+func (s *DiskAttachmentStore) save(eventID int, list []Attachment) error {
+	path := s.eventPath(eventID)
+	if len(list) == 0 {
+		return os.Remove(path)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "event-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *DiskAttachmentStore) eventPath(eventID int) string {
+	return filepath.Join(s.dir, "events", strconv.Itoa(eventID)+".json")
+}
+
+// blobPath spreads blobs over 256 directories by the first hash byte.
+func (s *DiskAttachmentStore) blobPath(id string) string {
+	return filepath.Join(s.dir, "blobs", id[:2], id)
+}
+
+// cleanName keeps the base name without path separators and control
+// characters, so it is safe in a Content-Disposition header.
+func cleanName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskAttachmentStore_SharesBlobs(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskAttachmentStore(dir, 1<<20)
+	require.NoError(t, err)
+
+	a, err := s.Put(1, "../../notes.txt", strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "notes.txt", a.Name)
+	assert.Equal(t, "text/plain; charset=utf-8", a.ContentType)
+	assert.Equal(t, int64(5), a.Size)
+	assert.Len(t, a.ID, 64)
+
+	again, err := s.Put(1, "copy.txt", strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, a, again, "same content on the same event is stored once")
+	_, err = s.Put(2, "other.txt", strings.NewReader("hello"))
+	require.NoError(t, err)
+
+	blob := filepath.Join(dir, "blobs", a.ID[:2], a.ID)
+	require.NoError(t, s.DeleteAll(1))
+	assert.FileExists(t, blob, "event 2 still refers to the blob")
+
+	// The reference counts survive a restart.
+	s, err = NewDiskAttachmentStore(dir, 1<<20)
+	require.NoError(t, err)
+	got, content, err := s.Open(2, a.ID)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "other.txt", got.Name)
+
+	require.NoError(t, s.Delete(2, a.ID))
+	assert.NoFileExists(t, blob)
+	assert.ErrorIs(t, s.Delete(2, a.ID), ErrAttachmentNotFound)
+	list, err := s.List(2)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestDiskAttachmentStore_SizeLimitAndSniffing(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskAttachmentStore(dir, 8)
+	require.NoError(t, err)
+
+	_, err = s.Put(1, "big.bin", strings.NewReader("123456789"))
+	assert.ErrorIs(t, err, ErrAttachmentTooLarge)
+	tmp, _ := os.ReadDir(filepath.Join(dir, "tmp"))
+	assert.Empty(t, tmp)
+
+	// The name says text, the content is a PNG.
+	a, err := s.Put(1, "image.txt", strings.NewReader("\x89PNG\r\n\x1a\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", a.ContentType)
+
+	_, _, err = s.Open(1, "nope")
+	assert.ErrorIs(t, err, ErrAttachmentNotFound)
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"wb_l12/18/internal/model"
+)
+
+var ErrBookingLinkNotFound = errors.New("booking link not found")
+
+// BookingLinkStore keeps booking links by token.
+type BookingLinkStore interface {
+	// Create stores a link with a token not used yet.
+	Create(l model.BookingLink) error
+	Delete(token string) error
+	Get(token string) (model.BookingLink, error)
+	// ListByUser returns the user's links ordered by title.
+	ListByUser(userID int) ([]model.BookingLink, error)
+}
+
+// FileBookingLinkStore keeps booking links in memory and, with a path,
+// saves them there on every change.
+type FileBookingLinkStore struct {
+	mu    sync.RWMutex
+	path  string
+	links map[string]model.BookingLink
+}
+
+// NewInMemoryBookingLinkStore returns a store that loses its links on
+// restart.
+func NewInMemoryBookingLinkStore() *FileBookingLinkStore {
+	return &FileBookingLinkStore{links: make(map[string]model.BookingLink)}
+}
+
+// NewFileBookingLinkStore loads the snapshot at path, if it exists.
+func NewFileBookingLinkStore(path string) (*FileBookingLinkStore, error) {
+	s := NewInMemoryBookingLinkStore()
+	s.path = path
+
+	var links []model.BookingLink
+	if _, err := loadJSON(path, &links); err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		s.links[l.Token] = l
+	}
+	return s, nil
+}
+
+func (s *FileBookingLinkStore) Create(l model.BookingLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[l.Token]; ok {
+		return fmt.Errorf("booking link token %q is taken", l.Token)
+	}
+	s.links[l.Token] = l
+	if err := s.save(); err != nil {
+		delete(s.links, l.Token)
+		return err
+	}
+	return nil
+}
+
+func (s *FileBookingLinkStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.links[token]
+	if !ok {
+		return ErrBookingLinkNotFound
+	}
+	delete(s.links, token)
+	if err := s.save(); err != nil {
+		s.links[token] = old
+		return err
+	}
+	return nil
+}
+
+func (s *FileBookingLinkStore) Get(token string) (model.BookingLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok := s.links[token]
+	if !ok {
+		return model.BookingLink{}, ErrBookingLinkNotFound
+	}
+	return l, nil
+}
+
+func (s *FileBookingLinkStore) ListByUser(userID int) ([]model.BookingLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := []model.BookingLink{}
+	for _, l := range s.links {
+		if l.UserID == userID {
+			res = append(res, l)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Title != res[j].Title {
+			return res[i].Title < res[j].Title
+		}
+		return res[i].Token < res[j].Token
+	})
+	return res, nil
+}
+
+// save writes the snapshot; s.mu must be held.
+func (s *FileBookingLinkStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	links := make([]model.BookingLink, 0, len(s.links))
+	for _, l := range s.links {
+		links = append(links, l)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Token < links[j].Token })
+	return saveJSON(s.path, links)
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+func TestFileBookingLinkStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bookings.json")
+	s, err := NewFileBookingLinkStore(path)
+	require.NoError(t, err)
+
+	intro := model.BookingLink{Token: "b", UserID: 1, Title: "Intro call", Weekdays: []time.Weekday{time.Monday}, From: "09:00", To: "12:00", SlotMinutes: 30}
+	demo := model.BookingLink{Token: "a", UserID: 1, Title: "Demo", Weekdays: []time.Weekday{time.Friday}, From: "14:00", To: "16:00", SlotMinutes: 60, BufferMinutes: 15}
+	gone := model.BookingLink{Token: "c", UserID: 1, Title: "Gone", From: "09:00", To: "10:00", SlotMinutes: 30}
+	for _, l := range []model.BookingLink{intro, demo, gone, {Token: "d", UserID: 2, Title: "Other"}} {
+		require.NoError(t, s.Create(l))
+	}
+	assert.Error(t, s.Create(model.BookingLink{Token: "a", UserID: 3}), "tokens are unique")
+	require.NoError(t, s.Delete("c"))
+
+	s, err = NewFileBookingLinkStore(path)
+	require.NoError(t, err)
+	list, err := s.ListByUser(1)
+	require.NoError(t, err)
+	assert.Equal(t, []model.BookingLink{demo, intro}, list)
+	got, err := s.Get("b")
+	require.NoError(t, err)
+	assert.Equal(t, intro, got)
+	_, err = s.Get("c")
+	assert.ErrorIs(t, err, ErrBookingLinkNotFound)
+	assert.ErrorIs(t, s.Delete("c"), ErrBookingLinkNotFound)
+}
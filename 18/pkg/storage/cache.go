@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+	"wb_l12/18/internal/model"
+)
+
+// CacheStats are counters of a CachedStorage.
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Evictions     int64 `json:"evictions"`
+	Invalidations int64 `json:"invalidations"`
+	Entries       int   `json:"entries"`
+	Capacity      int   `json:"capacity"`
+}
+
+// CachedStorage wraps a Storage and caches the results of GetByDay,
+// GetByWeek and GetByMonth per user and window. Writes going through the
+// cache drop exactly the windows containing the old and new dates of the
+// changed event; writes made to the wrapped storage directly are not seen.
+type CachedStorage struct {
+	Storage
+
+	mu       sync.Mutex
+	capacity int
+	entries  map[cacheKey]*list.Element
+	lru      *list.List // front is the most recently used
+	// gen is bumped on every invalidation so that a read that started
+	// before a write does not store its stale result afterwards.
+	gen   uint64
+	stats CacheStats
+}
+
+type cacheWindow uint8
+
+const (
+	windowDay cacheWindow = iota
+	windowWeek
+	windowMonth
+)
+
+// cacheKey identifies a window by its year and the day of the year, ISO week
+// or month, taken from the date's own location like isSameDay and friends.
+type cacheKey struct {
+	userID int
+	window cacheWindow
+	year   int
+	n      int
+}
+
+type cacheEntry struct {
+	key    cacheKey
+	events []model.Event
+}
+
+// NewCachedStorage caches up to capacity windows of next, evicting the least
+// recently used ones.
+func NewCachedStorage(next Storage, capacity int) *CachedStorage {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &CachedStorage{
+		Storage:  next,
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func keyFor(userID int, window cacheWindow, date time.Time) cacheKey {
+	k := cacheKey{userID: userID, window: window, year: date.Year()}
+	switch window {
+	case windowDay:
+		k.n = date.YearDay()
+	case windowWeek:
+		k.year, k.n = date.ISOWeek()
+	case windowMonth:
+		k.n = int(date.Month())
+	}
+	return k
+}
+
+func (s *CachedStorage) GetByDay(user_id int, date time.Time) ([]model.Event, error) {
+	return s.get(keyFor(user_id, windowDay, date), func() ([]model.Event, error) {
+		return s.Storage.GetByDay(user_id, date)
+	})
+}
+
+func (s *CachedStorage) GetByWeek(user_id int, date time.Time) ([]model.Event, error) {
+	return s.get(keyFor(user_id, windowWeek, date), func() ([]model.Event, error) {
+		return s.Storage.GetByWeek(user_id, date)
+	})
+}
+
+func (s *CachedStorage) GetByMonth(user_id int, date time.Time) ([]model.Event, error) {
+	return s.get(keyFor(user_id, windowMonth, date), func() ([]model.Event, error) {
+		return s.Storage.GetByMonth(user_id, date)
+	})
+}
+
+func (s *CachedStorage) get(key cacheKey, load func() ([]model.Event, error)) ([]model.Event, error) {
+	s.mu.Lock()
+	if el, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(el)
+		s.stats.Hits++
+		events := clone(el.Value.(*cacheEntry).events)
+		s.mu.Unlock()
+		return events, nil
+	}
+	s.stats.Misses++
+	gen := s.gen
+	s.mu.Unlock()
+
+	events, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if gen != s.gen {
+		return events, nil
+	}
+	if el, ok := s.entries[key]; ok {
+		el.Value.(*cacheEntry).events = clone(events)
+		s.lru.MoveToFront(el)
+		return events, nil
+	}
+	s.entries[key] = s.lru.PushFront(&cacheEntry{key: key, events: clone(events)})
+	for s.lru.Len() > s.capacity {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cacheEntry).key)
+		s.stats.Evictions++
+	}
+	return events, nil
+}
+
+// clone keeps callers from modifying cached slices.
+func clone(events []model.Event) []model.Event {
+	if events == nil {
+		return nil
+	}
+	return append([]model.Event(nil), events...)
+}
+
+// invalidate drops every window containing one of the events.
+func (s *CachedStorage) invalidate(events ...model.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	for _, e := range events {
+		for _, w := range []cacheWindow{windowDay, windowWeek, windowMonth} {
+			if el, ok := s.entries[keyFor(e.UserID, w, e.Date)]; ok {
+				s.lru.Remove(el)
+				delete(s.entries, el.Value.(*cacheEntry).key)
+				s.stats.Invalidations++
+			}
+		}
+	}
+}
+
+// previous returns the stored version of the event with id, if any.
+func (s *CachedStorage) previous(id int) ([]model.Event, error) {
+	old, err := s.Storage.GetByID(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []model.Event{old}, nil
+}
+
+func (s *CachedStorage) Create(event *model.Event) (int, error) {
+	id, err := s.Storage.Create(event)
+	if err == nil {
+		s.invalidate(*event)
+	}
+	return id, err
+}
+
+func (s *CachedStorage) Update(event *model.Event) error {
+	old, err := s.previous(event.ID)
+	if err != nil {
+		return err
+	}
+	err = s.Storage.Update(event)
+	s.invalidate(append(old, *event)...)
+	return err
+}
+
+func (s *CachedStorage) Delete(id int) error {
+	old, err := s.previous(id)
+	if err != nil {
+		return err
+	}
+	err = s.Storage.Delete(id)
+	s.invalidate(old...)
+	return err
+}
+
+func (s *CachedStorage) Import(events []model.Event) error {
+	var changed []model.Event
+	for _, e := range events {
+		old, err := s.previous(e.ID)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, old...)
+	}
+	err := s.Storage.Import(events)
+	s.invalidate(append(changed, events...)...)
+	return err
+}
+
+func (s *CachedStorage) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stats
+	st.Entries = s.lru.Len()
+	st.Capacity = s.capacity
+	return st
+}
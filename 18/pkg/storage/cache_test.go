@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+// countingStorage counts window queries reaching the wrapped storage.
+type countingStorage struct {
+	Storage
+	reads atomic.Int32
+}
+
+func (s *countingStorage) GetByDay(user_id int, date time.Time) ([]model.Event, error) {
+	s.reads.Add(1)
+	return s.Storage.GetByDay(user_id, date)
+}
+
+func (s *countingStorage) GetByWeek(user_id int, date time.Time) ([]model.Event, error) {
+	s.reads.Add(1)
+	return s.Storage.GetByWeek(user_id, date)
+}
+
+func (s *countingStorage) GetByMonth(user_id int, date time.Time) ([]model.Event, error) {
+	s.reads.Add(1)
+	return s.Storage.GetByMonth(user_id, date)
+}
+
+func newCounted(capacity int) (*CachedStorage, *countingStorage) {
+	next := &countingStorage{Storage: NewInMemoryStorage()}
+	return NewCachedStorage(next, capacity), next
+}
+
+func TestCachedStorage_HitsAndCopies(t *testing.T) {
+	s, next := newCounted(10)
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	s.Create(&model.Event{UserID: 1, Date: day, Title: "Standup"})
+
+	first, err := s.GetByDay(1, day)
+	require.NoError(t, err)
+	first[0].Title = "changed by caller"
+	second, err := s.GetByDay(1, day.Add(5*time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Standup", second[0].Title)
+	assert.Equal(t, int32(1), next.reads.Load())
+	st := s.Stats()
+	assert.Equal(t, int64(1), st.Hits)
+	assert.Equal(t, int64(1), st.Misses)
+	assert.Equal(t, 1, st.Entries)
+}
+
+func TestCachedStorage_InvalidatesAffectedWindowsOnly(t *testing.T) {
+	s, next := newCounted(10)
+	mon := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	nextMonth := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	id, _ := s.Create(&model.Event{UserID: 1, Date: mon, Title: "Standup"})
+
+	warm := func() {
+		s.GetByDay(1, mon)
+		s.GetByWeek(1, mon)
+		s.GetByMonth(1, mon)
+		s.GetByMonth(1, nextMonth)
+		s.GetByMonth(2, mon)
+	}
+	warm()
+	require.Equal(t, int32(5), next.reads.Load())
+
+	// Another day of the same week keeps the day window of mon cached.
+	s.Create(&model.Event{UserID: 1, Date: mon.AddDate(0, 0, 2), Title: "Retro"})
+	warm()
+	assert.Equal(t, int32(7), next.reads.Load(), "week and month of user 1 reloaded")
+
+	// Moving an event to another month drops both the old and the new windows.
+	require.NoError(t, s.Update(&model.Event{ID: id, UserID: 1, Date: nextMonth, Title: "Standup"}))
+	events, _ := s.GetByMonth(1, nextMonth)
+	assert.Len(t, events, 1)
+	events, _ = s.GetByDay(1, mon)
+	assert.Empty(t, events)
+
+	require.NoError(t, s.Delete(id))
+	events, _ = s.GetByMonth(1, nextMonth)
+	assert.Empty(t, events)
+
+	assert.ErrorIs(t, s.Delete(id), ErrNotFound)
+}
+
+func TestCachedStorage_Import(t *testing.T) {
+	s, _ := newCounted(10)
+	day := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	id, _ := s.Create(&model.Event{UserID: 1, Date: day, Title: "Old"})
+	s.GetByDay(1, day)
+
+	require.NoError(t, s.Import([]model.Event{{ID: id, UserID: 2, Date: day, Title: "Moved"}}))
+	events, _ := s.GetByDay(1, day)
+	assert.Empty(t, events)
+}
+
+func TestCachedStorage_EvictsLeastRecentlyUsed(t *testing.T) {
+	s, next := newCounted(2)
+	d1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d2, d3 := d1.AddDate(0, 0, 1), d1.AddDate(0, 0, 2)
+
+	s.GetByDay(1, d1)
+	s.GetByDay(1, d2)
+	s.GetByDay(1, d1)
+	s.GetByDay(1, d3) // evicts d2
+	require.Equal(t, int32(3), next.reads.Load())
+
+	s.GetByDay(1, d1)
+	assert.Equal(t, int32(3), next.reads.Load())
+	s.GetByDay(1, d2)
+	assert.Equal(t, int32(4), next.reads.Load())
+
+	st := s.Stats()
+	assert.Equal(t, 2, st.Entries)
+	assert.Equal(t, int64(2), st.Evictions)
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	"wb_l12/18/internal/model"
+)
+
+// DefaultChangeLogSize is the number of changes a ChangeLog keeps unless
+// told otherwise.
+const DefaultChangeLogSize = 10000
+
+// ErrChangesExpired means the changes after a sequence number are no longer
+// kept, or the number was never issued by this log.
+var ErrChangesExpired = errors.New("changes are no longer kept")
+
+type ChangeKind string
+
+const (
+	ChangeCreated ChangeKind = "created"
+	ChangeUpdated ChangeKind = "updated"
+	ChangeDeleted ChangeKind = "deleted"
+)
+
+// Change is one write to the storage. Event is the event as written, or as
+// it was before a delete, so deletes leave a tombstone. An update that moves
+// an event to another user is a delete for the previous one and a create
+// for the new one.
+type Change struct {
+	Seq   int64       `json:"seq"`
+	Kind  ChangeKind  `json:"kind"`
+	Event model.Event `json:"event"`
+}
+
+// ChangeLog wraps a Storage and numbers its writes with a sequence that
+// only grows, keeping the latest changes in memory so that clients can ask
+// what changed after a number they saw. The wrapped storage writes without
+// holding the log, which is locked only to number the changes; callers must
+// not write the same event concurrently, as the service ensures, so that
+// the sequence follows the order in which writes are applied. Writes made
+// to the wrapped storage directly are not seen.
+type ChangeLog struct {
+	Storage
+	log *changeLog
+}
+
+type changeLog struct {
+	mu    sync.Mutex
+	epoch string
+	size  int
+	seq   int64
+	// dropped is the sequence of the newest change no longer kept.
+	dropped int64
+	changes []Change
+}
+
+// NewChangeLog keeps the last size changes of next.
+func NewChangeLog(next Storage, size int) *ChangeLog {
+	if size <= 0 {
+		size = DefaultChangeLogSize
+	}
+	return &ChangeLog{Storage: next, log: &changeLog{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  size,
+	}}
+}
+
+// Epoch tells this log apart from the logs of earlier runs, whose sequence
+// numbers mean nothing to it.
+func (c *ChangeLog) Epoch() string {
+	return c.log.epoch
+}
+
+// Seq returns the sequence number of the latest change.
+func (c *ChangeLog) Seq() int64 {
+	c.log.mu.Lock()
+	defer c.log.mu.Unlock()
+	return c.log.seq
+}
+
+// Since returns up to limit changes of the user's events made after seq,
+// oldest first, and the sequence number to ask from next time. more tells
+// whether changes were left out because of the limit.
+func (c *ChangeLog) Since(userID int, seq int64, limit int) (changes []Change, next int64, more bool, err error) {
+	c.log.mu.Lock()
+	defer c.log.mu.Unlock()
+
+	if seq < c.log.dropped || seq > c.log.seq {
+		return nil, 0, false, fmt.Errorf("%w: %d is not within %d..%d", ErrChangesExpired, seq, c.log.dropped, c.log.seq)
+	}
+	changes = []Change{}
+	next = c.log.seq
+	// Changes are ordered by Seq without gaps.
+	start := int(seq - c.log.dropped)
+	for _, ch := range c.log.changes[start:] {
+		if ch.Event.UserID != userID {
+			continue
+		}
+		if len(changes) == limit {
+			more = true
+			next = changes[len(changes)-1].Seq
+			break
+		}
+		changes = append(changes, ch)
+	}
+	return changes, next, more, nil
+}
+
+// WithContext binds the wrapped storage to ctx if it supports it, sharing
+// the log.
+func (c *ChangeLog) WithContext(ctx context.Context) Storage {
+	cs, ok := c.Storage.(interface {
+		WithContext(context.Context) Storage
+	})
+	if !ok {
+		return c
+	}
+	return &ChangeLog{Storage: cs.WithContext(ctx), log: c.log}
+}
+
+// Unwrap returns the wrapped Storage.
+func (c *ChangeLog) Unwrap() Storage {
+	return c.Storage
+}
+
+func (c *ChangeLog) Create(event *model.Event) (int, error) {
+	id, err := c.Storage.Create(event)
+	if err != nil {
+		return 0, err
+	}
+	written := *event
+	written.ID = id
+	c.log.add(Change{Kind: ChangeCreated, Event: written})
+	return id, nil
+}
+
+func (c *ChangeLog) Update(event *model.Event) error {
+	old, err := c.Storage.GetByID(event.ID)
+	if err != nil {
+		return err
+	}
+	if err := c.Storage.Update(event); err != nil {
+		return err
+	}
+	c.log.add(updated(old, *event)...)
+	return nil
+}
+
+func (c *ChangeLog) Delete(id int) error {
+	old, err := c.Storage.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := c.Storage.Delete(id); err != nil {
+		return err
+	}
+	c.log.add(Change{Kind: ChangeDeleted, Event: old})
+	return nil
+}
+
+func (c *ChangeLog) Import(events []model.Event) error {
+	olds := make([]*model.Event, len(events))
+	for i, e := range events {
+		old, err := c.Storage.GetByID(e.ID)
+		if err == nil {
+			olds[i] = &old
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	if err := c.Storage.Import(events); err != nil {
+		return err
+	}
+	var changes []Change
+	for i, e := range events {
+		if olds[i] == nil {
+			changes = append(changes, Change{Kind: ChangeCreated, Event: e})
+		} else {
+			changes = append(changes, updated(*olds[i], e)...)
+		}
+	}
+	c.log.add(changes...)
+	return nil
+}
+
+// updated returns the changes of an update from old to event. An event
+// that changed hands is deleted for the previous user and created for the
+// new one.
+func updated(old, event model.Event) []Change {
+	if old.UserID == event.UserID {
+		return []Change{{Kind: ChangeUpdated, Event: event}}
+	}
+	return []Change{{Kind: ChangeDeleted, Event: old}, {Kind: ChangeCreated, Event: event}}
+}
+
+// add numbers and appends changes and forgets the oldest ones beyond size.
+func (l *changeLog) add(changes ...Change) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, ch := range changes {
+		l.seq++
+		ch.Seq = l.seq
+		l.changes = append(l.changes, ch)
+	}
+	if n := len(l.changes) - l.size; n > 0 {
+		l.dropped = l.changes[n-1].Seq
+		l.changes = l.changes[n:]
+	}
+}
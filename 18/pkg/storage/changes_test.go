@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+func TestChangeLog_Since(t *testing.T) {
+	s := NewChangeLog(NewInMemoryStorage(), 4)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	a := model.Event{UserID: 1, Date: day, Title: "a"}
+	_, err := s.Create(&a)
+	require.NoError(t, err)
+	_, err = s.Create(&model.Event{UserID: 2, Date: day, Title: "b"})
+	require.NoError(t, err)
+	a.Title = "a2"
+	require.NoError(t, s.Update(&a))
+	require.NoError(t, s.Import([]model.Event{{ID: 10, UserID: 1, Date: day, Title: "imported"}}))
+	assert.Equal(t, int64(4), s.Seq())
+
+	changes, next, more, err := s.Since(1, 0, 2)
+	require.NoError(t, err)
+	assert.True(t, more)
+	assert.Equal(t, int64(3), next)
+	assert.Equal(t, []Change{{Seq: 1, Kind: ChangeCreated, Event: model.Event{ID: a.ID, UserID: 1, Date: day, Title: "a"}}, {Seq: 3, Kind: ChangeUpdated, Event: a}}, changes)
+
+	changes, next, more, err = s.Since(1, next, 2)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Equal(t, int64(4), next)
+	require.Len(t, changes, 1)
+	assert.Equal(t, ChangeCreated, changes[0].Kind)
+
+	require.NoError(t, s.Delete(a.ID))
+	changes, next, _, err = s.Since(1, next, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Seq: 5, Kind: ChangeDeleted, Event: a}}, changes)
+	assert.Equal(t, int64(5), next)
+
+	// Five changes with room for four: the first one is gone.
+	_, _, _, err = s.Since(1, 0, 10)
+	assert.ErrorIs(t, err, ErrChangesExpired)
+	_, _, _, err = s.Since(1, 1, 10)
+	assert.NoError(t, err)
+	_, _, _, err = s.Since(1, 6, 10)
+	assert.ErrorIs(t, err, ErrChangesExpired)
+	assert.ErrorIs(t, s.Delete(a.ID), ErrNotFound)
+	assert.Equal(t, int64(5), s.Seq(), "failed writes are not logged")
+}
+
+func TestChangeLog_OwnerChange(t *testing.T) {
+	s := NewChangeLog(NewInMemoryStorage(), 0)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := model.Event{UserID: 1, Date: day, Title: "a"}
+	_, err := s.Create(&a)
+	require.NoError(t, err)
+
+	moved := a
+	moved.UserID = 2
+	require.NoError(t, s.Update(&moved))
+	changes, _, _, err := s.Since(1, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Seq: 2, Kind: ChangeDeleted, Event: a}}, changes)
+	changes, _, _, err = s.Since(2, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Seq: 3, Kind: ChangeCreated, Event: moved}}, changes)
+
+	require.NoError(t, s.Import([]model.Event{a}))
+	changes, _, _, err = s.Since(1, 3, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Seq: 5, Kind: ChangeCreated, Event: a}}, changes)
+}
@@ -0,0 +1,42 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/pkg/storage"
+	"wb_l12/18/pkg/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("InMemory", func(t *testing.T) {
+		storagetest.Run(t, func(*testing.T) storage.Storage { return storage.NewInMemoryStorage() })
+	})
+	t.Run("Sharded", func(t *testing.T) {
+		storagetest.Run(t, func(*testing.T) storage.Storage { return storage.NewShardedStorage(4) })
+	})
+	t.Run("File", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Storage {
+			s, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "events.json"))
+			require.NoError(t, err)
+			return s
+		})
+	})
+	t.Run("Cached", func(t *testing.T) {
+		storagetest.Run(t, func(*testing.T) storage.Storage {
+			return storage.NewCachedStorage(storage.NewShardedStorage(4), 16)
+		})
+	})
+	t.Run("ChangeLog", func(t *testing.T) {
+		storagetest.Run(t, func(*testing.T) storage.Storage {
+			return storage.NewChangeLog(storage.NewInMemoryStorage(), 8)
+		})
+	})
+	t.Run("Traced", func(t *testing.T) {
+		storagetest.Run(t, func(*testing.T) storage.Storage {
+			return storage.NewTracedStorage(storage.NewInMemoryStorage())
+		})
+	})
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wb_l12/18/internal/model"
+)
+
+// FileStorage keeps events in memory and writes a JSON snapshot to disk after
+// every change. A change that cannot be written is undone in memory, so that
+// memory and disk stay the same. It suits small single-instance deployments;
+// only one process may use a file at a time.
+type FileStorage struct {
+	mem  *InMemoryStorage
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStorage loads the snapshot at path, if it exists.
+func NewFileStorage(path string) (*FileStorage, error) {
+	s := &FileStorage{mem: NewInMemoryStorage(), path: path}
+
+	var snap snapshot
+	if ok, err := loadJSON(path, &snap); err != nil || !ok {
+		return s, err
+	}
+	if err := s.mem.Import(snap.Events); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	if snap.NextID > s.mem.nextID {
+		s.mem.nextID = snap.NextID
+	}
+	return s, nil
+}
+
+// snapshot keeps NextID so that IDs of deleted events are not reused after
+// a restart.
+type snapshot struct {
+	NextID int           `json:"next_id"`
+	Events []model.Event `json:"events"`
+}
+
+// Open returns the storage described by dsn: "memory:", "sharded:[SHARDS]"
+// or "file:PATH".
+func Open(dsn string) (Storage, error) {
+	driver, arg, _ := strings.Cut(dsn, ":")
+	switch driver {
+	case "memory":
+		return NewInMemoryStorage(), nil
+	case "sharded":
+		if arg == "" {
+			return NewShardedStorage(DefaultShards), nil
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("sharded storage: invalid shard count %q", arg)
+		}
+		return NewShardedStorage(n), nil
+	case "file":
+		if arg == "" {
+			return nil, errors.New("file storage requires a path, e.g. file:events.json")
+		}
+		return NewFileStorage(arg)
+	default:
+		return nil, fmt.Errorf("unknown storage %q, use memory:, sharded:[SHARDS] or file:PATH", dsn)
+	}
+}
+
+func (s *FileStorage) Create(event *model.Event) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.mem.Create(event)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.save(); err != nil {
+		s.mem.Delete(id)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *FileStorage) Update(event *model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.mem.GetByID(event.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.mem.Update(event); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.mem.Update(&old)
+		return err
+	}
+	return nil
+}
+
+func (s *FileStorage) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.mem.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.mem.Delete(id); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.mem.Import([]model.Event{old})
+		return err
+	}
+	return nil
+}
+
+func (s *FileStorage) Import(events []model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var replaced []model.Event
+	var added []int
+	for _, e := range events {
+		if old, err := s.mem.GetByID(e.ID); err == nil {
+			replaced = append(replaced, old)
+		} else {
+			added = append(added, e.ID)
+		}
+	}
+	if err := s.mem.Import(events); err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		for _, id := range added {
+			s.mem.Delete(id)
+		}
+		s.mem.Import(replaced)
+		return err
+	}
+	return nil
+}
+
+func (s *FileStorage) GetByID(id int) (model.Event, error) {
+	return s.mem.GetByID(id)
+}
+
+func (s *FileStorage) GetByDay(user_id int, date time.Time) ([]model.Event, error) {
+	return s.mem.GetByDay(user_id, date)
+}
+
+func (s *FileStorage) GetByWeek(user_id int, date time.Time) ([]model.Event, error) {
+	return s.mem.GetByWeek(user_id, date)
+}
+
+func (s *FileStorage) GetByMonth(user_id int, date time.Time) ([]model.Event, error) {
+	return s.mem.GetByMonth(user_id, date)
+}
+
+func (s *FileStorage) Search(user_id int, query string, offset, limit int) ([]model.Event, int, error) {
+	return s.mem.Search(user_id, query, offset, limit)
+}
+
+func (s *FileStorage) CountByUser(user_id int) (int, error) {
+	return s.mem.CountByUser(user_id)
+}
+
+func (s *FileStorage) GetBefore(date time.Time) ([]model.Event, error) {
+	return s.mem.GetBefore(date)
+}
+
+func (s *FileStorage) All() ([]model.Event, error) {
+	return s.mem.All()
+}
+
+func (s *FileStorage) DailyTotals(user_id int, from, to time.Time) ([]DayTotal, error) {
+	return s.mem.DailyTotals(user_id, from, to)
+}
+
+func (s *FileStorage) GroupTotals(user_id int, from, to time.Time, by Grouping) ([]GroupTotal, error) {
+	return s.mem.GroupTotals(user_id, from, to, by)
+}
+
+// save writes the snapshot; s.mu must be held.
+func (s *FileStorage) save() error {
+	events, err := s.mem.All()
+	if err != nil {
+		return err
+	}
+	s.mem.mu.RLock()
+	nextID := s.mem.nextID
+	s.mem.mu.RUnlock()
+	return saveJSON(s.path, snapshot{NextID: nextID, Events: events})
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+func TestFileStorage_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	day := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := NewFileStorage(path)
+	require.NoError(t, err)
+	id, err := s.Create(&model.Event{UserID: 1, Date: day, Title: "Kickoff"})
+	require.NoError(t, err)
+	_, err = s.Create(&model.Event{UserID: 1, Date: day, Title: "Lunch"})
+	require.NoError(t, err)
+	_, err = s.Create(&model.Event{UserID: 2, Date: day, Title: "Trip", Location: "Paris", Category: "travel", Color: "#00ff00", Tags: []string{"flight"}})
+	require.NoError(t, err)
+	require.NoError(t, s.Update(&model.Event{ID: id, UserID: 1, Date: day, Title: "Kickoff call"}))
+	require.NoError(t, s.Delete(id+1))
+
+	reopened, err := NewFileStorage(path)
+	require.NoError(t, err)
+	events, err := reopened.GetByDay(1, day)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Kickoff call", events[0].Title)
+
+	_, total, _ := reopened.Search(1, "kick", 0, 0)
+	assert.Equal(t, 1, total, "search index is rebuilt on load")
+	trip, err := reopened.GetByID(id + 2)
+	require.NoError(t, err)
+	assert.Equal(t, model.Event{ID: id + 2, UserID: 2, Date: day, Title: "Trip", Location: "Paris", Category: "travel", Color: "#00ff00", Tags: []string{"flight"}}, trip)
+
+	newID, _ := reopened.Create(&model.Event{UserID: 1, Date: day, Title: "Next"})
+	assert.Equal(t, id+3, newID)
+}
+
+func TestFileStorage_FailedSaveIsUndone(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	day := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	s, err := NewFileStorage(filepath.Join(dir, "events.json"))
+	require.NoError(t, err)
+	id, err := s.Create(&model.Event{UserID: 1, Date: day, Title: "Kickoff"})
+	require.NoError(t, err)
+	before, err := s.All()
+	require.NoError(t, err)
+
+	// Snapshots cannot be written without the directory.
+	require.NoError(t, os.RemoveAll(dir))
+	_, err = s.Create(&model.Event{UserID: 1, Date: day, Title: "Lunch"})
+	assert.Error(t, err)
+	assert.Error(t, s.Update(&model.Event{ID: id, UserID: 2, Date: day, Title: "Kickoff call"}))
+	assert.Error(t, s.Delete(id))
+	assert.Error(t, s.Import([]model.Event{{ID: id, UserID: 1, Date: day, Title: "Imported"}, {ID: 99, UserID: 1, Date: day, Title: "New"}}))
+
+	after, err := s.All()
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	events, err := s.GetByDay(1, day)
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "the index is restored too")
+	n, err := s.CountByUser(2)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestOpen(t *testing.T) {
+	s, err := Open("memory:")
+	require.NoError(t, err)
+	assert.IsType(t, &InMemoryStorage{}, s)
+
+	_, err = Open("file:")
+	assert.Error(t, err)
+	_, err = Open("postgres://localhost")
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "broken.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err = Open("file:" + path)
+	assert.Error(t, err)
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 	"wb_l12/18/internal/model"
@@ -11,15 +12,35 @@ type Storage interface {
 	Create(event *model.Event) (int, error)
 	Update(event *model.Event) error
 	Delete(id int) error
+	GetByID(id int) (model.Event, error)
 	GetByDay(user_id int, date time.Time) ([]model.Event, error)
 	GetByWeek(user_id int, date time.Time) ([]model.Event, error)
 	GetByMonth(user_id int, date time.Time) ([]model.Event, error)
+	// Search returns one page of the user's events matching query, best
+	// matches first, and the total number of matches.
+	Search(user_id int, query string, offset, limit int) ([]model.Event, int, error)
+	CountByUser(user_id int) (int, error)
+	// GetBefore returns events of all users dated before date.
+	GetBefore(date time.Time) ([]model.Event, error)
+	// All returns every stored event ordered by ID.
+	All() ([]model.Event, error)
+	// Import stores events keeping their IDs, replacing existing events with
+	// the same ID. New IDs are allocated after the largest imported one.
+	Import(events []model.Event) error
+	// DailyTotals returns the number and scheduled minutes of the user's
+	// events dated in [from, to) per day, ordered by day. Days without
+	// events are left out.
+	DailyTotals(user_id int, from, to time.Time) ([]DayTotal, error)
+	// GroupTotals returns the number and scheduled minutes of the user's
+	// events dated in [from, to) per tag or category, most events first.
+	GroupTotals(user_id int, from, to time.Time, by Grouping) ([]GroupTotal, error)
 }
 
 var ErrNotFound = fmt.Errorf("event not found")
 
 type InMemoryStorage struct {
 	events map[int]model.Event
+	index  *searchIndex
 	mu     sync.RWMutex
 	nextID int
 }
@@ -27,6 +48,7 @@ type InMemoryStorage struct {
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
 		events: make(map[int]model.Event),
+		index:  newSearchIndex(),
 		nextID: 1,
 	}
 }
@@ -38,6 +60,7 @@ func (s *InMemoryStorage) Create(event *model.Event) (int, error) {
 	id := s.nextID
 	event.ID = id
 	s.events[id] = *event
+	s.index.add(*event)
 	s.nextID++
 	return id, nil
 }
@@ -46,10 +69,13 @@ func (s *InMemoryStorage) Update(event *model.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.events[event.ID]; !ok {
+	old, ok := s.events[event.ID]
+	if !ok {
 		return ErrNotFound
 	}
+	s.index.remove(old)
 	s.events[event.ID] = *event
+	s.index.add(*event)
 
 	return nil
 }
@@ -58,14 +84,27 @@ func (s *InMemoryStorage) Delete(id int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.events[id]; !ok {
+	old, ok := s.events[id]
+	if !ok {
 		return ErrNotFound
 	}
+	s.index.remove(old)
 	delete(s.events, id)
 
 	return nil
 }
 
+func (s *InMemoryStorage) GetByID(id int) (model.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.events[id]
+	if !ok {
+		return model.Event{}, ErrNotFound
+	}
+	return e, nil
+}
+
 func (s *InMemoryStorage) GetByDay(user_id int, date time.Time) ([]model.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -106,6 +145,121 @@ func (s *InMemoryStorage) GetByMonth(user_id int, date time.Time) ([]model.Event
 
 }
 
+func (s *InMemoryStorage) Search(user_id int, query string, offset, limit int) ([]model.Event, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := s.index.search(user_id, query)
+	res := make([]model.Event, 0, len(scores))
+	for id := range scores {
+		res = append(res, s.events[id])
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if scores[a.ID] != scores[b.ID] {
+			return scores[a.ID] > scores[b.ID]
+		}
+		if !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(res)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return res[offset:end], total, nil
+}
+
+func (s *InMemoryStorage) CountByUser(user_id int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, e := range s.events {
+		if e.UserID == user_id {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *InMemoryStorage) GetBefore(date time.Time) ([]model.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []model.Event
+	for _, e := range s.events {
+		if e.Date.Before(date) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (s *InMemoryStorage) All() ([]model.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]model.Event, 0, len(s.events))
+	for _, e := range s.events {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (s *InMemoryStorage) Import(events []model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range events {
+		if e.ID <= 0 {
+			return fmt.Errorf("import event %q: invalid id %d", e.Title, e.ID)
+		}
+	}
+	for _, e := range events {
+		if old, ok := s.events[e.ID]; ok {
+			s.index.remove(old)
+		}
+		s.events[e.ID] = e
+		s.index.add(e)
+		if e.ID >= s.nextID {
+			s.nextID = e.ID + 1
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) DailyTotals(user_id int, from, to time.Time) ([]DayTotal, error) {
+	acc := dailyAcc{}
+	s.each(user_id, from, to, acc.add)
+	return acc.result(), nil
+}
+
+func (s *InMemoryStorage) GroupTotals(user_id int, from, to time.Time, by Grouping) ([]GroupTotal, error) {
+	acc := newGroupAcc(by)
+	s.each(user_id, from, to, acc.add)
+	return acc.result(), nil
+}
+
+// each calls fn for the user's events dated in [from, to).
+func (s *InMemoryStorage) each(userID int, from, to time.Time, fn func(model.Event)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.events {
+		if e.UserID == userID && inRange(e.Date, from, to) {
+			fn(e)
+		}
+	}
+}
+
 func isSameDay(a, b time.Time) bool {
 	return a.Day() == b.Day() && a.Month() == b.Month() && a.Year() == b.Year()
 }
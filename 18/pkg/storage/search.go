@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"wb_l12/18/internal/model"
+)
+
+// Weights of a matched term. Title matches rank above matches in the
+// description, location and tags, and whole-word matches rank above prefix
+// matches.
+const (
+	titleWeight       = 2
+	descriptionWeight = 1
+	exactMatchBonus   = 2
+)
+
+// tokenize splits text into lowercase words of letters and digits. It is
+// Unicode-aware and folds "ё" to "е" so Russian text matches either spelling.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = strings.ReplaceAll(strings.ToLower(w), "ё", "е")
+	}
+	return words
+}
+
+// searchIndex is an inverted index of event words per user. Callers must
+// synchronize access.
+type searchIndex struct {
+	users map[int]*userIndex
+}
+
+type userIndex struct {
+	// postings maps a term to the weight it contributes to each event.
+	postings map[string]map[int]int
+	// terms is kept sorted for prefix lookups.
+	terms []string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{users: make(map[int]*userIndex)}
+}
+
+func termWeights(e model.Event) map[string]int {
+	weights := make(map[string]int)
+	for _, t := range tokenize(e.Title) {
+		weights[t] += titleWeight
+	}
+	for _, text := range append([]string{e.Description, e.Location}, e.Tags...) {
+		for _, t := range tokenize(text) {
+			weights[t] += descriptionWeight
+		}
+	}
+	return weights
+}
+
+func (idx *searchIndex) add(e model.Event) {
+	weights := termWeights(e)
+	if len(weights) == 0 {
+		return
+	}
+	u, ok := idx.users[e.UserID]
+	if !ok {
+		u = &userIndex{postings: make(map[string]map[int]int)}
+		idx.users[e.UserID] = u
+	}
+	for term, w := range weights {
+		p, ok := u.postings[term]
+		if !ok {
+			p = make(map[int]int)
+			u.postings[term] = p
+			i := sort.SearchStrings(u.terms, term)
+			u.terms = append(u.terms, "")
+			copy(u.terms[i+1:], u.terms[i:])
+			u.terms[i] = term
+		}
+		p[e.ID] = w
+	}
+}
+
+func (idx *searchIndex) remove(e model.Event) {
+	u, ok := idx.users[e.UserID]
+	if !ok {
+		return
+	}
+	for term := range termWeights(e) {
+		p := u.postings[term]
+		delete(p, e.ID)
+		if len(p) == 0 {
+			delete(u.postings, term)
+			i := sort.SearchStrings(u.terms, term)
+			u.terms = append(u.terms[:i], u.terms[i+1:]...)
+		}
+	}
+	if len(u.postings) == 0 {
+		delete(idx.users, e.UserID)
+	}
+}
+
+// search returns IDs of the user's events that match every query word,
+// either fully or as a prefix, with their scores.
+func (idx *searchIndex) search(userID int, query string) map[int]int {
+	u, ok := idx.users[userID]
+	words := tokenize(query)
+	if !ok || len(words) == 0 {
+		return nil
+	}
+
+	var scores map[int]int
+	for _, word := range words {
+		matched := make(map[int]int)
+		for i := sort.SearchStrings(u.terms, word); i < len(u.terms) && strings.HasPrefix(u.terms[i], word); i++ {
+			term := u.terms[i]
+			bonus := 1
+			if term == word {
+				bonus = exactMatchBonus
+			}
+			for id, w := range u.postings[term] {
+				matched[id] += w * bonus
+			}
+		}
+
+		if scores == nil {
+			scores = matched
+			continue
+		}
+		for id := range scores {
+			if s, ok := matched[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	return scores
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+func titles(events []model.Event) []string {
+	res := make([]string, 0, len(events))
+	for _, e := range events {
+		res = append(res, e.Title)
+	}
+	return res
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"sprint", "planning", "q3", "2024"}, tokenize("Sprint-planning: Q3/2024!"))
+	assert.Equal(t, []string{"демо", "елка", "и", "ежик"}, tokenize("ДЕМО, Ёлка и ёжик"))
+}
+
+func TestSearch_PrefixCaseAndRanking(t *testing.T) {
+	s := NewInMemoryStorage()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	s.Create(&model.Event{UserID: 1, Date: day, Title: "Lunch", Description: "planning the offsite"})
+	s.Create(&model.Event{UserID: 1, Date: day, Title: "Planning"})
+	s.Create(&model.Event{UserID: 1, Date: day, Title: "Planner review"})
+	s.Create(&model.Event{UserID: 2, Date: day, Title: "Planning"})
+
+	res, total, err := s.Search(1, "PLAN", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, "Lunch", titles(res)[2], "description matches rank below title matches")
+
+	res, _, _ = s.Search(1, "planning", 0, 0)
+	assert.Equal(t, []string{"Planning", "Lunch"}, titles(res))
+
+	res, _, _ = s.Search(1, "plan off", 0, 0)
+	assert.Equal(t, []string{"Lunch"}, titles(res), "every word must match")
+}
+
+func TestSearch_Cyrillic(t *testing.T) {
+	s := NewInMemoryStorage()
+	s.Create(&model.Event{UserID: 1, Date: time.Now(), Title: "Демо для заказчика", Description: "Ёлочные игрушки"})
+
+	for _, q := range []string{"демо", "ДЕМ", "заказ", "елоч", "ёлочные"} {
+		_, total, err := s.Search(1, q, 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total, q)
+	}
+}
+
+func TestSearch_IndexFollowsUpdatesAndDeletes(t *testing.T) {
+	s := NewInMemoryStorage()
+	id, _ := s.Create(&model.Event{UserID: 1, Date: time.Now(), Title: "Retro"})
+
+	require.NoError(t, s.Update(&model.Event{ID: id, UserID: 1, Date: time.Now(), Title: "Demo"}))
+	_, total, _ := s.Search(1, "retro", 0, 0)
+	assert.Zero(t, total)
+	_, total, _ = s.Search(1, "demo", 0, 0)
+	assert.Equal(t, 1, total)
+
+	require.NoError(t, s.Delete(id))
+	_, total, _ = s.Search(1, "demo", 0, 0)
+	assert.Zero(t, total)
+	assert.Empty(t, s.index.users)
+}
+
+func TestSearch_Pagination(t *testing.T) {
+	s := NewInMemoryStorage()
+	for d := 1; d <= 5; d++ {
+		s.Create(&model.Event{UserID: 1, Date: time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC), Title: "Standup"})
+	}
+
+	page, total, err := s.Search(1, "standup", 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	require.Len(t, page, 2)
+	assert.Equal(t, 3, page[0].Date.Day(), "equal scores are ordered by date, newest first")
+
+	page, _, _ = s.Search(1, "standup", 10, 2)
+	assert.Empty(t, page)
+}
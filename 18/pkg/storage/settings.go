@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"sync"
+	"wb_l12/18/internal/model"
+)
+
+// UserSettingsStore keeps the settings of users by user ID.
+type UserSettingsStore interface {
+	// Get reports false for users that have no settings.
+	Get(userID int) (model.UserSettings, bool)
+	Set(userID int, st model.UserSettings) error
+}
+
+// FileUserSettingsStore keeps user settings in memory and, with a path,
+// saves them there on every change.
+type FileUserSettingsStore struct {
+	mu    sync.RWMutex
+	path  string
+	users map[int]model.UserSettings
+}
+
+// NewInMemoryUserSettingsStore returns a store that loses its settings on
+// restart.
+func NewInMemoryUserSettingsStore() *FileUserSettingsStore {
+	return &FileUserSettingsStore{users: make(map[int]model.UserSettings)}
+}
+
+// NewFileUserSettingsStore loads the snapshot at path, if it exists.
+func NewFileUserSettingsStore(path string) (*FileUserSettingsStore, error) {
+	s := NewInMemoryUserSettingsStore()
+	s.path = path
+
+	if _, err := loadJSON(path, &s.users); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileUserSettingsStore) Get(userID int) (model.UserSettings, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok := s.users[userID]
+	return st, ok
+}
+
+func (s *FileUserSettingsStore) Set(userID int, st model.UserSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, existed := s.users[userID]
+	s.users[userID] = st
+	if err := s.save(); err != nil {
+		if existed {
+			s.users[userID] = old
+		} else {
+			delete(s.users, userID)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the snapshot; s.mu must be held.
+func (s *FileUserSettingsStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	// Maps are written with sorted keys.
+	return saveJSON(s.path, s.users)
+}
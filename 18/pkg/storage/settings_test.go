@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+func TestFileUserSettingsStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	s, err := NewFileUserSettingsStore(path)
+	require.NoError(t, err)
+
+	moscow := model.UserSettings{Region: "ru", FirstWeekday: time.Monday, TimeZone: "Europe/Moscow"}
+	require.NoError(t, s.Set(1, model.UserSettings{FirstWeekday: time.Sunday}))
+	require.NoError(t, s.Set(1, moscow))
+	require.NoError(t, s.Set(2, model.UserSettings{FirstWeekday: time.Sunday}))
+
+	s, err = NewFileUserSettingsStore(path)
+	require.NoError(t, err)
+	got, ok := s.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, moscow, got)
+	got, ok = s.Get(2)
+	assert.True(t, ok)
+	assert.Equal(t, time.Sunday, got.FirstWeekday)
+	_, ok = s.Get(3)
+	assert.False(t, ok)
+}
+
+func TestFileUserSettingsStore_FailedSaveIsUndone(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	s, err := NewFileUserSettingsStore(filepath.Join(dir, "settings.json"))
+	require.NoError(t, err)
+	require.NoError(t, s.Set(1, model.UserSettings{FirstWeekday: time.Sunday}))
+
+	require.NoError(t, os.RemoveAll(dir))
+	assert.Error(t, s.Set(1, model.UserSettings{FirstWeekday: time.Monday}))
+	assert.Error(t, s.Set(2, model.UserSettings{FirstWeekday: time.Monday}))
+
+	got, _ := s.Get(1)
+	assert.Equal(t, time.Sunday, got.FirstWeekday)
+	_, ok := s.Get(2)
+	assert.False(t, ok)
+}
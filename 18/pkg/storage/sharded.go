@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"wb_l12/18/internal/model"
+)
+
+// DefaultShards is the number of shards used by Open("sharded:").
+const DefaultShards = 64
+
+// ShardedStorage is an in-memory Storage for many concurrent users. Events
+// are sharded by user ID, each shard with its own lock and search index, and
+// every user's events are kept sorted by date so that window queries are a
+// binary search instead of a scan. A second, ID-striped table serves GetByID
+// and serializes writes to the same event.
+//
+// Like InMemoryStorage, an event falls in a day, week or month by its date
+// in the event's own location.
+type ShardedStorage struct {
+	shards []*shard
+	ids    []*idStripe
+	nextID atomic.Int64
+}
+
+type shard struct {
+	mu    sync.RWMutex
+	users map[int]*userEvents
+	index *searchIndex
+}
+
+// userEvents holds one user's events sorted by date, then ID.
+type userEvents struct {
+	sorted []model.Event
+	byID   map[int]model.Event
+}
+
+type idStripe struct {
+	mu     sync.RWMutex
+	events map[int]model.Event
+}
+
+// NewShardedStorage creates a store with n shards; n < 1 means DefaultShards.
+func NewShardedStorage(n int) *ShardedStorage {
+	if n < 1 {
+		n = DefaultShards
+	}
+	s := &ShardedStorage{
+		shards: make([]*shard, n),
+		ids:    make([]*idStripe, n),
+	}
+	for i := range n {
+		s.shards[i] = &shard{users: make(map[int]*userEvents), index: newSearchIndex()}
+		s.ids[i] = &idStripe{events: make(map[int]model.Event)}
+	}
+	s.nextID.Store(1)
+	return s
+}
+
+func (s *ShardedStorage) shardOf(userID int) *shard {
+	return s.shards[uint(userID)%uint(len(s.shards))]
+}
+
+func (s *ShardedStorage) stripeOf(id int) *idStripe {
+	return s.ids[uint(id)%uint(len(s.ids))]
+}
+
+func (s *ShardedStorage) Create(event *model.Event) (int, error) {
+	id := int(s.nextID.Add(1) - 1)
+	event.ID = id
+
+	st := s.stripeOf(id)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s.put(nil, *event)
+	st.events[id] = *event
+	return id, nil
+}
+
+func (s *ShardedStorage) Update(event *model.Event) error {
+	st := s.stripeOf(event.ID)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	old, ok := st.events[event.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	s.put(&old, *event)
+	st.events[event.ID] = *event
+	return nil
+}
+
+func (s *ShardedStorage) Delete(id int) error {
+	st := s.stripeOf(id)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	old, ok := st.events[id]
+	if !ok {
+		return ErrNotFound
+	}
+	sh := s.shardOf(old.UserID)
+	sh.mu.Lock()
+	sh.remove(old)
+	sh.mu.Unlock()
+	delete(st.events, id)
+	return nil
+}
+
+// put replaces old, if any, with e in the user shards. The ID stripe of e
+// must be locked.
+func (s *ShardedStorage) put(old *model.Event, e model.Event) {
+	if old != nil {
+		sh := s.shardOf(old.UserID)
+		sh.mu.Lock()
+		sh.remove(*old)
+		sh.mu.Unlock()
+	}
+	sh := s.shardOf(e.UserID)
+	sh.mu.Lock()
+	sh.add(e)
+	sh.mu.Unlock()
+}
+
+func (s *ShardedStorage) GetByID(id int) (model.Event, error) {
+	st := s.stripeOf(id)
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	e, ok := st.events[id]
+	if !ok {
+		return model.Event{}, ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *ShardedStorage) GetByDay(user_id int, date time.Time) ([]model.Event, error) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return s.window(user_id, from, from.AddDate(0, 0, 1), func(e model.Event) bool {
+		return isSameDay(e.Date, date)
+	}), nil
+}
+
+func (s *ShardedStorage) GetByWeek(user_id int, date time.Time) ([]model.Event, error) {
+	// ISO weeks start on Monday.
+	offset := (int(date.Weekday()) + 6) % 7
+	from := time.Date(date.Year(), date.Month(), date.Day()-offset, 0, 0, 0, 0, time.UTC)
+	return s.window(user_id, from, from.AddDate(0, 0, 7), func(e model.Event) bool {
+		return isSameWeek(e.Date, date)
+	}), nil
+}
+
+func (s *ShardedStorage) GetByMonth(user_id int, date time.Time) ([]model.Event, error) {
+	from := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return s.window(user_id, from, from.AddDate(0, 1, 0), func(e model.Event) bool {
+		return isSameMonth(e.Date, date)
+	}), nil
+}
+
+// window returns the user's events in the calendar window [from, to), given
+// in UTC, for which in reports true. An event belongs to the window by the
+// date in its own location, which is at most a day off UTC, so the search is
+// widened by a day on each side and in decides at the edges.
+func (s *ShardedStorage) window(userID int, from, to time.Time, in func(model.Event) bool) []model.Event {
+	var res []model.Event
+	s.each(userID, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1), func(e model.Event) {
+		if in(e) {
+			res = append(res, e)
+		}
+	})
+	return res
+}
+
+// each calls fn for the user's events dated in [from, to), in date order,
+// with the shard locked.
+func (s *ShardedStorage) each(userID int, from, to time.Time, fn func(model.Event)) {
+	sh := s.shardOf(userID)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	u, ok := sh.users[userID]
+	if !ok {
+		return
+	}
+	i := sort.Search(len(u.sorted), func(i int) bool { return !u.sorted[i].Date.Before(from) })
+	j := sort.Search(len(u.sorted), func(j int) bool { return !u.sorted[j].Date.Before(to) })
+	for _, e := range u.sorted[i:j] {
+		fn(e)
+	}
+}
+
+func (s *ShardedStorage) DailyTotals(user_id int, from, to time.Time) ([]DayTotal, error) {
+	acc := dailyAcc{}
+	s.each(user_id, from, to, acc.add)
+	return acc.result(), nil
+}
+
+func (s *ShardedStorage) GroupTotals(user_id int, from, to time.Time, by Grouping) ([]GroupTotal, error) {
+	acc := newGroupAcc(by)
+	s.each(user_id, from, to, acc.add)
+	return acc.result(), nil
+}
+
+func (s *ShardedStorage) Search(user_id int, query string, offset, limit int) ([]model.Event, int, error) {
+	sh := s.shardOf(user_id)
+	sh.mu.RLock()
+	scores := sh.index.search(user_id, query)
+	res := make([]model.Event, 0, len(scores))
+	if u, ok := sh.users[user_id]; ok {
+		for id := range scores {
+			res = append(res, u.byID[id])
+		}
+	}
+	sh.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if scores[a.ID] != scores[b.ID] {
+			return scores[a.ID] > scores[b.ID]
+		}
+		if !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(res)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return res[offset:end], total, nil
+}
+
+func (s *ShardedStorage) CountByUser(user_id int) (int, error) {
+	sh := s.shardOf(user_id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	if u, ok := sh.users[user_id]; ok {
+		return len(u.sorted), nil
+	}
+	return 0, nil
+}
+
+func (s *ShardedStorage) GetBefore(date time.Time) ([]model.Event, error) {
+	var res []model.Event
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, u := range sh.users {
+			n := sort.Search(len(u.sorted), func(i int) bool { return !u.sorted[i].Date.Before(date) })
+			res = append(res, u.sorted[:n]...)
+		}
+		sh.mu.RUnlock()
+	}
+	return res, nil
+}
+
+func (s *ShardedStorage) All() ([]model.Event, error) {
+	var res []model.Event
+	for _, st := range s.ids {
+		st.mu.RLock()
+		for _, e := range st.events {
+			res = append(res, e)
+		}
+		st.mu.RUnlock()
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (s *ShardedStorage) Import(events []model.Event) error {
+	for _, e := range events {
+		if e.ID <= 0 {
+			return fmt.Errorf("import event %q: invalid id %d", e.Title, e.ID)
+		}
+	}
+	for _, e := range events {
+		st := s.stripeOf(e.ID)
+		st.mu.Lock()
+		if old, ok := st.events[e.ID]; ok {
+			s.put(&old, e)
+		} else {
+			s.put(nil, e)
+		}
+		st.events[e.ID] = e
+		st.mu.Unlock()
+
+		for {
+			next := s.nextID.Load()
+			if int64(e.ID) < next || s.nextID.CompareAndSwap(next, int64(e.ID)+1) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// add and remove must be called with sh.mu held.
+func (sh *shard) add(e model.Event) {
+	u, ok := sh.users[e.UserID]
+	if !ok {
+		u = &userEvents{byID: make(map[int]model.Event)}
+		sh.users[e.UserID] = u
+	}
+	i := u.position(e)
+	u.sorted = append(u.sorted, model.Event{})
+	copy(u.sorted[i+1:], u.sorted[i:])
+	u.sorted[i] = e
+	u.byID[e.ID] = e
+	sh.index.add(e)
+}
+
+func (sh *shard) remove(e model.Event) {
+	u, ok := sh.users[e.UserID]
+	if !ok {
+		return
+	}
+	if i := u.position(e); i < len(u.sorted) && u.sorted[i].ID == e.ID {
+		u.sorted = append(u.sorted[:i], u.sorted[i+1:]...)
+	}
+	delete(u.byID, e.ID)
+	if len(u.byID) == 0 {
+		delete(sh.users, e.UserID)
+	}
+	sh.index.remove(e)
+}
+
+// position returns the index of e in the date-ordered slice, or where it
+// would be inserted.
+func (u *userEvents) position(e model.Event) int {
+	return sort.Search(len(u.sorted), func(i int) bool {
+		x := u.sorted[i]
+		if !x.Date.Equal(e.Date) {
+			return x.Date.After(e.Date)
+		}
+		return x.ID >= e.ID
+	})
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+func byID(events []model.Event) []model.Event {
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+// TestShardedStorage_MatchesInMemory runs the same random operations against
+// both stores and compares every query.
+func TestShardedStorage_MatchesInMemory(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	mem, sharded := NewInMemoryStorage(), NewShardedStorage(4)
+	start := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	randomEvent := func() model.Event {
+		return model.Event{
+			UserID: rnd.Intn(6) + 1,
+			Date:   start.AddDate(0, 0, rnd.Intn(90)),
+			Title:  fmt.Sprintf("meeting %d", rnd.Intn(5)),
+		}
+	}
+
+	var ids []int
+	for i := 0; i < 500; i++ {
+		switch op := rnd.Intn(10); {
+		case op < 6 || len(ids) == 0:
+			e1 := randomEvent()
+			e2 := e1
+			id1, _ := mem.Create(&e1)
+			id2, _ := sharded.Create(&e2)
+			require.Equal(t, id1, id2)
+			ids = append(ids, id1)
+		case op < 8:
+			e := randomEvent()
+			e.ID = ids[rnd.Intn(len(ids))]
+			assert.Equal(t, mem.Update(&e), sharded.Update(&e))
+		default:
+			id := ids[rnd.Intn(len(ids))]
+			assert.Equal(t, mem.Delete(id), sharded.Delete(id))
+		}
+	}
+
+	for user := 1; user <= 6; user++ {
+		for d := 0; d < 90; d += 3 {
+			date := start.AddDate(0, 0, d).Add(13 * time.Hour)
+			for _, get := range []func(Storage) ([]model.Event, error){
+				func(s Storage) ([]model.Event, error) { return s.GetByDay(user, date) },
+				func(s Storage) ([]model.Event, error) { return s.GetByWeek(user, date) },
+				func(s Storage) ([]model.Event, error) { return s.GetByMonth(user, date) },
+			} {
+				want, _ := get(mem)
+				got, _ := get(sharded)
+				require.Equal(t, byID(want), byID(got), "user %d, %s", user, date)
+			}
+		}
+		want, wantTotal, _ := mem.Search(user, "meet 3", 1, 5)
+		got, gotTotal, _ := sharded.Search(user, "meet 3", 1, 5)
+		assert.Equal(t, wantTotal, gotTotal)
+		assert.Equal(t, want, got)
+
+		wantN, _ := mem.CountByUser(user)
+		gotN, _ := sharded.CountByUser(user)
+		assert.Equal(t, wantN, gotN)
+	}
+
+	want, _ := mem.All()
+	got, _ := sharded.All()
+	assert.Equal(t, want, got)
+	want, _ = mem.GetBefore(start.AddDate(0, 1, 0))
+	got, _ = sharded.GetBefore(start.AddDate(0, 1, 0))
+	assert.Equal(t, byID(want), byID(got))
+}
+
+func TestShardedStorage_MovesEventBetweenUsers(t *testing.T) {
+	s := NewShardedStorage(8)
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	id, _ := s.Create(&model.Event{UserID: 1, Date: day, Title: "Handover"})
+
+	require.NoError(t, s.Update(&model.Event{ID: id, UserID: 2, Date: day, Title: "Handover"}))
+	events, _ := s.GetByDay(1, day)
+	assert.Empty(t, events)
+	events, _ = s.GetByDay(2, day)
+	assert.Len(t, events, 1)
+	_, total, _ := s.Search(2, "hand", 0, 0)
+	assert.Equal(t, 1, total)
+
+	assert.ErrorIs(t, s.Update(&model.Event{ID: id + 1, UserID: 2}), ErrNotFound)
+	assert.ErrorIs(t, s.Delete(id+1), ErrNotFound)
+}
+
+func TestShardedStorage_ImportAdvancesIDs(t *testing.T) {
+	s := NewShardedStorage(2)
+	require.NoError(t, s.Import([]model.Event{{ID: 41, UserID: 1, Date: time.Now(), Title: "Imported"}}))
+	id, _ := s.Create(&model.Event{UserID: 1, Date: time.Now(), Title: "New"})
+	assert.Equal(t, 42, id)
+	assert.Error(t, s.Import([]model.Event{{ID: 0}}))
+}
+
+func TestShardedStorage_ConcurrentCreatesGetUniqueIDs(t *testing.T) {
+	s := NewShardedStorage(4)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(user int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				s.Create(&model.Event{UserID: user, Date: time.Now(), Title: "x"})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	all, _ := s.All()
+	require.Len(t, all, 800)
+	for i, e := range all {
+		assert.Equal(t, i+1, e.ID)
+	}
+}
+
+func TestOpen_Sharded(t *testing.T) {
+	s, err := Open("sharded:")
+	require.NoError(t, err)
+	assert.Len(t, s.(*ShardedStorage).shards, DefaultShards)
+
+	s, err = Open("sharded:8")
+	require.NoError(t, err)
+	assert.Len(t, s.(*ShardedStorage).shards, 8)
+
+	_, err = Open("sharded:zero")
+	assert.Error(t, err)
+}
+
+// benchmarkParallel runs a mix of 90% window reads and 10% creates from many
+// goroutines over a store preloaded with 1000 users.
+func benchmarkParallel(b *testing.B, s Storage) {
+	const users = 1000
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < users*20; i++ {
+		s.Create(&model.Event{UserID: i % users, Date: start.AddDate(0, 0, i%365), Title: "event"})
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			user, date := rnd.Intn(users), start.AddDate(0, 0, rnd.Intn(365))
+			switch n := rnd.Intn(10); {
+			case n == 0:
+				s.Create(&model.Event{UserID: user, Date: date, Title: "event"})
+			case n < 4:
+				s.GetByDay(user, date)
+			case n < 7:
+				s.GetByWeek(user, date)
+			default:
+				s.GetByMonth(user, date)
+			}
+		}
+	})
+}
+
+func BenchmarkParallel_InMemory(b *testing.B) {
+	benchmarkParallel(b, NewInMemoryStorage())
+}
+
+func BenchmarkParallel_Sharded(b *testing.B) {
+	benchmarkParallel(b, NewShardedStorage(DefaultShards))
+}
@@ -0,0 +1,200 @@
+package storagetest
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/pkg/storage"
+)
+
+// reference is the specified behaviour of a Storage written as plainly as
+// possible: a map and linear scans. Implementations are compared to it.
+type reference struct {
+	events map[int]model.Event
+	nextID int
+}
+
+func newReference() *reference {
+	return &reference{events: make(map[int]model.Event), nextID: 1}
+}
+
+func (r *reference) create(e model.Event) int {
+	e.ID = r.nextID
+	r.events[e.ID] = e
+	r.nextID++
+	return e.ID
+}
+
+func (r *reference) update(e model.Event) error {
+	if _, ok := r.events[e.ID]; !ok {
+		return storage.ErrNotFound
+	}
+	r.events[e.ID] = e
+	return nil
+}
+
+func (r *reference) delete(id int) error {
+	if _, ok := r.events[id]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(r.events, id)
+	return nil
+}
+
+func (r *reference) importEvents(events []model.Event) {
+	for _, e := range events {
+		r.events[e.ID] = e
+		if e.ID >= r.nextID {
+			r.nextID = e.ID + 1
+		}
+	}
+}
+
+// filter returns the matching events ordered by ID.
+func (r *reference) filter(match func(model.Event) bool) []model.Event {
+	res := []model.Event{}
+	for _, e := range r.events {
+		if match(e) {
+			res = append(res, e)
+		}
+	}
+	return sortByID(res)
+}
+
+func (r *reference) day(userID int, date time.Time) []model.Event {
+	y, m, d := date.Date()
+	return r.filter(func(e model.Event) bool {
+		ey, em, ed := e.Date.Date()
+		return e.UserID == userID && ey == y && em == m && ed == d
+	})
+}
+
+// week uses ISO weeks: Monday to Sunday, and the first week of a year is
+// the one with its first Thursday.
+func (r *reference) week(userID int, date time.Time) []model.Event {
+	y, w := date.ISOWeek()
+	return r.filter(func(e model.Event) bool {
+		ey, ew := e.Date.ISOWeek()
+		return e.UserID == userID && ey == y && ew == w
+	})
+}
+
+func (r *reference) month(userID int, date time.Time) []model.Event {
+	return r.filter(func(e model.Event) bool {
+		return e.UserID == userID && e.Date.Year() == date.Year() && e.Date.Month() == date.Month()
+	})
+}
+
+// dailyTotals sums the user's events dated in [from, to) per calendar day.
+func (r *reference) dailyTotals(userID int, from, to time.Time) []storage.DayTotal {
+	byDay := map[time.Time]*storage.DayTotal{}
+	for _, e := range r.filter(func(e model.Event) bool {
+		return e.UserID == userID && !e.Date.Before(from) && e.Date.Before(to)
+	}) {
+		y, m, d := e.Date.Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		if byDay[day] == nil {
+			byDay[day] = &storage.DayTotal{Date: day}
+		}
+		byDay[day].Events++
+		byDay[day].Minutes += e.DurationMinutes
+	}
+	res := []storage.DayTotal{}
+	for _, t := range byDay {
+		res = append(res, *t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Date.Before(res[j].Date) })
+	return res
+}
+
+// groupTotals sums the user's events dated in [from, to) per category, or
+// per tag with untagged events under "", most events first, then by key.
+func (r *reference) groupTotals(userID int, from, to time.Time, by storage.Grouping) []storage.GroupTotal {
+	groups := map[string]*storage.GroupTotal{}
+	for _, e := range r.filter(func(e model.Event) bool {
+		return e.UserID == userID && !e.Date.Before(from) && e.Date.Before(to)
+	}) {
+		keys := []string{e.Category}
+		if by == storage.ByTag {
+			keys = append([]string{}, e.Tags...)
+			if len(keys) == 0 {
+				keys = []string{""}
+			}
+		}
+		for _, k := range keys {
+			if groups[k] == nil {
+				groups[k] = &storage.GroupTotal{Key: k}
+			}
+			groups[k].Events++
+			groups[k].Minutes += e.DurationMinutes
+		}
+	}
+	res := []storage.GroupTotal{}
+	for _, t := range groups {
+		res = append(res, *t)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Events != res[j].Events {
+			return res[i].Events > res[j].Events
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+func (r *reference) before(date time.Time) []model.Event {
+	return r.filter(func(e model.Event) bool { return e.Date.Before(date) })
+}
+
+func (r *reference) all() []model.Event {
+	return r.filter(func(model.Event) bool { return true })
+}
+
+// search returns the user's events in which every query word is a prefix
+// of a word of the title, description, location or tags. Ranking is left
+// to the implementation.
+func (r *reference) search(userID int, query string) []model.Event {
+	want := words(query)
+	if len(want) == 0 {
+		return []model.Event{}
+	}
+	return r.filter(func(e model.Event) bool {
+		if e.UserID != userID {
+			return false
+		}
+		text := words(strings.Join(append([]string{e.Title, e.Description, e.Location}, e.Tags...), " "))
+		for _, w := range want {
+			found := false
+			for _, t := range text {
+				if strings.HasPrefix(t, w) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func words(text string) []string {
+	res := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range res {
+		res[i] = strings.ReplaceAll(w, "ё", "е")
+	}
+	return res
+}
+
+func sortByID(events []model.Event) []model.Event {
+	if events == nil {
+		return []model.Event{}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
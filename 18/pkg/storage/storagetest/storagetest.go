@@ -0,0 +1,552 @@
+// Package storagetest checks that a storage.Storage implementation keeps
+// the guarantees the service relies on. A backend runs it from its own
+// tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return mybackend.New(t.TempDir())
+//		})
+//	}
+//
+// Besides fixed cases, Run applies random operation sequences and compares
+// every query with a reference model, and runs random operations from many
+// goroutines; run it with -race.
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/quick"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new, empty storage. It is called for every subtest and
+// random sequence; release resources with t.Cleanup.
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs the conformance suite against the storages made by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("IDs", func(t *testing.T) { testIDs(t, newStorage(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newStorage(t)) })
+	t.Run("Days", func(t *testing.T) { testDays(t, newStorage(t)) })
+	t.Run("ISOWeeks", func(t *testing.T) { testISOWeeks(t, newStorage(t)) })
+	t.Run("Months", func(t *testing.T) { testMonths(t, newStorage(t)) })
+	t.Run("Locations", func(t *testing.T) { testLocations(t, newStorage(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newStorage(t)) })
+	t.Run("Import", func(t *testing.T) { testImport(t, newStorage(t)) })
+	t.Run("Totals", func(t *testing.T) { testTotals(t, newStorage(t)) })
+	t.Run("MatchesModel", func(t *testing.T) { testMatchesModel(t, newStorage) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, newStorage(t)) })
+}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func mustCreate(t *testing.T, s storage.Storage, e model.Event) int {
+	t.Helper()
+	id, err := s.Create(&e)
+	require.NoError(t, err)
+	return id
+}
+
+// ids returns a function listing the IDs of a query result in ID order, so
+// that ids(t)(s.GetByDay(...)) reads like a plain call.
+func ids(t *testing.T) func([]model.Event, error) []int {
+	return func(events []model.Event, err error) []int {
+		t.Helper()
+		require.NoError(t, err)
+		return idsOf(events)
+	}
+}
+
+func idsOf(events []model.Event) []int {
+	res := []int{}
+	for _, e := range sortByID(append([]model.Event(nil), events...)) {
+		res = append(res, e.ID)
+	}
+	return res
+}
+
+// testIDs: IDs are positive, increasing and never reused, and Create
+// writes the ID into the event.
+func testIDs(t *testing.T, s storage.Storage) {
+	e := model.Event{UserID: 1, Date: date(2024, 1, 1), Title: "first"}
+	id, err := s.Create(&e)
+	require.NoError(t, err)
+	assert.Positive(t, id)
+	assert.Equal(t, id, e.ID, "Create sets event.ID")
+
+	second := mustCreate(t, s, model.Event{UserID: 2, Date: date(2024, 1, 1), Title: "second"})
+	assert.Greater(t, second, id)
+	require.NoError(t, s.Delete(second))
+	third := mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 1, 1), Title: "third"})
+	assert.Greater(t, third, second, "IDs of deleted events are not reused")
+
+	got, err := s.GetByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, e, got)
+	all, err := s.All()
+	require.NoError(t, err)
+	assert.Equal(t, []int{id, third}, idsOf(all))
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].ID, all[i].ID, "All is ordered by ID")
+	}
+}
+
+func testNotFound(t *testing.T, s storage.Storage) {
+	id := mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 1, 1), Title: "gone"})
+	require.NoError(t, s.Delete(id))
+
+	_, err := s.GetByID(id)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, s.Delete(id), storage.ErrNotFound)
+	assert.ErrorIs(t, s.Update(&model.Event{ID: id, UserID: 1, Date: date(2024, 1, 1), Title: "back"}), storage.ErrNotFound)
+	assert.ErrorIs(t, s.Update(&model.Event{ID: id + 100, UserID: 1, Date: date(2024, 1, 1), Title: "never"}), storage.ErrNotFound)
+
+	// A failed update must not create the event.
+	all, err := s.All()
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	n, err := s.CountByUser(1)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func testDays(t *testing.T, s storage.Storage) {
+	late := mustCreate(t, s, model.Event{UserID: 1, Date: time.Date(2024, 3, 10, 23, 59, 0, 0, time.UTC), Title: "late"})
+	next := mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 3, 11), Title: "next"})
+	mustCreate(t, s, model.Event{UserID: 2, Date: date(2024, 3, 10), Title: "other user"})
+	mustCreate(t, s, model.Event{UserID: 1, Date: date(2023, 3, 10), Title: "year before"})
+
+	assert.Equal(t, []int{late}, ids(t)(s.GetByDay(1, date(2024, 3, 10))))
+	assert.Equal(t, []int{late}, ids(t)(s.GetByDay(1, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))), "time of day is ignored")
+	assert.Equal(t, []int{next}, ids(t)(s.GetByDay(1, date(2024, 3, 11))))
+	assert.Empty(t, ids(t)(s.GetByDay(3, date(2024, 3, 10))))
+
+	before, err := s.GetBefore(date(2024, 3, 11))
+	require.NoError(t, err)
+	assert.Len(t, before, 3, "GetBefore covers every user and excludes the date itself")
+}
+
+func testISOWeeks(t *testing.T, s storage.Storage) {
+	sunday := mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 12, 29), Title: "sunday"})
+	monday := mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 12, 30), Title: "monday"})
+	newYear := mustCreate(t, s, model.Event{UserID: 1, Date: date(2025, 1, 1), Title: "new year"})
+	nextSunday := mustCreate(t, s, model.Event{UserID: 1, Date: date(2025, 1, 5), Title: "sunday"})
+	mustCreate(t, s, model.Event{UserID: 1, Date: date(2025, 1, 6), Title: "next week"})
+	mustCreate(t, s, model.Event{UserID: 1, Date: date(2023, 12, 30), Title: "year before"})
+
+	// 2024-12-30 to 2025-01-05 is ISO week 1 of 2025.
+	assert.Equal(t, []int{monday, newYear, nextSunday}, ids(t)(s.GetByWeek(1, date(2025, 1, 1))))
+	assert.Equal(t, []int{monday, newYear, nextSunday}, ids(t)(s.GetByWeek(1, date(2024, 12, 30))))
+	assert.Equal(t, []int{sunday}, ids(t)(s.GetByWeek(1, date(2024, 12, 23))))
+	assert.Empty(t, ids(t)(s.GetByWeek(2, date(2025, 1, 1))))
+}
+
+func testMonths(t *testing.T, s storage.Storage) {
+	first := mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 2, 1), Title: "first"})
+	leap := mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 2, 29), Title: "leap"})
+	mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 1, 31), Title: "january"})
+	mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 3, 1), Title: "march"})
+	mustCreate(t, s, model.Event{UserID: 1, Date: date(2023, 2, 15), Title: "year before"})
+	mustCreate(t, s, model.Event{UserID: 2, Date: date(2024, 2, 15), Title: "other user"})
+
+	assert.Equal(t, []int{first, leap}, ids(t)(s.GetByMonth(1, date(2024, 2, 14))))
+	n, err := s.CountByUser(1)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+}
+
+// testLocations checks that an event falls in a day, week or month by its
+// date in its own location, not by the instant in UTC.
+func testLocations(t *testing.T, s storage.Storage) {
+	east := time.FixedZone("UTC+3", 3*60*60)
+	west := time.FixedZone("UTC-5", -5*60*60)
+	// 2024-12-30 04:30 UTC, but still Sunday of ISO week 52.
+	sunday := mustCreate(t, s, model.Event{UserID: 1, Date: time.Date(2024, 12, 29, 23, 30, 0, 0, west), Title: "sunday"})
+	// 2024-12-29 22:00 UTC, but already Monday of ISO week 1.
+	monday := mustCreate(t, s, model.Event{UserID: 1, Date: time.Date(2024, 12, 30, 1, 0, 0, 0, east), Title: "monday"})
+	// 2024-12-01 03:00 UTC, but still November.
+	november := mustCreate(t, s, model.Event{UserID: 1, Date: time.Date(2024, 11, 30, 22, 0, 0, 0, west), Title: "november"})
+
+	assert.Equal(t, []int{sunday}, ids(t)(s.GetByDay(1, date(2024, 12, 29))))
+	assert.Equal(t, []int{monday}, ids(t)(s.GetByDay(1, date(2024, 12, 30))))
+	assert.Equal(t, []int{sunday}, ids(t)(s.GetByWeek(1, date(2024, 12, 23))))
+	assert.Equal(t, []int{monday}, ids(t)(s.GetByWeek(1, date(2025, 1, 1))))
+	assert.Equal(t, []int{monday}, ids(t)(s.GetByWeek(1, time.Date(2025, 1, 5, 23, 0, 0, 0, west))))
+	assert.Equal(t, []int{sunday, monday}, ids(t)(s.GetByMonth(1, date(2024, 12, 1))))
+	assert.Equal(t, []int{november}, ids(t)(s.GetByMonth(1, date(2024, 11, 1))))
+}
+
+func testSearch(t *testing.T, s storage.Storage) {
+	day := date(2024, 5, 1)
+	a := mustCreate(t, s, model.Event{UserID: 1, Date: day, Title: "Sprint planning"})
+	b := mustCreate(t, s, model.Event{UserID: 1, Date: day, Title: "Review", Description: "sprint demo", Tags: []string{"team"}})
+	mustCreate(t, s, model.Event{UserID: 2, Date: day, Title: "Sprint planning"})
+
+	events, total, err := s.Search(1, "SPRI", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, events, 2)
+	assert.Equal(t, []int{a, b}, idsOf(events))
+	assert.Equal(t, a, events[0].ID, "title matches rank first")
+
+	page, total, err := s.Search(1, "sprint", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []int{b}, idsOf(page))
+	page, _, err = s.Search(1, "sprint", 5, 1)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	require.NoError(t, s.Update(&model.Event{ID: b, UserID: 1, Date: day, Title: "Retro"}))
+	assert.Equal(t, []int{a}, ids(t)(searchAll(s, 1, "sprint")), "the index follows updates")
+	assert.Equal(t, []int{b}, ids(t)(searchAll(s, 1, "retro")))
+	require.NoError(t, s.Delete(a))
+	assert.Empty(t, ids(t)(searchAll(s, 1, "sprint")), "the index follows deletes")
+}
+
+func searchAll(s storage.Storage, userID int, query string) ([]model.Event, error) {
+	events, _, err := s.Search(userID, query, 0, 0)
+	return events, err
+}
+
+func testImport(t *testing.T, s storage.Storage) {
+	day := date(2024, 7, 1)
+	require.NoError(t, s.Import([]model.Event{
+		{ID: 10, UserID: 1, Date: day, Title: "ten"},
+		{ID: 41, UserID: 2, Date: day, Title: "forty-one"},
+	}))
+	got, err := s.GetByID(41)
+	require.NoError(t, err)
+	assert.Equal(t, "forty-one", got.Title)
+	assert.Equal(t, 42, mustCreate(t, s, model.Event{UserID: 1, Date: day, Title: "new"}), "IDs continue after the largest imported one")
+
+	require.NoError(t, s.Import([]model.Event{{ID: 10, UserID: 3, Date: day, Title: "replaced"}}))
+	assert.Equal(t, []int{10}, ids(t)(s.GetByDay(3, day)), "importing an existing ID moves the event")
+	assert.Equal(t, []int{42}, ids(t)(s.GetByDay(1, day)))
+
+	assert.Error(t, s.Import([]model.Event{{ID: 0, UserID: 1, Date: day, Title: "bad"}}))
+}
+
+// op is one step of a random program. Pick chooses an existing event for
+// updates and deletes, or a missing one when it is negative.
+type op struct {
+	Kind  int
+	Pick  int
+	Event model.Event
+}
+
+// testTotals: aggregates count each event in its calendar day and under
+// each of its tags, sum durations and honour the half-open range.
+func testTotals(t *testing.T, s storage.Storage) {
+	mon, tue := date(2024, 1, 1), date(2024, 1, 2)
+	mustCreate(t, s, model.Event{UserID: 1, Date: mon, Title: "a", DurationMinutes: 30, Category: "work", Tags: []string{"team", "sync"}})
+	mustCreate(t, s, model.Event{UserID: 1, Date: mon, Title: "b", DurationMinutes: 60, Category: "work", Tags: []string{"team"}})
+	mustCreate(t, s, model.Event{UserID: 1, Date: tue, Title: "c"})
+	mustCreate(t, s, model.Event{UserID: 1, Date: date(2024, 1, 3), Title: "after the range", DurationMinutes: 90})
+	mustCreate(t, s, model.Event{UserID: 2, Date: mon, Title: "other user", DurationMinutes: 45})
+
+	daily, err := s.DailyTotals(1, mon, date(2024, 1, 3))
+	require.NoError(t, err)
+	assert.Equal(t, []storage.DayTotal{{Date: mon, Events: 2, Minutes: 90}, {Date: tue, Events: 1}}, daily)
+
+	tags, err := s.GroupTotals(1, mon, date(2024, 1, 3), storage.ByTag)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.GroupTotal{{Key: "team", Events: 2, Minutes: 90}, {Key: "", Events: 1}, {Key: "sync", Events: 1, Minutes: 30}}, tags)
+	categories, err := s.GroupTotals(1, mon, date(2024, 1, 3), storage.ByCategory)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.GroupTotal{{Key: "work", Events: 2, Minutes: 90}, {Key: "", Events: 1}}, categories)
+
+	daily, err = s.DailyTotals(3, mon, tue)
+	require.NoError(t, err)
+	assert.Empty(t, daily)
+}
+
+const (
+	opCreate = iota
+	opUpdate
+	opDelete
+	opImport
+	opKinds
+)
+
+// start and days bound the random dates; the range crosses a year and an
+// ISO week spanning two years.
+var start = date(2024, 12, 1)
+
+const days = 70
+
+type program []op
+
+// Generate makes programs for testing/quick.
+func (program) Generate(r *rand.Rand, size int) reflect.Value {
+	p := make(program, 20+r.Intn(size+1)*2)
+	for i := range p {
+		p[i] = op{Kind: r.Intn(opKinds), Pick: r.Intn(100) - 10, Event: randomEvent(r, 4)}
+	}
+	return reflect.ValueOf(p)
+}
+
+func randomEvent(r *rand.Rand, users int) model.Event {
+	e := model.Event{
+		UserID: r.Intn(users) + 1,
+		Date:   start.AddDate(0, 0, r.Intn(days)),
+		Title:  fmt.Sprintf("%s %d", []string{"meeting", "lunch", "review", "встреча"}[r.Intn(4)], r.Intn(5)),
+	}
+	if r.Intn(3) == 0 {
+		e.Tags = []string{"team"}
+	}
+	if r.Intn(2) == 0 {
+		e.Category = []string{"work", "personal"}[r.Intn(2)]
+		e.DurationMinutes = 15 * r.Intn(8)
+	}
+	return e
+}
+
+// testMatchesModel is the property that any sequence of operations leaves
+// the storage answering every query like the reference model.
+func testMatchesModel(t *testing.T, newStorage Factory) {
+	property := func(p program) bool {
+		s, ref := newStorage(t), newReference()
+		var known []int
+		pick := func(n int) int {
+			if n < 0 || len(known) == 0 {
+				return ref.nextID + 1000 - n
+			}
+			return known[n%len(known)]
+		}
+
+		for i, o := range p {
+			e := o.Event
+			switch o.Kind {
+			case opCreate:
+				id, err := s.Create(&e)
+				if !assert.NoError(t, err, "op %d", i) || !assert.Equal(t, ref.create(e), id, "op %d: Create ID", i) {
+					return false
+				}
+				known = append(known, id)
+			case opUpdate:
+				e.ID = pick(o.Pick)
+				if !sameError(t, ref.update(e), s.Update(&e), "op %d: Update(%d)", i, e.ID) {
+					return false
+				}
+			case opDelete:
+				id := pick(o.Pick)
+				if !sameError(t, ref.delete(id), s.Delete(id), "op %d: Delete(%d)", i, id) {
+					return false
+				}
+			case opImport:
+				e.ID = pick(o.Pick)
+				if !assert.NoError(t, s.Import([]model.Event{e}), "op %d", i) {
+					return false
+				}
+				ref.importEvents([]model.Event{e})
+				known = append(known, e.ID)
+			}
+		}
+		return compare(t, s, ref)
+	}
+
+	cfg := &quick.Config{MaxCount: 30, Rand: rand.New(rand.NewSource(1))}
+	if testing.Short() {
+		cfg.MaxCount = 5
+	}
+	if err := quick.Check(property, cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func sameError(t *testing.T, want, got error, msg string, args ...any) bool {
+	t.Helper()
+	if errors.Is(want, storage.ErrNotFound) {
+		return assert.ErrorIs(t, got, storage.ErrNotFound, append([]any{msg}, args...)...)
+	}
+	return assert.NoError(t, got, append([]any{msg}, args...)...)
+}
+
+// compare checks every query of the storage against the model.
+func compare(t *testing.T, s storage.Storage, ref *reference) bool {
+	t.Helper()
+	ok := true
+	check := func(want []model.Event, got []model.Event, err error, what string) {
+		ok = assert.NoError(t, err, what) && assert.Equal(t, want, sortByID(got), what) && ok
+	}
+
+	all, err := s.All()
+	ok = assert.NoError(t, err) && assert.Equal(t, ref.all(), sortByID(all), "All") && ok
+	for user := 1; user <= 5; user++ {
+		for d := 0; d < days; d++ {
+			at := start.AddDate(0, 0, d).Add(time.Duration(d%24) * time.Hour)
+			events, err := s.GetByDay(user, at)
+			check(ref.day(user, at), events, err, fmt.Sprintf("GetByDay(%d, %s)", user, at))
+			if d%3 == 0 {
+				events, err = s.GetByWeek(user, at)
+				check(ref.week(user, at), events, err, fmt.Sprintf("GetByWeek(%d, %s)", user, at))
+			}
+			if d%10 == 0 {
+				events, err = s.GetByMonth(user, at)
+				check(ref.month(user, at), events, err, fmt.Sprintf("GetByMonth(%d, %s)", user, at))
+			}
+		}
+		n, err := s.CountByUser(user)
+		ok = assert.NoError(t, err) && assert.Equal(t, len(ref.filter(func(e model.Event) bool { return e.UserID == user })), n, "CountByUser(%d)", user) && ok
+		for _, q := range []string{"meet", "review 3", "team", "ВСТР"} {
+			events, total, err := s.Search(user, q, 0, 0)
+			want := ref.search(user, q)
+			check(want, events, err, fmt.Sprintf("Search(%d, %q)", user, q))
+			ok = assert.Equal(t, len(want), total, "Search(%d, %q) total", user, q) && ok
+		}
+	}
+	for _, id := range []int{1, ref.nextID / 2, ref.nextID - 1} {
+		got, err := s.GetByID(id)
+		if want, found := ref.events[id]; found {
+			ok = assert.NoError(t, err) && assert.Equal(t, want, got, "GetByID(%d)", id) && ok
+		} else {
+			ok = assert.ErrorIs(t, err, storage.ErrNotFound, "GetByID(%d)", id) && ok
+		}
+	}
+	cutoff := start.AddDate(0, 0, days/2)
+	events, err := s.GetBefore(cutoff)
+	check(ref.before(cutoff), events, err, "GetBefore")
+
+	for user := 1; user <= 5; user++ {
+		for _, r := range [][2]time.Time{{start, start.AddDate(0, 0, days)}, {start.AddDate(0, 0, 10), cutoff}} {
+			daily, err := s.DailyTotals(user, r[0], r[1])
+			ok = assert.NoError(t, err) && assert.Equal(t, ref.dailyTotals(user, r[0], r[1]), daily, "DailyTotals(%d, %s, %s)", user, r[0], r[1]) && ok
+			for _, by := range []storage.Grouping{storage.ByTag, storage.ByCategory} {
+				groups, err := s.GroupTotals(user, r[0], r[1], by)
+				ok = assert.NoError(t, err) && assert.Equal(t, ref.groupTotals(user, r[0], r[1], by), groups, "GroupTotals(%d, %s, %s, %d)", user, r[0], r[1], by) && ok
+			}
+		}
+	}
+	return ok
+}
+
+// testConcurrent runs random operations from several writers, each on its
+// own users, next to readers. Afterwards the storage must hold exactly what
+// the writers believe they wrote, under unique IDs.
+func testConcurrent(t *testing.T, s storage.Storage) {
+	const writers, opsPerWriter = 8, 150
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		written = make(map[int]model.Event)
+		stop    = make(chan struct{})
+	)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			mine := make(map[int]model.Event)
+			var order []int
+			for i := 0; i < opsPerWriter; i++ {
+				e := randomEvent(r, 2)
+				e.UserID += w * 2
+				switch k := r.Intn(10); {
+				case k < 5 || len(order) == 0:
+					id, err := s.Create(&e)
+					if !assert.NoError(t, err) {
+						return
+					}
+					if _, dup := mine[id]; dup {
+						t.Errorf("ID %d assigned twice", id)
+					}
+					mine[id] = e
+					order = append(order, id)
+				case k < 8:
+					e.ID = order[r.Intn(len(order))]
+					_, exists := mine[e.ID]
+					err := s.Update(&e)
+					if exists && assert.NoError(t, err) {
+						mine[e.ID] = e
+					} else if !exists {
+						assert.ErrorIs(t, err, storage.ErrNotFound)
+					}
+				default:
+					id := order[r.Intn(len(order))]
+					_, exists := mine[id]
+					err := s.Delete(id)
+					if exists {
+						assert.NoError(t, err)
+						delete(mine, id)
+					} else {
+						assert.ErrorIs(t, err, storage.ErrNotFound)
+					}
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for id, e := range mine {
+				if _, dup := written[id]; dup {
+					t.Errorf("ID %d assigned to two writers", id)
+				}
+				written[id] = e
+			}
+		}(w)
+	}
+
+	var readers sync.WaitGroup
+	for r := 0; r < 2; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			rnd := rand.New(rand.NewSource(int64(100 + r)))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				user := rnd.Intn(writers*2) + 1
+				at := start.AddDate(0, 0, rnd.Intn(days))
+				events, err := s.GetByWeek(user, at)
+				assert.NoError(t, err)
+				for _, e := range events {
+					y1, w1 := e.Date.ISOWeek()
+					y2, w2 := at.ISOWeek()
+					if e.UserID != user || y1 != y2 || w1 != w2 {
+						t.Errorf("GetByWeek(%d, %s) returned %+v", user, at, e)
+					}
+				}
+				_, _, err = s.Search(user, "meeting", 0, 5)
+				assert.NoError(t, err)
+				_, err = s.All()
+				assert.NoError(t, err)
+			}
+		}(r)
+	}
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	ref := newReference()
+	for id, e := range written {
+		e.ID = id
+		ref.events[id] = e
+	}
+	all, err := s.All()
+	require.NoError(t, err)
+	assert.Equal(t, ref.all(), sortByID(all))
+	for user := 1; user <= writers*2; user++ {
+		n, err := s.CountByUser(user)
+		require.NoError(t, err)
+		assert.Equal(t, len(ref.filter(func(e model.Event) bool { return e.UserID == user })), n, "CountByUser(%d)", user)
+		events, err := s.GetByMonth(user, start)
+		assert.NoError(t, err)
+		assert.Equal(t, ref.month(user, start), sortByID(events), "GetByMonth(%d)", user)
+	}
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"wb_l12/18/internal/model"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+// TemplateStore keeps event templates.
+type TemplateStore interface {
+	Create(t *model.Template) (int, error)
+	Update(t *model.Template) error
+	Delete(id int) error
+	Get(id int) (model.Template, error)
+	// ListByUser returns the user's templates ordered by name.
+	ListByUser(userID int) ([]model.Template, error)
+}
+
+// FileTemplateStore keeps templates in memory and, with a path, saves them
+// there on every change.
+type FileTemplateStore struct {
+	mu        sync.RWMutex
+	path      string
+	templates map[int]model.Template
+	nextID    int
+}
+
+// NewInMemoryTemplateStore returns a store that loses its templates on
+// restart.
+func NewInMemoryTemplateStore() *FileTemplateStore {
+	return &FileTemplateStore{templates: make(map[int]model.Template), nextID: 1}
+}
+
+// NewFileTemplateStore loads the snapshot at path, if it exists.
+func NewFileTemplateStore(path string) (*FileTemplateStore, error) {
+	s := NewInMemoryTemplateStore()
+	s.path = path
+
+	var snap templateSnapshot
+	if ok, err := loadJSON(path, &snap); err != nil || !ok {
+		return s, err
+	}
+	for _, t := range snap.Templates {
+		s.templates[t.ID] = t
+		if t.ID >= s.nextID {
+			s.nextID = t.ID + 1
+		}
+	}
+	if snap.NextID > s.nextID {
+		s.nextID = snap.NextID
+	}
+	return s, nil
+}
+
+type templateSnapshot struct {
+	NextID    int              `json:"next_id"`
+	Templates []model.Template `json:"templates"`
+}
+
+func (s *FileTemplateStore) Create(t *model.Template) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.ID = s.nextID
+	s.nextID++
+	s.templates[t.ID] = *t
+	if err := s.save(); err != nil {
+		delete(s.templates, t.ID)
+		return 0, err
+	}
+	return t.ID, nil
+}
+
+func (s *FileTemplateStore) Update(t *model.Template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.templates[t.ID]
+	if !ok {
+		return ErrTemplateNotFound
+	}
+	s.templates[t.ID] = *t
+	if err := s.save(); err != nil {
+		s.templates[t.ID] = old
+		return err
+	}
+	return nil
+}
+
+func (s *FileTemplateStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.templates[id]
+	if !ok {
+		return ErrTemplateNotFound
+	}
+	delete(s.templates, id)
+	if err := s.save(); err != nil {
+		s.templates[id] = old
+		return err
+	}
+	return nil
+}
+
+func (s *FileTemplateStore) Get(id int) (model.Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.templates[id]
+	if !ok {
+		return model.Template{}, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+func (s *FileTemplateStore) ListByUser(userID int) ([]model.Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := []model.Template{}
+	for _, t := range s.templates {
+		if t.UserID == userID {
+			res = append(res, t)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+// save writes the snapshot; s.mu must be held.
+func (s *FileTemplateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	snap := templateSnapshot{NextID: s.nextID, Templates: make([]model.Template, 0, len(s.templates))}
+	for _, t := range s.templates {
+		snap.Templates = append(snap.Templates, t)
+	}
+	sort.Slice(snap.Templates, func(i, j int) bool { return snap.Templates[i].ID < snap.Templates[j].ID })
+	return saveJSON(s.path, snap)
+}
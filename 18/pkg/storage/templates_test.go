@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb_l12/18/internal/model"
+)
+
+func TestFileTemplateStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	s, err := NewFileTemplateStore(path)
+	require.NoError(t, err)
+
+	standup := model.Template{UserID: 1, Name: "Standup", Title: "Daily standup", Reminders: []int{5}}
+	id, err := s.Create(&standup)
+	require.NoError(t, err)
+	retro := model.Template{UserID: 1, Name: "Retro", Title: "Retro"}
+	retroID, err := s.Create(&retro)
+	require.NoError(t, err)
+	_, err = s.Create(&model.Template{UserID: 2, Name: "Other", Title: "Other"})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(retroID))
+	standup.Title = "Standup"
+	require.NoError(t, s.Update(&standup))
+
+	s, err = NewFileTemplateStore(path)
+	require.NoError(t, err)
+	list, err := s.ListByUser(1)
+	require.NoError(t, err)
+	assert.Equal(t, []model.Template{standup}, list)
+	_, err = s.Get(retroID)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.ErrorIs(t, s.Update(&model.Template{ID: retroID}), ErrTemplateNotFound)
+
+	next, err := s.Create(&model.Template{UserID: 1, Name: "Next", Title: "Next"})
+	require.NoError(t, err)
+	assert.Greater(t, next, retroID, "IDs of deleted templates are not reused")
+	assert.NotEqual(t, id, next)
+}
+
+func TestFileTemplateStore_OrdersByName(t *testing.T) {
+	s := NewInMemoryTemplateStore()
+	for _, name := range []string{"b", "a", "c"} {
+		_, err := s.Create(&model.Template{UserID: 1, Name: name, Title: name})
+		require.NoError(t, err)
+	}
+	list, err := s.ListByUser(1)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, []string{list[0].Name, list[1].Name, list[2].Name})
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"time"
+	"wb_l12/18/internal/model"
+	"wb_l12/18/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const tracerName = "wb_l12/18/pkg/storage"
+
+// TracedStorage records a span for every call to the wrapped Storage. The
+// spans are children of the span in the context given to WithContext; the
+// storage returned by NewTracedStorage starts new traces.
+type TracedStorage struct {
+	next Storage
+	ctx  context.Context
+}
+
+func NewTracedStorage(next Storage) *TracedStorage {
+	return &TracedStorage{next: next, ctx: context.Background()}
+}
+
+// WithContext returns a TracedStorage on the same storage tracing under ctx.
+func (t *TracedStorage) WithContext(ctx context.Context) Storage {
+	return &TracedStorage{next: t.next, ctx: ctx}
+}
+
+// Unwrap returns the wrapped Storage.
+func (t *TracedStorage) Unwrap() Storage {
+	return t.next
+}
+
+func (t *TracedStorage) Create(event *model.Event) (id int, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.Create", attribute.Int("user_id", event.UserID))
+	defer func() { tracing.End(span, err) }()
+	id, err = t.next.Create(event)
+	span.SetAttributes(attribute.Int("event_id", id))
+	return id, err
+}
+
+func (t *TracedStorage) Update(event *model.Event) (err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.Update",
+		attribute.Int("user_id", event.UserID), attribute.Int("event_id", event.ID))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Update(event)
+}
+
+func (t *TracedStorage) Delete(id int) (err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.Delete", attribute.Int("event_id", id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Delete(id)
+}
+
+func (t *TracedStorage) GetByID(id int) (event model.Event, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.GetByID", attribute.Int("event_id", id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.GetByID(id)
+}
+
+func (t *TracedStorage) GetByDay(userID int, date time.Time) ([]model.Event, error) {
+	return t.window("storage.GetByDay", "day", userID, date, t.next.GetByDay)
+}
+
+func (t *TracedStorage) GetByWeek(userID int, date time.Time) ([]model.Event, error) {
+	return t.window("storage.GetByWeek", "week", userID, date, t.next.GetByWeek)
+}
+
+func (t *TracedStorage) GetByMonth(userID int, date time.Time) ([]model.Event, error) {
+	return t.window("storage.GetByMonth", "month", userID, date, t.next.GetByMonth)
+}
+
+// window traces a day, week or month query.
+func (t *TracedStorage) window(name, window string, userID int, date time.Time, get func(int, time.Time) ([]model.Event, error)) (events []model.Event, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, name,
+		attribute.Int("user_id", userID),
+		attribute.String("window", window),
+		attribute.String("date", date.Format(time.DateOnly)),
+	)
+	defer func() { tracing.End(span, err) }()
+	events, err = get(userID, date)
+	span.SetAttributes(attribute.Int("events", len(events)))
+	return events, err
+}
+
+func (t *TracedStorage) Search(userID int, query string, offset, limit int) (events []model.Event, total int, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.Search",
+		attribute.Int("user_id", userID), attribute.Int("offset", offset), attribute.Int("limit", limit))
+	defer func() { tracing.End(span, err) }()
+	events, total, err = t.next.Search(userID, query, offset, limit)
+	span.SetAttributes(attribute.Int("events", len(events)), attribute.Int("total", total))
+	return events, total, err
+}
+
+func (t *TracedStorage) CountByUser(userID int) (n int, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.CountByUser", attribute.Int("user_id", userID))
+	defer func() { tracing.End(span, err) }()
+	return t.next.CountByUser(userID)
+}
+
+func (t *TracedStorage) GetBefore(date time.Time) (events []model.Event, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.GetBefore", attribute.String("date", date.Format(time.DateOnly)))
+	defer func() { tracing.End(span, err) }()
+	events, err = t.next.GetBefore(date)
+	span.SetAttributes(attribute.Int("events", len(events)))
+	return events, err
+}
+
+func (t *TracedStorage) All() (events []model.Event, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.All")
+	defer func() { tracing.End(span, err) }()
+	events, err = t.next.All()
+	span.SetAttributes(attribute.Int("events", len(events)))
+	return events, err
+}
+
+func (t *TracedStorage) Import(events []model.Event) (err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.Import", attribute.Int("events", len(events)))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Import(events)
+}
+
+func (t *TracedStorage) DailyTotals(userID int, from, to time.Time) (totals []DayTotal, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.DailyTotals", rangeAttrs(userID, from, to)...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.DailyTotals(userID, from, to)
+}
+
+func (t *TracedStorage) GroupTotals(userID int, from, to time.Time, by Grouping) (totals []GroupTotal, err error) {
+	_, span := tracing.Start(t.ctx, tracerName, "storage.GroupTotals",
+		append(rangeAttrs(userID, from, to), attribute.Int("grouping", int(by)))...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.GroupTotals(userID, from, to, by)
+}
+
+func rangeAttrs(userID int, from, to time.Time) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("user_id", userID),
+		attribute.String("from", from.Format(time.DateOnly)),
+		attribute.String("to", to.Format(time.DateOnly)),
+	}
+}